@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"smb-chatbot/internal/auth"
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+// runAPIKeys implements the api-keys command: "create -name N -role R",
+// "list" and "revoke ID".
+func runAPIKeys(ctx context.Context, keys usecase.APIKeyService, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: api-keys create -name NAME -role owner|agent|integration | api-keys list | api-keys revoke ID")
+	}
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("api-keys create", flag.ContinueOnError)
+		name := flags.String("name", "", "name of the key, e.g. the integration using it")
+		role := flags.String("role", entity.RoleIntegration, "owner, agent or integration")
+		if err := flags.Parse(args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil
+			}
+			return err
+		}
+		key, secret, err := keys.Create(ctx, *name, *role)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Created %s key %s (%s). Store it now, it is not shown again:\n", key.Role, key.ID, key.Name)
+		fmt.Println(secret)
+		return nil
+	case "list":
+		list, err := keys.List(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPREFIX\tROLE\tCREATED\tLAST USED\tREVOKED")
+		for _, key := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix, key.Role,
+				formatTimeColumn(key.CreatedAt), formatTimeColumn(key.LastUsedAt), formatTimeColumn(key.RevokedAt))
+		}
+		return w.Flush()
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New("usage: api-keys revoke ID")
+		}
+		return keys.Revoke(ctx, args[1])
+	default:
+		return fmt.Errorf("unknown api-keys command '%s'", args[0])
+	}
+}
+
+// runIssueToken implements the issue-token command, which prints a dashboard
+// token for a user with a role.
+func runIssueToken(signer *auth.TokenSigner, args []string) error {
+	flags := flag.NewFlagSet("issue-token", flag.ContinueOnError)
+	subject := flags.String("subject", "", "the user the token is for, e.g. an email address")
+	role := flags.String("role", entity.RoleAgent, "owner, agent or integration")
+	ttl := flags.Duration("ttl", 12*time.Hour, "validity of the token")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	switch {
+	case signer == nil:
+		return errors.New("AUTH_TOKEN_SECRET environment variable not set")
+	case *subject == "":
+		return errors.New("-subject is required")
+	case !entity.IsValidRole(*role):
+		return fmt.Errorf("unknown role '%s'", *role)
+	case *ttl <= 0:
+		return errors.New("-ttl must be positive")
+	}
+
+	token, err := signer.Sign(auth.Claims{Subject: *subject, Role: *role}, *ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
+
+func formatTimeColumn(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
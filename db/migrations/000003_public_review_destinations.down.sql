@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_review_links_review_id;
+DROP TABLE IF EXISTS review_links;
+DROP TABLE IF EXISTS review_destinations;
+ALTER TABLE reviews DROP COLUMN IF EXISTS rating;
@@ -0,0 +1,26 @@
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS rating SMALLINT CHECK (rating BETWEEN 1 AND 5);
+
+CREATE TABLE IF NOT EXISTS review_destinations (
+    id SERIAL PRIMARY KEY,
+    business_id VARCHAR(100) NOT NULL,
+    platform VARCHAR(50) NOT NULL,
+    url_template TEXT NOT NULL,
+    priority INT NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    UNIQUE (business_id, platform)
+);
+
+CREATE TABLE IF NOT EXISTS review_links (
+    id UUID PRIMARY KEY,
+    review_id UUID NOT NULL,
+    chat_id BIGINT NOT NULL,
+    platform VARCHAR(50) NOT NULL,
+    target_url TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    click_count INT NOT NULL DEFAULT 0,
+    first_clicked_at TIMESTAMPTZ,
+    last_clicked_at TIMESTAMPTZ,
+    FOREIGN KEY (review_id) REFERENCES reviews(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_links_review_id ON review_links (review_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_conversation_transitions_chat_id;
+DROP TABLE IF EXISTS conversation_transitions;
+DROP INDEX IF EXISTS idx_conversations_state_last_interaction;
+ALTER TABLE conversations DROP COLUMN IF EXISTS reminder_sent_at;
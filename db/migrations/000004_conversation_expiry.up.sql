@@ -0,0 +1,15 @@
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_conversations_state_last_interaction ON conversations (state, last_interaction_at);
+
+CREATE TABLE IF NOT EXISTS conversation_transitions (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    from_state VARCHAR(50) NOT NULL,
+    to_state VARCHAR(50) NOT NULL,
+    reason VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (chat_id) REFERENCES conversations(chat_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_transitions_chat_id ON conversation_transitions (chat_id, created_at);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_reviews_chat_id;
+DROP INDEX IF EXISTS idx_campaign_sends_chat_id_sent_at;
+DROP TABLE IF EXISTS campaign_sends;
+DROP TABLE IF EXISTS review_campaigns;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS review_campaigns (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    message TEXT NOT NULL,
+    min_idle_hours INT NOT NULL,
+    cooldown_hours INT NOT NULL,
+    batch_size INT NOT NULL DEFAULT 50,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS campaign_sends (
+    id BIGSERIAL PRIMARY KEY,
+    campaign_id INT NOT NULL,
+    chat_id BIGINT NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (campaign_id) REFERENCES review_campaigns(id) ON DELETE CASCADE,
+    FOREIGN KEY (chat_id) REFERENCES conversations(chat_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaign_sends_chat_id_sent_at ON campaign_sends (chat_id, sent_at);
+CREATE INDEX IF NOT EXISTS idx_reviews_chat_id ON reviews (chat_id);
+
+INSERT INTO review_campaigns (name, message, min_idle_hours, cooldown_hours) VALUES
+('post-interaction', 'Hi again! Thanks for reaching out to us recently. Would you mind telling us how we did? Just reply with a short review.', 24, 720)
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS processed_messages (
+    chat_id BIGINT NOT NULL,
+    message_id VARCHAR(255) NOT NULL,
+    reply TEXT NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (chat_id, message_id),
+    FOREIGN KEY (chat_id) REFERENCES conversations(chat_id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_inbound_messages_finished_at;
+DROP INDEX IF EXISTS idx_inbound_messages_unfinished;
+DROP TABLE IF EXISTS inbound_messages;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS inbound_messages (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error TEXT,
+    enqueued_at TIMESTAMPTZ NOT NULL,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_inbound_messages_unfinished ON inbound_messages (chat_id, id) WHERE status IN ('pending', 'processing');
+CREATE INDEX IF NOT EXISTS idx_inbound_messages_finished_at ON inbound_messages (finished_at);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS channel_opt_outs (
+    channel VARCHAR(50) NOT NULL,
+    chat_id BIGINT NOT NULL,
+    opted_out_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (channel, chat_id)
+);
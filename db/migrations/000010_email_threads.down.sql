@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS email_message_ids;
+DROP TABLE IF EXISTS email_threads;
+DROP SEQUENCE IF EXISTS email_chat_id_seq;
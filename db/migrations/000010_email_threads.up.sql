@@ -0,0 +1,19 @@
+-- Email chat IDs start above any phone number (15 digits) so they never
+-- collide with SMS or WhatsApp chats, while staying below 2^53 for JSON clients.
+CREATE SEQUENCE IF NOT EXISTS email_chat_id_seq START WITH 1000000000000000;
+
+CREATE TABLE IF NOT EXISTS email_threads (
+    chat_id BIGINT PRIMARY KEY,
+    address VARCHAR(320) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    subject TEXT NOT NULL DEFAULT '',
+    last_message_id TEXT NOT NULL DEFAULT '',
+    message_references TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS email_message_ids (
+    message_id TEXT PRIMARY KEY,
+    chat_id BIGINT NOT NULL REFERENCES email_threads(chat_id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL
+);
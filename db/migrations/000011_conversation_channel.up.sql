@@ -0,0 +1,2 @@
+-- An empty channel means the default channel configured at startup.
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS channel VARCHAR(50) NOT NULL DEFAULT '';
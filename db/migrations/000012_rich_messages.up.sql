@@ -0,0 +1,2 @@
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS quick_replies JSONB;
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS buttons JSONB;
@@ -0,0 +1,9 @@
+ALTER TABLE message_history DROP COLUMN IF EXISTS message_id;
+
+DROP INDEX IF EXISTS idx_outbox_messages_external_id;
+DROP INDEX IF EXISTS idx_outbox_messages_message_id;
+
+ALTER TABLE outbox_messages DROP COLUMN IF EXISTS read_at;
+ALTER TABLE outbox_messages DROP COLUMN IF EXISTS delivered_at;
+ALTER TABLE outbox_messages DROP COLUMN IF EXISTS external_id;
+ALTER TABLE outbox_messages DROP COLUMN IF EXISTS message_id;
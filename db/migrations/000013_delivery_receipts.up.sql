@@ -0,0 +1,12 @@
+-- message_id is the public ID of an outgoing message; external_id is the ID
+-- the channel gave it, which delivery receipts refer to.
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS message_id UUID;
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMPTZ;
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS read_at TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_messages_message_id ON outbox_messages (message_id);
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_external_id ON outbox_messages (external_id) WHERE external_id IS NOT NULL;
+
+-- Links bot messages in the history to their outbox message and its delivery status.
+ALTER TABLE message_history ADD COLUMN IF NOT EXISTS message_id UUID;
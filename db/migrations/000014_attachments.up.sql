@@ -0,0 +1,18 @@
+-- Photos customers sent. review_id stays NULL until a review claims them;
+-- source identifies media downloaded from a channel.
+CREATE TABLE IF NOT EXISTS attachments (
+    id UUID PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    review_id UUID REFERENCES reviews(id) ON DELETE SET NULL,
+    file_name VARCHAR(255) NOT NULL DEFAULT '',
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    source TEXT,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_review_id ON attachments (review_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_pending ON attachments (chat_id, created_at) WHERE review_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attachments_source ON attachments (chat_id, source) WHERE source IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_reviews_customer_id;
+DROP INDEX IF EXISTS idx_reviews_rating;
+DROP INDEX IF EXISTS idx_reviews_received_at;
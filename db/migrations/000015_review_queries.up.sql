@@ -0,0 +1,4 @@
+-- Keyset pagination of GET /api/reviews orders by these columns with id as tie-breaker.
+CREATE INDEX IF NOT EXISTS idx_reviews_received_at ON reviews (received_at, id);
+CREATE INDEX IF NOT EXISTS idx_reviews_rating ON reviews ((COALESCE(rating, 0)), id);
+CREATE INDEX IF NOT EXISTS idx_reviews_customer_id ON reviews (customer_id);
@@ -0,0 +1,2 @@
+ALTER TABLE reviews DROP COLUMN IF EXISTS channel;
+ALTER TABLE reviews DROP COLUMN IF EXISTS customer_name;
@@ -0,0 +1,7 @@
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS customer_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS channel VARCHAR(50) NOT NULL DEFAULT '';
+
+-- Earlier reviews take the channel their conversation is on now.
+UPDATE reviews SET channel = conversations.channel
+FROM conversations
+WHERE conversations.chat_id = reviews.chat_id;
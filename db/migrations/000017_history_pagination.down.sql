@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_message_history_chat_id_id;
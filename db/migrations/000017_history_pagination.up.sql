@@ -0,0 +1,2 @@
+-- History pages are cut by message id, which unlike timestamps never ties.
+CREATE INDEX IF NOT EXISTS idx_message_history_chat_id_id ON message_history (chat_id, id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS web_sessions;
+DROP SEQUENCE IF EXISTS web_chat_id_seq;
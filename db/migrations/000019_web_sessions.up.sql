@@ -0,0 +1,8 @@
+-- Anonymous web chat visitors. Their chat IDs start above the email range
+-- and stay below 2^53, like email chat IDs.
+CREATE SEQUENCE IF NOT EXISTS web_chat_id_seq START WITH 2000000000000000;
+
+CREATE TABLE IF NOT EXISTS web_sessions (
+    chat_id BIGINT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,12 @@
+-- Token buckets and mutes of the shared rate limit store. Rows of full
+-- buckets and expired mutes are pruned periodically.
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key VARCHAR(100) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS rate_limit_mutes (
+    key VARCHAR(100) PRIMARY KEY,
+    muted_until TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,35 @@
+-- WhatsApp and SMS chats used the bare phone number as chat and user ID,
+-- which could collide with Telegram chat IDs and with each other. They move
+-- to the ranges starting at 3e15 (WhatsApp) and 4e15 (SMS), along with all
+-- rows referring to them.
+CREATE TEMP TABLE chat_id_moves AS
+SELECT chat_id AS old_id,
+       chat_id + CASE channel WHEN 'whatsapp' THEN 3000000000000000 ELSE 4000000000000000 END AS new_id
+FROM conversations
+WHERE channel IN ('whatsapp', 'sms') AND chat_id BETWEEN 1 AND 999999999999999;
+
+INSERT INTO conversations (chat_id, user_id, state, last_interaction_at, reminder_sent_at, channel)
+SELECT m.new_id, CASE WHEN c.user_id = m.old_id THEN m.new_id ELSE c.user_id END,
+       c.state, c.last_interaction_at, c.reminder_sent_at, c.channel
+FROM conversations c JOIN chat_id_moves m ON c.chat_id = m.old_id;
+
+UPDATE message_history t SET chat_id = m.new_id FROM chat_id_moves m WHERE t.chat_id = m.old_id;
+UPDATE conversation_transitions t SET chat_id = m.new_id FROM chat_id_moves m WHERE t.chat_id = m.old_id;
+UPDATE campaign_sends t SET chat_id = m.new_id FROM chat_id_moves m WHERE t.chat_id = m.old_id;
+UPDATE processed_messages t SET chat_id = m.new_id FROM chat_id_moves m WHERE t.chat_id = m.old_id;
+UPDATE outbox_messages t SET chat_id = m.new_id FROM chat_id_moves m WHERE t.chat_id = m.old_id;
+UPDATE review_links t SET chat_id = m.new_id FROM chat_id_moves m WHERE t.chat_id = m.old_id;
+UPDATE attachments t SET chat_id = m.new_id FROM chat_id_moves m WHERE t.chat_id = m.old_id;
+UPDATE reviews t
+SET chat_id = m.new_id, customer_id = CASE WHEN t.customer_id = m.old_id THEN m.new_id ELSE t.customer_id END
+FROM chat_id_moves m WHERE t.chat_id = m.old_id;
+UPDATE inbound_messages t
+SET chat_id = m.new_id,
+    payload = jsonb_set(jsonb_set(t.payload, '{chat_id}', to_jsonb(m.new_id)), '{user_id}', to_jsonb(m.new_id))
+FROM chat_id_moves m WHERE t.chat_id = m.old_id;
+
+DELETE FROM conversations c USING chat_id_moves m WHERE c.chat_id = m.old_id;
+DROP TABLE chat_id_moves;
+
+UPDATE channel_opt_outs SET chat_id = chat_id + 4000000000000000
+WHERE channel = 'sms' AND chat_id BETWEEN 1 AND 999999999999999;
@@ -0,0 +1,14 @@
+-- WhatsApp only delivers free-form messages within 24 hours of the customer's
+-- last message, which last_interaction_at does not track as campaigns and
+-- admins touch it too.
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS last_inbound_at TIMESTAMPTZ;
+
+UPDATE conversations c
+SET last_inbound_at = h.last_at
+FROM (
+    SELECT chat_id, max("timestamp") AS last_at
+    FROM message_history
+    WHERE is_user_message
+    GROUP BY chat_id
+) h
+WHERE c.chat_id = h.chat_id;
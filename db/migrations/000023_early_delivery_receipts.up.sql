@@ -0,0 +1,13 @@
+-- Receipts can arrive before the dispatcher committed the external ID of the
+-- message they refer to. They wait here until it did.
+CREATE TABLE IF NOT EXISTS early_delivery_receipts (
+    external_id VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    chat_id BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    at TIMESTAMPTZ NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (external_id, status)
+);
+
+CREATE INDEX IF NOT EXISTS idx_early_delivery_receipts_received_at ON early_delivery_receipts (received_at);
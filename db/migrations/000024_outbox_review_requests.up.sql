@@ -0,0 +1,3 @@
+-- WhatsApp replaces review requests with an approved template outside the
+-- customer service window; other messages are not sent there.
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS review_request BOOLEAN NOT NULL DEFAULT false;
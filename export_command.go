@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"smb-chatbot/internal/gateway/export"
+	"smb-chatbot/internal/usecase"
+)
+
+// runExportReviews implements the export-reviews command, which writes the
+// reviews matching the flags to a file or stdout without starting the server.
+func runExportReviews(ctx context.Context, reviews usecase.ReviewReader, args []string) error {
+	flags := flag.NewFlagSet("export-reviews", flag.ContinueOnError)
+	formatName := flags.String("format", "csv", "export format: csv, ndjson or xlsx")
+	output := flags.String("o", "", "output file (default stdout)")
+	from := flags.String("from", "", "only reviews received on or after this date (2024-05-01 or RFC 3339)")
+	to := flags.String("to", "", "only reviews received before this time, or on this date if it is a plain date")
+	var input usecase.ListReviewsInput
+	flags.Int64Var(&input.Filter.ChatID, "chat-id", 0, "only reviews of this chat")
+	flags.Int64Var(&input.Filter.CustomerID, "customer-id", 0, "only reviews of this customer")
+	flags.IntVar(&input.Filter.MinRating, "min-rating", 0, "minimum rating")
+	flags.IntVar(&input.Filter.MaxRating, "max-rating", 0, "maximum rating")
+	flags.StringVar(&input.Sentiment, "sentiment", "", "positive, neutral or negative")
+	flags.StringVar(&input.Filter.Search, "q", "", "only reviews containing this text")
+	flags.StringVar(&input.Sort, "sort", "", "-received_at (default), received_at, -rating or rating")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	format, err := export.LookupFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	if input.Filter.From, err = parseDateFlag("from", *from, false); err != nil {
+		return err
+	}
+	if input.Filter.To, err = parseDateFlag("to", *to, true); err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *output, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	buffered := bufio.NewWriter(out)
+
+	count, err := reviews.ExportReviews(ctx, input, format.NewWriter(buffered))
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		if *output != "" {
+			os.Remove(*output)
+		}
+		return err
+	}
+	if *output != "" {
+		log.Printf("Exported %d reviews to %s", count, *output)
+	}
+	return nil
+}
+
+func parseDateFlag(name, v string, endOfDay bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("-%s must be a date like 2024-05-01 or an RFC 3339 timestamp", name)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
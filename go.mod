@@ -5,6 +5,7 @@ go 1.24.1
 require (
 	github.com/google/uuid v1.6.0
 	github.com/jackc/pgx/v4 v4.18.3
+	github.com/rs/cors v1.11.1
 	github.com/sashabaranov/go-openai v1.38.1
 )
 
@@ -13,7 +14,6 @@ require (
 	github.com/golang-migrate/migrate v3.5.4+incompatible // indirect
 	github.com/lib/pq v1.10.2 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/rs/cors v1.11.1 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
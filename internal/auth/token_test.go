@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenSignerRoundTrip(t *testing.T) {
+	signer := NewTokenSigner("secret")
+	token, err := signer.Sign(Claims{Subject: "web-session", ChatID: 42, UserID: 7, Role: "agent"}, time.Hour)
+	require.NoError(t, err)
+
+	claims, err := signer.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "web-session", claims.Subject)
+	assert.Equal(t, int64(42), claims.ChatID)
+	assert.Equal(t, int64(7), claims.UserID)
+	assert.Equal(t, "agent", claims.Role)
+	assert.Equal(t, claims.IssuedAt+int64(time.Hour.Seconds()), claims.ExpiresAt)
+}
+
+func TestTokenSignerRejectsTamperedTokens(t *testing.T) {
+	signer := NewTokenSigner("secret")
+	token, err := signer.Sign(Claims{ChatID: 42, UserID: 7}, time.Hour)
+	require.NoError(t, err)
+	parts := strings.Split(token, ".")
+
+	// forgedPayload swaps in claims for another chat, keeping the signature.
+	forgedPayload := base64.RawURLEncoding.EncodeToString([]byte(`{"chat_id":43,"user_id":7,"iat":0,"exp":9999999999}`))
+	noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	otherSigner, err := NewTokenSigner("other-secret").Sign(Claims{ChatID: 42, UserID: 7}, time.Hour)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "empty", token: ""},
+		{name: "two parts", token: parts[0] + "." + parts[1]},
+		{name: "four parts", token: token + ".x"},
+		{name: "forged payload", token: parts[0] + "." + forgedPayload + "." + parts[2]},
+		{name: "alg none", token: noneHeader + "." + parts[1] + "."},
+		{name: "alg none with signature", token: noneHeader + "." + parts[1] + "." + parts[2]},
+		{name: "altered signature", token: parts[0] + "." + parts[1] + "." + strings.ToUpper(parts[2])},
+		{name: "missing signature", token: parts[0] + "." + parts[1] + "."},
+		{name: "signed with another secret", token: otherSigner},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := signer.Verify(tt.token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestTokenSignerExpiry(t *testing.T) {
+	signer := NewTokenSigner("secret")
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		wantErr error
+	}{
+		{name: "valid", ttl: time.Minute},
+		{name: "expired", ttl: -time.Second, wantErr: ErrTokenExpired},
+		{name: "expires now", ttl: 0, wantErr: ErrTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := signer.Sign(Claims{ChatID: 42}, tt.ttl)
+			require.NoError(t, err)
+			_, err = signer.Verify(token)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
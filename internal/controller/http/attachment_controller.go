@@ -0,0 +1,204 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"smb-chatbot/internal/auth"
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+// attachmentSubjectPrefix marks tokens that grant access to one attachment,
+// as embedded in signed attachment URLs.
+const attachmentSubjectPrefix = "attachment:"
+
+type AttachmentController struct {
+	attachments usecase.AttachmentService
+	signer      *auth.TokenSigner
+	links       *attachmentLinker
+	maxBytes    int64
+	limiter     usecase.RateLimiter
+}
+
+// NewAttachmentController creates the photo upload and download handlers.
+// Signed attachment URLs are valid for urlTTL. Uploads count against the
+// message limits of rl; a nil rl admits every upload.
+func NewAttachmentController(as usecase.AttachmentService, signer *auth.TokenSigner, urlTTL time.Duration, maxBytes int64, rl usecase.RateLimiter) *AttachmentController {
+	return &AttachmentController{
+		attachments: as,
+		signer:      signer,
+		links:       newAttachmentLinker(signer, urlTTL),
+		maxBytes:    maxBytes,
+		limiter:     rl,
+	}
+}
+
+type attachmentResponse struct {
+	entity.Attachment
+	URL          string `json:"url,omitempty"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+}
+
+// handleUpload stores the "file" field of a multipart upload for the chat of
+// the caller's web chat token. The returned ID goes into attachment_ids of
+// the next message.
+func (h *AttachmentController) handleUpload(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.signer.Verify(bearerToken(r))
+	if err != nil || claims.ChatID == 0 {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "Invalid or expired token")
+		return
+	}
+	if !allowMessage(w, r, h.limiter, claims.ChatID, claims.UserID) {
+		return
+	}
+
+	// Leave room for the multipart framing around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBytes+64<<10)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, codePayloadTooLarge, "File is too large")
+			return
+		}
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Expected a multipart upload with a 'file' field")
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.attachments.Upload(r.Context(), claims.ChatID, header.Filename, "", file)
+	switch {
+	case errors.Is(err, usecase.ErrAttachmentTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, codePayloadTooLarge, "File is too large")
+		return
+	case errors.Is(err, usecase.ErrUnsupportedAttachment):
+		writeError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Only JPEG, PNG and GIF images are accepted")
+		return
+	case errors.Is(err, usecase.ErrTooManyPendingAttachments):
+		writeError(w, http.StatusTooManyRequests, codeRateLimited, "Too many photos waiting for a message, send one first")
+		return
+	case err != nil:
+		log.Printf("ERROR: Failed to store upload for chat %d: %v", claims.ChatID, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to store file")
+		return
+	}
+
+	response, err := h.links.link(*attachment)
+	if err != nil {
+		log.Printf("ERROR: Failed to sign URLs of attachment %s: %v", attachment.ID, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to store file")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("ERROR: Failed to encode attachment response: %v", err)
+	}
+}
+
+func (h *AttachmentController) handleGet(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, false)
+}
+
+func (h *AttachmentController) handleGetThumbnail(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, true)
+}
+
+// serve streams an attachment to callers holding either a signed URL for it
+// or a web chat token of the chat it belongs to.
+func (h *AttachmentController) serve(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	id := r.PathValue("attachment_id")
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		token = bearerToken(r)
+	}
+	claims, err := h.signer.Verify(token)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	// Check the token before looking the attachment up: a signed URL grants
+	// its own attachment, a web chat token those of its chat.
+	var chatID int64
+	switch {
+	case claims.Subject == attachmentSubjectPrefix+id:
+	case claims.ChatID != 0:
+		chatID = claims.ChatID
+	default:
+		writeError(w, http.StatusNotFound, codeNotFound, "Attachment not found")
+		return
+	}
+
+	// Attachments of other chats are not found rather than forbidden, so
+	// their IDs are not confirmed.
+	attachment, body, err := h.attachments.Open(r.Context(), chatID, id, thumbnail)
+	if errors.Is(err, usecase.ErrAttachmentNotFound) {
+		writeError(w, http.StatusNotFound, codeNotFound, "Attachment not found")
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to open attachment %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to load attachment")
+		return
+	}
+	defer body.Close()
+
+	contentType := attachment.ContentType
+	if thumbnail {
+		contentType = "image/jpeg"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if attachment.FileName != "" && !thumbnail {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.FileName}))
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("HANDLER: Failed to stream attachment %s: %v", id, err)
+	}
+}
+
+// attachmentLinker adds signed URLs to attachments, which work in <img> tags
+// without further auth.
+type attachmentLinker struct {
+	signer *auth.TokenSigner
+	ttl    time.Duration
+}
+
+// newAttachmentLinker returns nil if signer is nil, which leaves the URLs
+// of attachments empty.
+func newAttachmentLinker(signer *auth.TokenSigner, ttl time.Duration) *attachmentLinker {
+	if signer == nil {
+		return nil
+	}
+	return &attachmentLinker{signer: signer, ttl: ttl}
+}
+
+func (l *attachmentLinker) link(attachment entity.Attachment) (attachmentResponse, error) {
+	if l == nil {
+		return attachmentResponse{Attachment: attachment}, nil
+	}
+	token, err := l.signer.Sign(auth.Claims{Subject: attachmentSubjectPrefix + attachment.ID}, l.ttl)
+	if err != nil {
+		return attachmentResponse{}, err
+	}
+	url := "/api/v1/attachments/" + attachment.ID
+	return attachmentResponse{
+		Attachment:   attachment,
+		URL:          url + "?token=" + token,
+		ThumbnailURL: url + "/thumbnail?token=" + token,
+	}, nil
+}
+
+func bearerToken(r *http.Request) string {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token
+}
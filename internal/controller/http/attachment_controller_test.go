@@ -0,0 +1,142 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/auth"
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+const testAttachmentID = "0b6a3c1e-6c3e-4a57-9d43-3f1d1c2f8a10"
+
+// recordingAttachments holds one photo of chat 7 and records the calls.
+type recordingAttachments struct {
+	usecase.AttachmentService
+	uploads   int
+	uploadErr error
+	opened    []int64
+}
+
+func (a *recordingAttachments) Upload(_ context.Context, chatID int64, fileName, _ string, _ io.Reader) (*entity.Attachment, error) {
+	a.uploads++
+	if a.uploadErr != nil {
+		return nil, a.uploadErr
+	}
+	return &entity.Attachment{ID: testAttachmentID, ChatID: chatID, FileName: fileName, ContentType: "image/png"}, nil
+}
+
+func (a *recordingAttachments) Open(_ context.Context, chatID int64, id string, _ bool) (*entity.Attachment, io.ReadCloser, error) {
+	a.opened = append(a.opened, chatID)
+	if id != testAttachmentID || (chatID != 0 && chatID != 7) {
+		return nil, nil, usecase.ErrAttachmentNotFound
+	}
+	return &entity.Attachment{ID: id, ChatID: 7, ContentType: "image/png"}, io.NopCloser(bytes.NewReader([]byte("png"))), nil
+}
+
+// exhaustedLimiter rejects every message and request.
+type exhaustedLimiter struct {
+	usecase.RateLimiter
+}
+
+func (exhaustedLimiter) Allow(context.Context, int64, int64, string) (time.Duration, error) {
+	return time.Second, usecase.ErrRateLimited
+}
+
+func (exhaustedLimiter) AllowIP(context.Context, string) (time.Duration, error) {
+	return time.Second, usecase.ErrRateLimited
+}
+
+func uploadRequest(t *testing.T, token string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "photo.png")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("png"))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/attachments", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestAttachmentUpload(t *testing.T) {
+	signer := auth.NewTokenSigner("secret")
+	chatToken, err := signer.Sign(auth.Claims{ChatID: 7, UserID: 9}, time.Minute)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		limiter     usecase.RateLimiter
+		uploadErr   error
+		wantStatus  int
+		wantUploads int
+	}{
+		{name: "stored", wantStatus: http.StatusCreated, wantUploads: 1},
+		{name: "rate limited", limiter: exhaustedLimiter{}, wantStatus: http.StatusTooManyRequests},
+		{name: "too many pending photos", uploadErr: usecase.ErrTooManyPendingAttachments, wantStatus: http.StatusTooManyRequests, wantUploads: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachments := &recordingAttachments{uploadErr: tt.uploadErr}
+			h := NewAttachmentController(attachments, signer, time.Hour, 1<<20, tt.limiter)
+			rec := httptest.NewRecorder()
+			h.handleUpload(rec, uploadRequest(t, chatToken))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantUploads, attachments.uploads)
+		})
+	}
+}
+
+func TestAttachmentServeChecksTokenFirst(t *testing.T) {
+	signer := auth.NewTokenSigner("secret")
+	sign := func(claims auth.Claims) string {
+		token, err := signer.Sign(claims, time.Minute)
+		require.NoError(t, err)
+		return token
+	}
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+		// wantOpened are the chat IDs Open was called with.
+		wantOpened []int64
+	}{
+		{name: "signed URL", token: sign(auth.Claims{Subject: attachmentSubjectPrefix + testAttachmentID}), wantStatus: http.StatusOK, wantOpened: []int64{0}},
+		{name: "web chat token of the chat", token: sign(auth.Claims{ChatID: 7}), wantStatus: http.StatusOK, wantOpened: []int64{7}},
+		{name: "web chat token of another chat", token: sign(auth.Claims{ChatID: 8}), wantStatus: http.StatusNotFound, wantOpened: []int64{8}},
+		{name: "signed URL of another attachment", token: sign(auth.Claims{Subject: attachmentSubjectPrefix + "other"}), wantStatus: http.StatusNotFound},
+		{name: "token without chat", token: sign(auth.Claims{Subject: "admin"}), wantStatus: http.StatusNotFound},
+		{name: "invalid token", token: "nope", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachments := &recordingAttachments{}
+			h := NewAttachmentController(attachments, signer, time.Hour, 1<<20, nil)
+			req := httptest.NewRequest(http.MethodGet, "/api/attachments/"+testAttachmentID+"?token="+tt.token, nil)
+			req.SetPathValue("attachment_id", testAttachmentID)
+			rec := httptest.NewRecorder()
+			h.handleGet(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantOpened, attachments.opened)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, "png", rec.Body.String())
+			}
+		})
+	}
+}
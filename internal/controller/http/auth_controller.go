@@ -0,0 +1,136 @@
+package http
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"smb-chatbot/internal/auth"
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+const (
+	defaultDashboardTokenTTL = 12 * time.Hour
+	maxDashboardTokenTTL     = 30 * 24 * time.Hour
+)
+
+// AuthController lets owners manage API keys and issue dashboard tokens.
+type AuthController struct {
+	keys   usecase.APIKeyService
+	signer *auth.TokenSigner
+}
+
+// NewAuthController creates the key and token handlers. Dashboard tokens
+// cannot be issued without signer.
+func NewAuthController(ks usecase.APIKeyService, signer *auth.TokenSigner) *AuthController {
+	return &AuthController{keys: ks, signer: signer}
+}
+
+type createAPIKeyRequest struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type createAPIKeyResponse struct {
+	entity.APIKey
+	// Key is only returned on creation.
+	Key string `json:"key"`
+}
+
+type apiKeyListResponse struct {
+	APIKeys []entity.APIKey `json:"api_keys"`
+}
+
+type issueTokenRequest struct {
+	Subject string `json:"subject"`
+	Role    string `json:"role"`
+	// TTL is a duration like "12h".
+	TTL string `json:"ttl"`
+}
+
+type issueTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (h *AuthController) handleListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.keys.List(r.Context())
+	if err != nil {
+		log.Printf("ERROR: Failed to list API keys: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to retrieve API keys")
+		return
+	}
+	writeJSON(w, http.StatusOK, apiKeyListResponse{APIKeys: keys})
+}
+
+func (h *AuthController) handleCreateKey(w http.ResponseWriter, r *http.Request) {
+	var req createAPIKeyRequest
+	if !decodeJSON(w, r, 1<<10, &req) {
+		return
+	}
+	key, secret, err := h.keys.Create(r.Context(), req.Name, req.Role)
+	if errors.Is(err, usecase.ErrInvalidAPIKey) {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to create API key: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to create API key")
+		return
+	}
+	writeJSON(w, http.StatusCreated, createAPIKeyResponse{APIKey: *key, Key: secret})
+}
+
+func (h *AuthController) handleRevokeKey(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("key_id")
+	err := h.keys.Revoke(r.Context(), id)
+	if errors.Is(err, usecase.ErrAPIKeyNotFound) {
+		writeError(w, http.StatusNotFound, codeNotFound, "API key not found or already revoked")
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to revoke API key %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to revoke API key")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleIssueToken signs a dashboard token for a user with a role.
+func (h *AuthController) handleIssueToken(w http.ResponseWriter, r *http.Request) {
+	if h.signer == nil {
+		writeError(w, http.StatusNotFound, codeNotFound, "Dashboard tokens need AUTH_TOKEN_SECRET")
+		return
+	}
+	var req issueTokenRequest
+	if !decodeJSON(w, r, 1<<10, &req) {
+		return
+	}
+	ttl := defaultDashboardTokenTTL
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 || ttl > maxDashboardTokenTTL {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "ttl must be a duration like '12h', at most 720h")
+			return
+		}
+	}
+	switch {
+	case req.Subject == "" || len(req.Subject) > 255:
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "subject must have 1 to 255 characters")
+		return
+	case !entity.IsValidRole(req.Role):
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "role must be 'owner', 'agent' or 'integration'")
+		return
+	}
+
+	token, err := h.signer.Sign(auth.Claims{Subject: req.Subject, Role: req.Role}, ttl)
+	if err != nil {
+		log.Printf("ERROR: Failed to sign dashboard token for '%s': %v", req.Subject, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to issue token")
+		return
+	}
+	log.Printf("HANDLER: Issued %s dashboard token for '%s', valid for %s", req.Role, req.Subject, ttl)
+	writeJSON(w, http.StatusOK, issueTokenResponse{Token: token, ExpiresAt: time.Now().Add(ttl)})
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"smb-chatbot/internal/auth"
+	"smb-chatbot/internal/usecase"
+)
+
+// principal is the authenticated caller of a request. Web chat tokens yield
+// a principal without role, bound to the chat in ChatID.
+type principal struct {
+	Role    string
+	Subject string
+	ChatID  int64
+	UserID  int64
+}
+
+type principalKey struct{}
+
+// principalFrom returns the caller authenticated by the Authenticator.
+func principalFrom(ctx context.Context) (principal, bool) {
+	caller, ok := ctx.Value(principalKey{}).(principal)
+	return caller, ok
+}
+
+// Authenticator admits requests carrying an API key, in the X-API-Key header
+// or as bearer token, or a bearer token signed by signer: a dashboard token
+// with a role, or a web chat token on chat-scoped routes.
+type Authenticator struct {
+	keys   usecase.APIKeyService
+	signer *auth.TokenSigner
+	// public admits requests without or with invalid credentials, and
+	// only uses valid tokens to tell whose chat a request is for.
+	public bool
+}
+
+// NewAuthenticator makes all routes public if ks is nil. Without signer only
+// API keys are accepted.
+func NewAuthenticator(ks usecase.APIKeyService, signer *auth.TokenSigner) *Authenticator {
+	return &Authenticator{keys: ks, signer: signer, public: ks == nil}
+}
+
+// allow admits callers with one of roles.
+func (a *Authenticator) allow(handler http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return a.wrap(handler, false, roles)
+}
+
+// allowChat also admits web chat tokens. The handler must restrict them to
+// their chat with canAccessChat.
+func (a *Authenticator) allowChat(handler http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return a.wrap(handler, true, roles)
+}
+
+func (a *Authenticator) wrap(handler http.HandlerFunc, chatTokens bool, roles []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller, err := a.authenticate(r)
+		if a.public {
+			if err == nil {
+				r = r.WithContext(context.WithValue(r.Context(), principalKey{}, caller))
+			}
+			handler(w, r)
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, auth.ErrInvalidToken) && !errors.Is(err, auth.ErrTokenExpired) && !errors.Is(err, usecase.ErrAPIKeyNotFound) {
+				log.Printf("ERROR: Failed to authenticate %s %s: %v", r.Method, r.URL.Path, err)
+				writeError(w, http.StatusInternalServerError, codeInternal, "Failed to authenticate request")
+				return
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "Missing, invalid or expired credentials")
+			return
+		}
+
+		allowed := slices.Contains(roles, caller.Role)
+		if caller.Role == "" {
+			allowed = chatTokens && caller.ChatID != 0
+		}
+		if !allowed {
+			log.Printf("HANDLER: Denied %s %s to '%s' (role '%s')", r.Method, r.URL.Path, caller.Subject, caller.Role)
+			writeError(w, http.StatusForbidden, codeForbidden, "Not allowed for this role")
+			return
+		}
+		handler(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, caller)))
+	}
+}
+
+func (a *Authenticator) authenticate(r *http.Request) (principal, error) {
+	credential := r.Header.Get("X-API-Key")
+	if credential == "" {
+		credential = bearerToken(r)
+	}
+	if credential == "" {
+		return principal{}, auth.ErrInvalidToken
+	}
+
+	if auth.IsAPIKey(credential) {
+		if a.keys == nil {
+			return principal{}, usecase.ErrAPIKeyNotFound
+		}
+		key, err := a.keys.Authenticate(r.Context(), credential)
+		if err != nil {
+			return principal{}, err
+		}
+		return principal{Role: key.Role, Subject: "api_key:" + key.ID}, nil
+	}
+
+	if a.signer == nil {
+		return principal{}, auth.ErrInvalidToken
+	}
+	claims, err := a.signer.Verify(credential)
+	if err != nil {
+		return principal{}, err
+	}
+	if claims.Role == "" && claims.ChatID == 0 {
+		// E.g. the token of a signed attachment URL.
+		return principal{}, auth.ErrInvalidToken
+	}
+	return principal{Role: claims.Role, Subject: claims.Subject, ChatID: claims.ChatID, UserID: claims.UserID}, nil
+}
+
+// canAccessChat reports whether the caller may act in the chat as userID,
+// or just read it if userID is 0. Only web chat tokens are restricted.
+func canAccessChat(r *http.Request, chatID, userID int64) bool {
+	tokenChatID, tokenUserID, ok := webChatIdentity(r)
+	if !ok {
+		return true
+	}
+	return tokenChatID == chatID && (userID == 0 || tokenUserID == userID)
+}
+
+// webChatIdentity returns the chat and user of the caller's web chat token,
+// if the request was made with one.
+func webChatIdentity(r *http.Request) (chatID, userID int64, ok bool) {
+	caller, ok := principalFrom(r.Context())
+	if !ok || caller.Role != "" || caller.ChatID == 0 {
+		return 0, 0, false
+	}
+	return caller.ChatID, caller.UserID, true
+}
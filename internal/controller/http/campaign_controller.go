@@ -0,0 +1,33 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"smb-chatbot/internal/usecase"
+)
+
+type CampaignController struct {
+	scheduler usecase.CampaignScheduler
+}
+
+func NewCampaignController(cs usecase.CampaignScheduler) *CampaignController {
+	return &CampaignController{scheduler: cs}
+}
+
+func (h *CampaignController) handleGetStats(w http.ResponseWriter, r *http.Request) {
+	log.Println("HANDLER: Received GET /api/campaigns/stats request")
+
+	stats, err := h.scheduler.Stats(r.Context())
+	if err != nil {
+		log.Printf("ERROR: Failed to get campaign stats: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to retrieve campaign statistics")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Printf("Failed to encode campaign stats response: %v", err)
+	}
+}
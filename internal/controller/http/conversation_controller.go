@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"smb-chatbot/internal/usecase"
+)
+
+type ConversationController struct {
+	admin usecase.ConversationAdmin
+}
+
+func NewConversationController(ca usecase.ConversationAdmin) *ConversationController {
+	return &ConversationController{admin: ca}
+}
+
+type setStateRequest struct {
+	State string `json:"state"`
+}
+
+func (h *ConversationController) handleList(w http.ResponseWriter, r *http.Request) {
+	log.Printf("HANDLER: Received GET /api/conversations request (%s)", r.URL.RawQuery)
+
+	query := r.URL.Query()
+	input := usecase.ListConversationsInput{
+		State:  query.Get("state"),
+		Cursor: query.Get("cursor"),
+	}
+	var err error
+	if v := query.Get("idle_for"); v != "" {
+		if input.IdleFor, err = time.ParseDuration(v); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "idle_for must be a duration like '30m' or '24h'")
+			return
+		}
+	}
+	if v := query.Get("user_id"); v != "" {
+		if input.UserID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "user_id must be a number")
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if input.Limit, err = strconv.Atoi(v); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "limit must be a number")
+			return
+		}
+	}
+
+	page, err := h.admin.ListConversations(r.Context(), input)
+	if errors.Is(err, usecase.ErrInvalidConversationQuery) {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to list conversations: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to retrieve conversations")
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (h *ConversationController) handleGet(w http.ResponseWriter, r *http.Request) {
+	chatID, ok := chatIDParam(w, r)
+	if !ok {
+		return
+	}
+	details, err := h.admin.GetConversation(r.Context(), chatID)
+	if err != nil {
+		h.handleError(w, chatID, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, details)
+}
+
+func (h *ConversationController) handleSetState(w http.ResponseWriter, r *http.Request) {
+	chatID, ok := chatIDParam(w, r)
+	if !ok {
+		return
+	}
+	var req setStateRequest
+	if !decodeJSON(w, r, 1<<10, &req) {
+		return
+	}
+	if req.State == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Required field: state (string)")
+		return
+	}
+
+	conversation, err := h.admin.SetState(r.Context(), chatID, req.State)
+	if err != nil {
+		h.handleError(w, chatID, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, conversation)
+}
+
+func (h *ConversationController) handleClose(w http.ResponseWriter, r *http.Request) {
+	chatID, ok := chatIDParam(w, r)
+	if !ok {
+		return
+	}
+	conversation, err := h.admin.Close(r.Context(), chatID)
+	if err != nil {
+		h.handleError(w, chatID, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, conversation)
+}
+
+func (h *ConversationController) handleDelete(w http.ResponseWriter, r *http.Request) {
+	chatID, ok := chatIDParam(w, r)
+	if !ok {
+		return
+	}
+	if err := h.admin.Delete(r.Context(), chatID); err != nil {
+		h.handleError(w, chatID, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ConversationController) handleError(w http.ResponseWriter, chatID int64, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrConversationNotFound):
+		writeError(w, http.StatusNotFound, codeNotFound, "Conversation not found")
+	case errors.Is(err, usecase.ErrInvalidConversationQuery):
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+	case errors.Is(err, usecase.ErrChatBusy):
+		writeChatBusy(w)
+	default:
+		log.Printf("ERROR: Conversation request for chat %d failed: %v", chatID, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal server error")
+	}
+}
+
+// chatIDParam reads the {chat_id} path segment, answering 400 if it is not
+// a chat ID.
+func chatIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	chatID, err := strconv.ParseInt(r.PathValue("chat_id"), 10, 64)
+	if err != nil || chatID == 0 {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid chat_id in URL path")
+		return 0, false
+	}
+	return chatID, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("ERROR: Failed to encode response payload: %v", err)
+	}
+}
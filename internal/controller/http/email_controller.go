@@ -0,0 +1,126 @@
+package http
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	gwMessenger "smb-chatbot/internal/gateway/messenger"
+	"smb-chatbot/internal/usecase"
+)
+
+const maxInboundEmailSize = 10 << 20
+
+type EmailController struct {
+	inbound     usecase.InboundService
+	threader    usecase.EmailThreader
+	attachments usecase.AttachmentService
+	secret      string
+}
+
+// NewEmailController creates the inbound email handler. as stores image
+// parts as photos; it may be nil when attachments are disabled.
+func NewEmailController(is usecase.InboundService, et usecase.EmailThreader, as usecase.AttachmentService, secret string) *EmailController {
+	return &EmailController{
+		inbound:     is,
+		threader:    et,
+		attachments: as,
+		secret:      secret,
+	}
+}
+
+// handleWebhook accepts a raw MIME message, either as the request body or as
+// the "email" field of a form post, which is how inbound parse services
+// forward raw mail.
+func (h *EmailController) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(h.secret)) != 1 {
+		log.Println("HANDLER: Rejected email webhook request with invalid token")
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	raw, err := readRawEmail(w, r)
+	if err != nil {
+		http.Error(w, "Failed to read email", http.StatusBadRequest)
+		return
+	}
+
+	email, err := gwMessenger.ParseInboundEmail(bytes.NewReader(raw))
+	if errors.Is(err, gwMessenger.ErrAutoReply) {
+		log.Println("HANDLER: Ignoring automatic email reply")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err != nil {
+		log.Printf("HANDLER: Rejected unparseable email: %v", err)
+		http.Error(w, "Invalid email", http.StatusBadRequest)
+		return
+	}
+	if h.attachments == nil {
+		email.Images = nil
+	}
+	if email.Text == "" && len(email.Images) == 0 {
+		log.Printf("HANDLER: Ignoring email %s without new text", email.MessageID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	input, err := h.threader.ToInput(r.Context(), email)
+	if err != nil {
+		log.Printf("ERROR: Failed to thread email %s: %v", email.MessageID, err)
+		http.Error(w, "Failed to process email", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("HANDLER: Received email %s for chat %d", email.MessageID, input.ChatID)
+
+	for i, image := range email.Images {
+		// Redelivered emails must not store their images twice.
+		source := fmt.Sprintf("email:%s:%d", email.MessageID, i)
+		attachment, err := h.attachments.Upload(r.Context(), input.ChatID, image.FileName, source, bytes.NewReader(image.Data))
+		if errors.Is(err, usecase.ErrAttachmentTooLarge) || errors.Is(err, usecase.ErrUnsupportedAttachment) {
+			log.Printf("HANDLER: Skipping image '%s' of email %s: %v", image.FileName, email.MessageID, err)
+			continue
+		}
+		if err != nil {
+			log.Printf("ERROR: Failed to store image of email %s: %v", email.MessageID, err)
+			http.Error(w, "Failed to process email", http.StatusInternalServerError)
+			return
+		}
+		input.AttachmentIDs = append(input.AttachmentIDs, attachment.ID)
+	}
+
+	if _, err := h.inbound.Submit(r.Context(), input); err != nil {
+		log.Printf("ERROR: Failed to handle email %s for chat %d: %v", email.MessageID, input.ChatID, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, usecase.ErrChatBusy) {
+			status = http.StatusTooManyRequests
+		}
+		http.Error(w, "Failed to process email", status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func readRawEmail(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxInboundEmailSize)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(maxInboundEmailSize); err != nil {
+			return nil, err
+		}
+		return []byte(r.FormValue("email")), nil
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		return []byte(r.PostFormValue("email")), nil
+	default:
+		return io.ReadAll(r.Body)
+	}
+}
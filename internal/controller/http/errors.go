@@ -0,0 +1,111 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error codes of the JSON error envelope. Clients branch on the code; the
+// message is meant for people and may change.
+const (
+	codeInvalidRequest       = "invalid_request"
+	codeInvalidJSON          = "invalid_json"
+	codeUnknownField         = "unknown_field"
+	codePayloadTooLarge      = "payload_too_large"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeUnauthorized         = "unauthorized"
+	codeForbidden            = "forbidden"
+	codeNotFound             = "not_found"
+	codeChatBusy             = "chat_busy"
+	codeChannelMismatch      = "channel_mismatch"
+	codeRateLimited          = "rate_limited"
+	codeInternal             = "internal_error"
+)
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeError answers with the JSON error envelope
+// {"error":{"code":"...","message":"..."}}.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	writeJSON(w, status, errorResponse{Error: errorBody{Code: code, Message: message}})
+}
+
+// writeChatBusy answers a request that lost the race for the chat lock.
+func writeChatBusy(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	writeError(w, http.StatusConflict, codeChatBusy, "Chat is busy processing a previous message, please retry")
+}
+
+// writeRateLimited answers a request rejected by the rate limits. Clients
+// may retry after retryAfter, rounded up to whole seconds.
+func writeRateLimited(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+	writeError(w, http.StatusTooManyRequests, codeRateLimited, message)
+}
+
+// handleNotFound answers requests to unknown API paths.
+func handleNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, codeNotFound, "No endpoint "+r.Method+" "+r.URL.Path)
+}
+
+// decodeJSON reads a JSON request body of at most maxBytes into v. Unknown
+// fields, trailing data and other content types than JSON are rejected. On
+// failure it answers the request and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, maxBytes int64, v any) bool {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Content-Type must be application/json")
+			return false
+		}
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err == nil {
+		if decoder.Decode(&struct{}{}) != io.EOF {
+			writeError(w, http.StatusBadRequest, codeInvalidJSON, "Request body must hold a single JSON object")
+			return false
+		}
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeError(w, http.StatusRequestEntityTooLarge, codePayloadTooLarge, fmt.Sprintf("Request body must be at most %d bytes", maxBytes))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields.
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		writeError(w, http.StatusBadRequest, codeUnknownField, "Unknown field "+field)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, fmt.Sprintf("Field '%s' must be of type %s", typeErr.Field, typeErr.Type))
+	case errors.Is(err, io.EOF):
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, "Request body is empty")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, "Request body is not valid JSON")
+	default:
+		log.Printf("HANDLER: Rejected request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, "Request body must be a JSON object")
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readError decodes the error envelope of rec and checks its headers.
+func readError(t *testing.T, rec *httptest.ResponseRecorder) errorBody {
+	t.Helper()
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+
+	var envelope map[string]map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &envelope))
+	require.Len(t, envelope, 1, "envelope holds more than the error")
+	require.Len(t, envelope["error"], 2, "error holds more than code and message")
+	return errorBody{Code: envelope["error"]["code"], Message: envelope["error"]["message"]}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type request struct {
+		ChatID int64  `json:"chat_id"`
+		Text   string `json:"text"`
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        request
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{name: "valid", contentType: "application/json", body: `{"chat_id": 7, "text": "hi"}`, want: request{ChatID: 7, Text: "hi"}},
+		{name: "content type with charset", contentType: "application/json; charset=utf-8", body: `{"chat_id": 7}`, want: request{ChatID: 7}},
+		{name: "no content type", body: `{"text": "hi"}`, want: request{Text: "hi"}},
+		{name: "trailing whitespace", body: "{\"chat_id\": 7}\n", want: request{ChatID: 7}},
+		{
+			name: "form content type", contentType: "application/x-www-form-urlencoded", body: `{"chat_id": 7}`,
+			wantStatus: http.StatusUnsupportedMediaType, wantCode: codeUnsupportedMediaType,
+		},
+		{
+			name: "unknown field", body: `{"chat_id": 7, "chatid": 8}`,
+			wantStatus: http.StatusBadRequest, wantCode: codeUnknownField, wantMessage: `Unknown field "chatid"`,
+		},
+		{
+			name: "trailing object", body: `{"chat_id": 7}{"chat_id": 8}`,
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidJSON, wantMessage: "Request body must hold a single JSON object",
+		},
+		{
+			name: "trailing garbage", body: `{"chat_id": 7} x`,
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidJSON, wantMessage: "Request body must hold a single JSON object",
+		},
+		{
+			name: "wrong type", body: `{"chat_id": "7"}`,
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidJSON, wantMessage: "Field 'chat_id' must be of type int64",
+		},
+		{
+			name:       "empty body",
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidJSON, wantMessage: "Request body is empty",
+		},
+		{
+			name: "syntax error", body: `{"chat_id": 7,}`,
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidJSON, wantMessage: "Request body is not valid JSON",
+		},
+		{
+			name: "cut off", body: `{"chat_id": 7`,
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidJSON, wantMessage: "Request body is not valid JSON",
+		},
+		{
+			name: "not an object", body: `[1, 2]`,
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidJSON, wantMessage: "Request body must be a JSON object",
+		},
+		{
+			name: "over the size limit", body: `{"text": "` + strings.Repeat("a", 64) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge, wantCode: codePayloadTooLarge, wantMessage: "Request body must be at most 64 bytes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/message", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+
+			var got request
+			ok := decodeJSON(rec, req, 64, &got)
+			if tt.wantStatus == 0 {
+				require.True(t, ok, rec.Body.String())
+				assert.Equal(t, tt.want, got)
+				assert.Zero(t, rec.Body.Len(), "accepted body was answered")
+				return
+			}
+			require.False(t, ok)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			body := readError(t, rec)
+			assert.Equal(t, tt.wantCode, body.Code)
+			if tt.wantMessage != "" {
+				assert.Equal(t, tt.wantMessage, body.Message)
+			}
+		})
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	t.Run("error", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		writeError(rec, http.StatusForbidden, codeForbidden, "Admin role required")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, errorBody{Code: codeForbidden, Message: "Admin role required"}, readError(t, rec))
+	})
+	t.Run("chat busy", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		writeChatBusy(rec)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+		assert.Equal(t, codeChatBusy, readError(t, rec).Code)
+	})
+	t.Run("rate limited", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		writeRateLimited(rec, 1500*time.Millisecond, "Too many messages")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("Retry-After"), "retry after is not rounded up")
+		assert.Equal(t, errorBody{Code: codeRateLimited, Message: "Too many messages"}, readError(t, rec))
+	})
+	t.Run("unknown endpoint", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handleNotFound(rec, httptest.NewRequest(http.MethodGet, "/api/v1/nope", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, errorBody{Code: codeNotFound, Message: "No endpoint GET /api/v1/nope"}, readError(t, rec))
+	})
+}
@@ -0,0 +1,33 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"smb-chatbot/internal/usecase"
+)
+
+type QueueController struct {
+	inbound usecase.InboundService
+}
+
+func NewQueueController(is usecase.InboundService) *QueueController {
+	return &QueueController{inbound: is}
+}
+
+func (h *QueueController) handleGetStats(w http.ResponseWriter, r *http.Request) {
+	log.Println("HANDLER: Received GET /api/queue/stats request")
+
+	stats, err := h.inbound.QueueStats(r.Context())
+	if err != nil {
+		log.Printf("ERROR: Failed to get queue stats: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to retrieve queue statistics")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Printf("Failed to encode queue stats response: %v", err)
+	}
+}
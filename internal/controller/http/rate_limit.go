@@ -0,0 +1,86 @@
+package http
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"smb-chatbot/internal/usecase"
+)
+
+// allowMessage applies the rate limits to a message to chatID from userID.
+// A nil rl admits everything. If the message is rejected it answers the
+// request and returns false.
+func allowMessage(w http.ResponseWriter, r *http.Request, rl usecase.RateLimiter, chatID, userID int64) bool {
+	if rl == nil {
+		return true
+	}
+	retryAfter, err := rl.Allow(r.Context(), chatID, userID, clientIP(r))
+	switch {
+	case errors.Is(err, usecase.ErrChatMuted):
+		log.Printf("HANDLER: Rejected message to muted chat %d", chatID)
+		writeRateLimited(w, retryAfter, "Too many messages, the chat is paused for a while")
+	case errors.Is(err, usecase.ErrRateLimited):
+		log.Printf("HANDLER: Rate limited message to chat %d from user %d at %s", chatID, userID, clientIP(r))
+		writeRateLimited(w, retryAfter, "Too many messages, please slow down")
+	case err != nil:
+		log.Printf("ERROR: Failed to apply rate limits to chat %d: %v", chatID, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal server error processing message")
+	default:
+		return true
+	}
+	return false
+}
+
+// allowIP applies the per-IP rate limit to a request not tied to a chat. A nil
+// rl admits everything. If the request is rejected it answers it and returns
+// false.
+func allowIP(w http.ResponseWriter, r *http.Request, rl usecase.RateLimiter) bool {
+	if rl == nil {
+		return true
+	}
+	retryAfter, err := rl.AllowIP(r.Context(), clientIP(r))
+	switch {
+	case errors.Is(err, usecase.ErrRateLimited):
+		log.Printf("HANDLER: Rate limited %s %s from %s", r.Method, r.URL.Path, clientIP(r))
+		writeRateLimited(w, retryAfter, "Too many requests, please slow down")
+	case err != nil:
+		log.Printf("ERROR: Failed to apply rate limit to %s: %v", clientIP(r), err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal server error")
+	default:
+		return true
+	}
+	return false
+}
+
+func retryAfterSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
+
+// clientIP is the address the request came from, without port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ForwardedFor takes the client address of requests from the last entry of
+// X-Forwarded-For, which the reverse proxy in front of the server appends.
+// Without a proxy clients could set any address this way.
+func ForwardedFor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			entries := strings.Split(values[len(values)-1], ",")
+			if ip := net.ParseIP(strings.TrimSpace(entries[len(entries)-1])); ip != nil {
+				r.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,99 +2,268 @@ package http
 
 import (
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
-	"strings"
+	"unicode/utf8"
 
-	gwMessenger "smb-chatbot/internal/gateway/messenger"
+	"smb-chatbot/internal/entity"
 	"smb-chatbot/internal/usecase"
 )
 
 type ReviewController struct {
-	uc              usecase.ReviewUseCase
-	historyRepo     usecase.HistoryRepository
-	messengerClient *gwMessenger.MockMessengerClient
+	inbound     usecase.InboundService
+	historyRepo usecase.HistoryRepository
+	limiter     usecase.RateLimiter
 }
 
+// NewReviewController creates the message and history handlers. Messages
+// are not rate limited if rl is nil.
 func NewReviewController(
-	uc usecase.ReviewUseCase,
+	is usecase.InboundService,
 	hr usecase.HistoryRepository,
-	mc *gwMessenger.MockMessengerClient,
+	rl usecase.RateLimiter,
 ) *ReviewController {
 	return &ReviewController{
-		uc:              uc,
-		historyRepo:     hr,
-		messengerClient: mc,
+		inbound:     is,
+		historyRepo: hr,
+		limiter:     rl,
 	}
 }
 
+const (
+	maxMessageBodyBytes = 64 << 10
+	// maxMessageText is the most characters of a message's text.
+	maxMessageText = 4096
+	// maxMessageAttachments bounds the photos referenced by one message.
+	maxMessageAttachments = 10
+)
+
+type sendMessageRequest struct {
+	ChatID        int64    `json:"chat_id"`
+	UserID        int64    `json:"user_id"`
+	UserName      string   `json:"user_name"`
+	Text          string   `json:"text"`
+	MessageID     string   `json:"message_id"`
+	Payload       string   `json:"payload"`
+	AttachmentIDs []string `json:"attachment_ids"`
+}
+
+// validate returns what is wrong with the request, or "" if it is valid.
+func (req sendMessageRequest) validate() string {
+	switch {
+	case req.ChatID == 0 || req.UserID == 0:
+		return "Required fields: chat_id (number), user_id (number)"
+	case req.Text == "" && len(req.AttachmentIDs) == 0:
+		return "Required field: text (string) or attachment_ids (array)"
+	case utf8.RuneCountInString(req.Text) > maxMessageText:
+		return fmt.Sprintf("text must be at most %d characters", maxMessageText)
+	case len(req.AttachmentIDs) > maxMessageAttachments:
+		return fmt.Sprintf("attachment_ids must hold at most %d IDs", maxMessageAttachments)
+	case len(req.MessageID) > 255:
+		return "message_id / Idempotency-Key must be at most 255 characters"
+	case len(req.UserName) > 255 || len(req.Payload) > 255:
+		return "user_name and payload must be at most 255 characters"
+	}
+	return ""
+}
+
 type MessageResponse struct {
 	Reply string `json:"reply"`
 }
 
+type QueuedMessageResponse struct {
+	Status  string `json:"status"`
+	QueueID int64  `json:"queue_id"`
+}
+
 func (h *ReviewController) handleSendMessage(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log.Println("HANDLER: Received POST /api/message request")
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Failed to read request body", http.StatusInternalServerError)
+	var req sendMessageRequest
+	if !decodeJSON(w, r, maxMessageBodyBytes, &req) {
 		return
 	}
-	defer r.Body.Close()
-
-	var input usecase.HandleMessageInput
-
-	err = json.Unmarshal(body, &input)
-
-	if err != nil || input.ChatID == 0 || input.UserID == 0 || input.Text == "" {
-		http.Error(w, "Invalid JSON payload. Required fields: chat_id (number), user_id (number), text (string)", http.StatusBadRequest)
+	// Web chat visitors write to the chat of their token.
+	channel := entity.ChannelAPI
+	if chatID, userID, ok := webChatIdentity(r); ok {
+		channel = entity.ChannelWebSocket
+		if req.ChatID == 0 {
+			req.ChatID = chatID
+		}
+		if req.UserID == 0 {
+			req.UserID = userID
+		}
+	}
+	if key := r.Header.Get("Idempotency-Key"); key != "" && req.MessageID == "" {
+		req.MessageID = key
+	}
+	if message := req.validate(); message != "" {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, message)
+		return
+	}
+	// The ranges above are reserved for the chats of other channels, which
+	// only their own tokens reach.
+	if channel == entity.ChannelAPI && (req.ChatID < 0 || req.ChatID >= entity.ChatIDRangeSize) {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("chat_id must be between 1 and %d", entity.ChatIDRangeSize-1))
+		return
+	}
+	if !canAccessChat(r, req.ChatID, req.UserID) {
+		writeError(w, http.StatusForbidden, codeForbidden, "The token does not grant access to this chat")
+		return
+	}
+	if !allowMessage(w, r, h.limiter, req.ChatID, req.UserID) {
 		return
 	}
 
-	h.messengerClient.AddHistory(input.ChatID, true, input.Text)
+	// The channel and its media are set by the channel adapters, never by API clients.
+	input := usecase.HandleMessageInput{
+		Channel:       channel,
+		ChatID:        req.ChatID,
+		UserID:        req.UserID,
+		UserName:      req.UserName,
+		Text:          req.Text,
+		MessageID:     req.MessageID,
+		Payload:       req.Payload,
+		AttachmentIDs: req.AttachmentIDs,
+	}
 
-	botReply, err := h.uc.HandleMessage(ctx, input)
+	result, err := h.inbound.Submit(ctx, input)
+	if errors.Is(err, usecase.ErrChatBusy) {
+		writeChatBusy(w)
+		return
+	}
+	if errors.Is(err, usecase.ErrInvalidAttachment) {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "attachment_ids must name photos uploaded to this chat and not yet sent")
+		return
+	}
+	if errors.Is(err, usecase.ErrChannelMismatch) {
+		writeError(w, http.StatusConflict, codeChannelMismatch, "The chat belongs to a messenger channel and cannot be written to through the API")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Internal server error processing message", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal server error processing message")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if result.Queued {
+		// The reply is delivered through the messenger once a worker processed the message.
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(QueuedMessageResponse{Status: "queued", QueueID: result.QueueID}); err != nil {
+			log.Printf("ERROR: Failed to encode response payload: %v", err)
+		}
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
-	responsePayload := MessageResponse{Reply: botReply}
+	responsePayload := MessageResponse{Reply: result.Reply}
 
 	if err := json.NewEncoder(w).Encode(responsePayload); err != nil {
 		log.Printf("ERROR: Failed to encode response payload: %v", err)
 	}
 }
 
+const (
+	defaultHistoryPageSize = 20
+	maxHistoryPageSize     = 100
+)
+
+type historyPageResponse struct {
+	Messages []entity.HistoryEntry `json:"messages"`
+	// HasMore reports messages beyond this page: newer ones when paging
+	// forward with "after", older ones otherwise.
+	HasMore bool `json:"has_more"`
+}
+
+// historyChatID is the chat of the {chat_id} path segment or, without one,
+// of the caller's web chat token.
+func historyChatID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	if r.PathValue("chat_id") != "" {
+		return chatIDParam(w, r)
+	}
+	chatID, _, ok := webChatIdentity(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "chat_id is required without a web chat token")
+	}
+	return chatID, ok
+}
+
+// handleGetHistory returns a page of the chat's messages. Without cursors it
+// is the latest messages; "before" and "after" take message IDs of earlier
+// pages. "direction" orders the page oldest first ("asc", the default) or
+// newest first ("desc").
 func (h *ReviewController) handleGetHistory(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	path := strings.TrimPrefix(r.URL.Path, "/api/history/")
-	path = strings.TrimSuffix(path, "/")
+	chatID, ok := historyChatID(w, r)
+	if !ok {
+		return
+	}
+	log.Printf("HANDLER: Received GET /api/history/%d request (%s)", chatID, r.URL.RawQuery)
+	if !canAccessChat(r, chatID, 0) {
+		writeError(w, http.StatusForbidden, codeForbidden, "The token does not grant access to this chat")
+		return
+	}
 
-	chatID, err := strconv.ParseInt(path, 10, 64)
-	if err != nil || chatID == 0 {
-		http.Error(w, "Invalid or missing chat_id in URL path", http.StatusBadRequest)
+	query := r.URL.Query()
+	historyRange := entity.HistoryRange{Limit: defaultHistoryPageSize}
+	params := []struct {
+		name  string
+		value *int64
+	}{
+		{"before", &historyRange.BeforeID},
+		{"after", &historyRange.AfterID},
+	}
+	for _, param := range params {
+		if v := query.Get(param.name); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id <= 0 {
+				writeError(w, http.StatusBadRequest, codeInvalidRequest, param.name+" must be a message id")
+				return
+			}
+			*param.value = id
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "limit must be a positive number")
+			return
+		}
+		historyRange.Limit = min(limit, maxHistoryPageSize)
+	}
+	direction := query.Get("direction")
+	if direction != "" && direction != "asc" && direction != "desc" {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "direction must be 'asc' or 'desc'")
 		return
 	}
-	log.Printf("HANDLER: Received GET /api/history/%d request", chatID)
 
-	const historyFetchLimit = 10
-	history, err := h.historyRepo.GetHistory(ctx, chatID, historyFetchLimit)
+	// One extra message tells whether another page follows.
+	limit := historyRange.Limit
+	historyRange.Limit++
+	history, err := h.historyRepo.GetHistoryRange(ctx, chatID, historyRange)
 	if err != nil {
 		log.Printf("ERROR: Failed to get history from repository for chat %d: %v", chatID, err)
-		http.Error(w, "Failed to retrieve conversation history", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to retrieve conversation history")
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-
-	err = json.NewEncoder(w).Encode(history)
-	if err != nil {
-		log.Printf("Failed to encode history response: %v", err)
+	response := historyPageResponse{Messages: history}
+	if len(history) > limit {
+		response.HasMore = true
+		// The extra message is the one farthest from the cursor.
+		if historyRange.AfterID > 0 {
+			response.Messages = history[:limit]
+		} else {
+			response.Messages = history[1:]
+		}
+	}
+	if direction == "desc" {
+		slices.Reverse(response.Messages)
 	}
+
+	writeJSON(w, http.StatusOK, response)
 }
@@ -0,0 +1,228 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+// memoryHistory selects messages like the Postgres repository: by ID within
+// the range, Limit of them from the AfterID end or the newest end, returned
+// oldest first.
+type memoryHistory struct {
+	usecase.HistoryRepository
+	entries []entity.HistoryEntry
+}
+
+func (h *memoryHistory) GetHistoryRange(_ context.Context, _ int64, r entity.HistoryRange) ([]entity.HistoryEntry, error) {
+	var inRange []entity.HistoryEntry
+	for _, entry := range h.entries {
+		if (r.AfterID == 0 || entry.ID > r.AfterID) && (r.BeforeID == 0 || entry.ID < r.BeforeID) {
+			inRange = append(inRange, entry)
+		}
+	}
+	if len(inRange) <= r.Limit {
+		return inRange, nil
+	}
+	if r.AfterID > 0 {
+		return inRange[:r.Limit], nil
+	}
+	return inRange[len(inRange)-r.Limit:], nil
+}
+
+func getHistoryPage(t *testing.T, h *ReviewController, query string) (int, historyPageResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/history/7?"+query, nil)
+	req.SetPathValue("chat_id", "7")
+	rec := httptest.NewRecorder()
+	h.handleGetHistory(rec, req)
+
+	var page historyPageResponse
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	}
+	return rec.Code, page
+}
+
+func historyIDs(entries []entity.HistoryEntry) []int64 {
+	ids := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func TestHistoryCursors(t *testing.T) {
+	history := &memoryHistory{}
+	// IDs are shared by all chats, so a chat's messages have gaps.
+	for id := int64(10); id <= 100; id += 10 {
+		history.entries = append(history.entries, entity.HistoryEntry{ID: id, Text: strconv.FormatInt(id, 10)})
+	}
+	h := NewReviewController(nil, history, nil)
+
+	tests := []struct {
+		name        string
+		query       string
+		wantIDs     []int64
+		wantHasMore bool
+	}{
+		{name: "latest", query: "limit=3", wantIDs: []int64{80, 90, 100}, wantHasMore: true},
+		{name: "latest newest first", query: "limit=3&direction=desc", wantIDs: []int64{100, 90, 80}, wantHasMore: true},
+		{name: "before", query: "limit=3&before=80", wantIDs: []int64{50, 60, 70}, wantHasMore: true},
+		{name: "before the last page", query: "limit=3&before=40", wantIDs: []int64{10, 20, 30}},
+		{name: "after", query: "limit=3&after=30", wantIDs: []int64{40, 50, 60}, wantHasMore: true},
+		{name: "after the last page", query: "limit=3&after=70", wantIDs: []int64{80, 90, 100}},
+		{name: "after newest first", query: "limit=3&after=30&direction=desc", wantIDs: []int64{60, 50, 40}, wantHasMore: true},
+		{name: "between", query: "after=20&before=60", wantIDs: []int64{30, 40, 50}},
+		{name: "cursor between IDs", query: "limit=2&before=55", wantIDs: []int64{40, 50}, wantHasMore: true},
+		{name: "nothing newer", query: "after=100", wantIDs: []int64{}},
+		{name: "everything", query: "", wantIDs: []int64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, page := getHistoryPage(t, h, tt.query)
+			require.Equal(t, http.StatusOK, code)
+			assert.Equal(t, tt.wantIDs, historyIDs(page.Messages))
+			assert.Equal(t, tt.wantHasMore, page.HasMore)
+		})
+	}
+}
+
+func TestHistoryPagesBackAndForth(t *testing.T) {
+	history := &memoryHistory{}
+	for id := int64(1); id <= 25; id++ {
+		history.entries = append(history.entries, entity.HistoryEntry{ID: id})
+	}
+	h := NewReviewController(nil, history, nil)
+
+	var backward []int64
+	query := "limit=4"
+	for {
+		code, page := getHistoryPage(t, h, query)
+		require.Equal(t, http.StatusOK, code)
+		backward = append(historyIDs(page.Messages), backward...)
+		if !page.HasMore {
+			break
+		}
+		query = "limit=4&before=" + strconv.FormatInt(page.Messages[0].ID, 10)
+	}
+	assert.Equal(t, historyIDs(history.entries), backward)
+
+	forward := []int64{1}
+	query = "limit=4&after=1"
+	for {
+		code, page := getHistoryPage(t, h, query)
+		require.Equal(t, http.StatusOK, code)
+		forward = append(forward, historyIDs(page.Messages)...)
+		if !page.HasMore {
+			break
+		}
+		query = "limit=4&after=" + strconv.FormatInt(page.Messages[len(page.Messages)-1].ID, 10)
+	}
+	assert.Equal(t, historyIDs(history.entries), forward)
+}
+
+func TestHistoryRejectsInvalidCursors(t *testing.T) {
+	h := NewReviewController(nil, &memoryHistory{}, nil)
+	for _, query := range []string{
+		"before=abc",
+		"before=-1",
+		"after=0",
+		"after=1.5",
+		"limit=0",
+		"limit=ten",
+		"direction=up",
+	} {
+		t.Run(query, func(t *testing.T) {
+			code, _ := getHistoryPage(t, h, query)
+			assert.Equal(t, http.StatusBadRequest, code)
+		})
+	}
+}
+
+func TestSendMessageChannels(t *testing.T) {
+	webChat := principal{ChatID: entity.WebChatIDBase + 5, UserID: 9}
+	tests := []struct {
+		name        string
+		caller      *principal
+		body        string
+		submitErr   error
+		wantStatus  int
+		wantChannel string
+	}{
+		{name: "API chat", body: `{"chat_id": 42, "user_id": 7, "text": "hi"}`, wantStatus: http.StatusOK, wantChannel: entity.ChannelAPI},
+		{name: "highest API chat", body: `{"chat_id": 999999999999999, "user_id": 7, "text": "hi"}`, wantStatus: http.StatusOK, wantChannel: entity.ChannelAPI},
+		{name: "negative chat", body: `{"chat_id": -42, "user_id": 7, "text": "hi"}`, wantStatus: http.StatusBadRequest},
+		{name: "email chat", body: `{"chat_id": 1000000000000000, "user_id": 7, "text": "hi"}`, wantStatus: http.StatusBadRequest},
+		{name: "WhatsApp chat", body: `{"chat_id": 3004915112345678, "user_id": 7, "text": "hi"}`, wantStatus: http.StatusBadRequest},
+		{name: "web chat of the token", caller: &webChat, body: `{"text": "hi"}`, wantStatus: http.StatusOK, wantChannel: entity.ChannelWebSocket},
+		{name: "web chat with its own IDs", caller: &webChat, body: `{"chat_id": 2000000000000005, "user_id": 9, "text": "hi"}`, wantStatus: http.StatusOK, wantChannel: entity.ChannelWebSocket},
+		{name: "web chat token for another chat", caller: &webChat, body: `{"chat_id": 2000000000000006, "text": "hi"}`, wantStatus: http.StatusForbidden},
+		{name: "web chat token for another user", caller: &webChat, body: `{"user_id": 10, "text": "hi"}`, wantStatus: http.StatusForbidden},
+		{name: "web chat token for an API chat", caller: &webChat, body: `{"chat_id": 42, "user_id": 9, "text": "hi"}`, wantStatus: http.StatusForbidden},
+		{name: "chat of a messenger", body: `{"chat_id": 42, "user_id": 7, "text": "hi"}`, submitErr: usecase.ErrChannelMismatch, wantStatus: http.StatusConflict, wantChannel: entity.ChannelAPI},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inbound := &recordingInbound{err: tt.submitErr}
+			h := NewReviewController(inbound, &memoryHistory{}, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/message", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.caller != nil {
+				req = req.WithContext(context.WithValue(req.Context(), principalKey{}, *tt.caller))
+			}
+			rec := httptest.NewRecorder()
+			h.handleSendMessage(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			if tt.wantChannel == "" {
+				assert.Empty(t, inbound.submitted)
+				return
+			}
+			require.Len(t, inbound.submitted, 1)
+			assert.Equal(t, tt.wantChannel, inbound.submitted[0].Channel)
+		})
+	}
+}
+
+func TestCanAccessChat(t *testing.T) {
+	const chatID = entity.WebChatIDBase + 5
+	webChat := principal{ChatID: chatID, UserID: 9}
+	tests := []struct {
+		name   string
+		caller *principal
+		chatID int64
+		userID int64
+		want   bool
+	}{
+		{name: "no credentials", chatID: 42, userID: 7, want: true},
+		{name: "API key", caller: &principal{Role: entity.RoleOwner, Subject: "key"}, chatID: 42, userID: 7, want: true},
+		{name: "dashboard token with a chat", caller: &principal{Role: entity.RoleAgent, ChatID: chatID}, chatID: 42, want: true},
+		{name: "token without chat", caller: &principal{Subject: "integration"}, chatID: 42, userID: 7, want: true},
+		{name: "web chat of the token", caller: &webChat, chatID: chatID, userID: 9, want: true},
+		{name: "web chat of the token without user", caller: &webChat, chatID: chatID, want: true},
+		{name: "another chat", caller: &webChat, chatID: chatID + 1, userID: 9},
+		{name: "another chat without user", caller: &webChat, chatID: chatID + 1},
+		{name: "another user", caller: &webChat, chatID: chatID, userID: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/history", nil)
+			if tt.caller != nil {
+				req = req.WithContext(context.WithValue(req.Context(), principalKey{}, *tt.caller))
+			}
+			assert.Equal(t, tt.want, canAccessChat(req, tt.chatID, tt.userID))
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"smb-chatbot/internal/usecase"
+)
+
+type ReviewLinkController struct {
+	promoter usecase.ReviewPromoter
+}
+
+func NewReviewLinkController(p usecase.ReviewPromoter) *ReviewLinkController {
+	return &ReviewLinkController{promoter: p}
+}
+
+func (h *ReviewLinkController) handleRedirect(w http.ResponseWriter, r *http.Request) {
+	linkID := r.PathValue("link_id")
+	log.Printf("HANDLER: Received GET /r/%s request", linkID)
+
+	targetURL, err := h.promoter.ResolveLink(r.Context(), linkID)
+	if err != nil {
+		if errors.Is(err, usecase.ErrReviewLinkNotFound) {
+			http.Error(w, "Review link not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("ERROR: Failed to resolve review link %s: %v", linkID, err)
+		http.Error(w, "Failed to resolve review link", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, targetURL, http.StatusFound)
+}
@@ -0,0 +1,255 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"smb-chatbot/internal/auth"
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/gateway/export"
+	"smb-chatbot/internal/usecase"
+)
+
+type ReviewListController struct {
+	reviews usecase.ReviewReader
+	links   *attachmentLinker
+}
+
+// NewReviewListController creates the handlers that read collected reviews.
+// Photos of reviews get signed URLs valid for urlTTL if signer is set.
+func NewReviewListController(rr usecase.ReviewReader, signer *auth.TokenSigner, urlTTL time.Duration) *ReviewListController {
+	return &ReviewListController{
+		reviews: rr,
+		links:   newAttachmentLinker(signer, urlTTL),
+	}
+}
+
+type reviewResponse struct {
+	entity.Review
+	Sentiment   string               `json:"sentiment,omitempty"`
+	Attachments []attachmentResponse `json:"attachments,omitempty"`
+}
+
+type reviewPageResponse struct {
+	Reviews    []reviewResponse `json:"reviews"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+func (h *ReviewListController) handleList(w http.ResponseWriter, r *http.Request) {
+	log.Printf("HANDLER: Received GET /api/reviews request (%s)", r.URL.RawQuery)
+
+	input, err := parseListReviewsInput(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	page, err := h.reviews.ListReviews(r.Context(), input)
+	if errors.Is(err, usecase.ErrInvalidReviewQuery) {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to list reviews: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to retrieve reviews")
+		return
+	}
+
+	response := reviewPageResponse{Reviews: make([]reviewResponse, 0, len(page.Reviews)), NextCursor: page.NextCursor}
+	for _, review := range page.Reviews {
+		item, err := h.response(review)
+		if err != nil {
+			log.Printf("ERROR: Failed to sign photo URLs of review %s: %v", review.ID, err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Failed to retrieve reviews")
+			return
+		}
+		response.Reviews = append(response.Reviews, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode reviews response: %v", err)
+	}
+}
+
+func (h *ReviewListController) handleGet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("review_id")
+	log.Printf("HANDLER: Received GET /api/reviews/%s request", id)
+
+	review, err := h.reviews.GetReview(r.Context(), id)
+	if errors.Is(err, usecase.ErrReviewNotFound) {
+		writeError(w, http.StatusNotFound, codeNotFound, "Review not found")
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to get review %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to retrieve review")
+		return
+	}
+
+	response, err := h.response(*review)
+	if err != nil {
+		log.Printf("ERROR: Failed to sign photo URLs of review %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to retrieve review")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode review response: %v", err)
+	}
+}
+
+// handleExport streams all reviews matching the list filters in the format
+// of the "format" query parameter, CSV by default.
+func (h *ReviewListController) handleExport(w http.ResponseWriter, r *http.Request) {
+	log.Printf("HANDLER: Received GET /api/reviews/export request (%s)", r.URL.RawQuery)
+
+	formatName := r.URL.Query().Get("format")
+	if formatName == "" {
+		formatName = "csv"
+	}
+	format, err := export.LookupFormat(formatName)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	input, err := parseListReviewsInput(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "reviews-" + time.Now().Format(time.DateOnly) + format.Extension,
+	}))
+	body := &exportResponseWriter{ResponseWriter: w}
+	count, err := h.reviews.ExportReviews(r.Context(), input, format.NewWriter(body))
+	if errors.Is(err, usecase.ErrInvalidReviewQuery) {
+		// Validation fails before anything is written.
+		w.Header().Del("Content-Disposition")
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Review export failed after %d reviews: %v", count, err)
+		if !body.written {
+			w.Header().Del("Content-Disposition")
+			writeError(w, http.StatusInternalServerError, codeInternal, "Failed to export reviews")
+			return
+		}
+		// The status line is gone already; aborting keeps the client from
+		// taking a truncated file for a complete one.
+		panic(http.ErrAbortHandler)
+	}
+	log.Printf("HANDLER: Exported %d reviews as %s", count, format.Name)
+}
+
+// exportResponseWriter records whether any of the export reached the
+// response, after which a failure can no longer be reported in it.
+type exportResponseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *exportResponseWriter) Write(p []byte) (int, error) {
+	w.written = w.written || len(p) > 0
+	return w.ResponseWriter.Write(p)
+}
+
+func (h *ReviewListController) response(review entity.Review) (reviewResponse, error) {
+	response := reviewResponse{Review: review, Sentiment: review.Sentiment()}
+	for _, attachment := range review.Attachments {
+		linked, err := h.links.link(attachment)
+		if err != nil {
+			return reviewResponse{}, err
+		}
+		response.Attachments = append(response.Attachments, linked)
+	}
+	return response, nil
+}
+
+// parseListReviewsInput reads the filters of GET /api/reviews. Dates are
+// RFC 3339 timestamps or plain dates; a plain "to" date includes that day.
+func parseListReviewsInput(query url.Values) (usecase.ListReviewsInput, error) {
+	input := usecase.ListReviewsInput{
+		Filter: entity.ReviewFilter{
+			Search: query.Get("q"),
+		},
+		Sentiment: query.Get("sentiment"),
+		Sort:      query.Get("sort"),
+		Cursor:    query.Get("cursor"),
+	}
+
+	var err error
+	if input.Filter.From, err = parseTimeParam(query, "from", false); err != nil {
+		return input, err
+	}
+	if input.Filter.To, err = parseTimeParam(query, "to", true); err != nil {
+		return input, err
+	}
+
+	ids := []struct {
+		name  string
+		value *int64
+	}{
+		{"chat_id", &input.Filter.ChatID},
+		{"customer_id", &input.Filter.CustomerID},
+	}
+	for _, param := range ids {
+		if v := query.Get(param.name); v != "" {
+			if *param.value, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return input, fmt.Errorf("%s must be a number", param.name)
+			}
+		}
+	}
+
+	numbers := []struct {
+		name  string
+		value *int
+	}{
+		{"min_rating", &input.Filter.MinRating},
+		{"max_rating", &input.Filter.MaxRating},
+		{"limit", &input.Limit},
+	}
+	for _, param := range numbers {
+		if v := query.Get(param.name); v != "" {
+			if *param.value, err = strconv.Atoi(v); err != nil {
+				return input, fmt.Errorf("%s must be a number", param.name)
+			}
+		}
+	}
+	if v := query.Get("rating"); v != "" {
+		rating, err := strconv.Atoi(v)
+		if err != nil || rating < 1 || rating > 5 {
+			return input, fmt.Errorf("rating must be a number between 1 and 5")
+		}
+		input.Filter.MinRating, input.Filter.MaxRating = rating, rating
+	}
+	return input, nil
+}
+
+func parseTimeParam(query url.Values, name string, endOfDay bool) (time.Time, error) {
+	v := query.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date like 2024-05-01 or an RFC 3339 timestamp", name)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
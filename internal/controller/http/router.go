@@ -1,10 +1,106 @@
 package http
 
 import (
+	_ "embed"
 	"net/http"
+
+	"smb-chatbot/internal/entity"
+)
+
+// apiPrefixes are the mount points of the JSON API. /api/v1 is the versioned
+// surface described by the OpenAPI document; the unversioned /api paths stay
+// for existing clients. Channel webhooks are only mounted under /api.
+var apiPrefixes = []string{"/api/v1", "/api"}
+
+//go:embed openapi.yaml
+var openAPIDocument []byte
+
+func handleAPI(mux *http.ServeMux, method, path string, handler http.HandlerFunc) {
+	for _, prefix := range apiPrefixes {
+		mux.HandleFunc(method+" "+prefix+path, handler)
+	}
+}
+
+// Roles admitted to groups of routes.
+var (
+	ownerRoles       = []string{entity.RoleOwner}
+	staffRoles       = []string{entity.RoleOwner, entity.RoleAgent}
+	integrationRoles = []string{entity.RoleOwner, entity.RoleIntegration}
+	allRoles         = []string{entity.RoleOwner, entity.RoleAgent, entity.RoleIntegration}
 )
 
-func RegisterRoutes(mux *http.ServeMux, h *ReviewController) {
-	mux.HandleFunc("POST /api/message", h.handleSendMessage)
-	mux.HandleFunc("GET /api/history/", h.handleGetHistory)
+func RegisterRoutes(mux *http.ServeMux, a *Authenticator, h *ReviewController, lh *ReviewLinkController, ch *CampaignController, qh *QueueController) {
+	handleAPI(mux, "POST", "/message", a.allowChat(h.handleSendMessage, integrationRoles...))
+	handleAPI(mux, "GET", "/history", a.allowChat(h.handleGetHistory, allRoles...))
+	handleAPI(mux, "GET", "/history/{chat_id}", a.allowChat(h.handleGetHistory, allRoles...))
+	handleAPI(mux, "GET", "/campaigns/stats", a.allow(ch.handleGetStats, staffRoles...))
+	handleAPI(mux, "GET", "/queue/stats", a.allow(qh.handleGetStats, staffRoles...))
+	mux.HandleFunc("GET /r/{link_id}", lh.handleRedirect)
+
+	mux.HandleFunc("GET /api/v1/openapi.yaml", handleOpenAPI)
+	mux.HandleFunc("/api/v1/", handleNotFound)
+}
+
+func RegisterConversationRoutes(mux *http.ServeMux, a *Authenticator, ch *ConversationController) {
+	handleAPI(mux, "GET", "/conversations", a.allow(ch.handleList, staffRoles...))
+	handleAPI(mux, "GET", "/conversations/{chat_id}", a.allow(ch.handleGet, staffRoles...))
+	handleAPI(mux, "POST", "/conversations/{chat_id}/state", a.allow(ch.handleSetState, staffRoles...))
+	handleAPI(mux, "POST", "/conversations/{chat_id}/close", a.allow(ch.handleClose, staffRoles...))
+	handleAPI(mux, "POST", "/conversations/{chat_id}/delete", a.allow(ch.handleDelete, ownerRoles...))
+}
+
+func RegisterReviewListRoutes(mux *http.ServeMux, a *Authenticator, rh *ReviewListController) {
+	handleAPI(mux, "GET", "/reviews", a.allow(rh.handleList, allRoles...))
+	handleAPI(mux, "GET", "/reviews/export", a.allow(rh.handleExport, allRoles...))
+	handleAPI(mux, "GET", "/reviews/{review_id}", a.allow(rh.handleGet, allRoles...))
+}
+
+func RegisterAuthRoutes(mux *http.ServeMux, a *Authenticator, ah *AuthController) {
+	handleAPI(mux, "GET", "/api-keys", a.allow(ah.handleListKeys, ownerRoles...))
+	handleAPI(mux, "POST", "/api-keys", a.allow(ah.handleCreateKey, ownerRoles...))
+	handleAPI(mux, "POST", "/api-keys/{key_id}/revoke", a.allow(ah.handleRevokeKey, ownerRoles...))
+	handleAPI(mux, "POST", "/auth/tokens", a.allow(ah.handleIssueToken, ownerRoles...))
+}
+
+func RegisterTelegramRoutes(mux *http.ServeMux, th *TelegramController) {
+	mux.HandleFunc("POST /api/telegram/webhook", th.handleWebhook)
+}
+
+func RegisterWhatsAppRoutes(mux *http.ServeMux, wh *WhatsAppController) {
+	mux.HandleFunc("GET /api/whatsapp/webhook", wh.handleVerify)
+	mux.HandleFunc("POST /api/whatsapp/webhook", wh.handleWebhook)
+}
+
+func RegisterSMSRoutes(mux *http.ServeMux, sh *SMSController) {
+	mux.HandleFunc("POST /api/sms/webhook", sh.handleWebhook)
+	mux.HandleFunc("POST /api/sms/status", sh.handleStatusCallback)
+}
+
+func RegisterEmailRoutes(mux *http.ServeMux, eh *EmailController) {
+	mux.HandleFunc("POST /api/email/webhook", eh.handleWebhook)
+}
+
+// RegisterWebSocketRoutes registers the web chat endpoints. Tokens are issued
+// to integrations, which embed the chat for their customers; the connection
+// checks the token itself.
+func RegisterWebSocketRoutes(mux *http.ServeMux, a *Authenticator, wh *WebSocketController) {
+	handleAPI(mux, "POST", "/ws/token", a.allow(wh.handleIssueToken, integrationRoles...))
+	handleAPI(mux, "GET", "/ws", wh.handleConnect)
+}
+
+// RegisterSessionRoutes registers the public session bootstrap of web chat
+// visitors.
+func RegisterSessionRoutes(mux *http.ServeMux, sh *SessionController) {
+	handleAPI(mux, "POST", "/sessions", sh.handleStart)
+}
+
+func RegisterAttachmentRoutes(mux *http.ServeMux, ah *AttachmentController) {
+	handleAPI(mux, "POST", "/attachments", ah.handleUpload)
+	handleAPI(mux, "GET", "/attachments/{attachment_id}", ah.handleGet)
+	handleAPI(mux, "GET", "/attachments/{attachment_id}/thumbnail", ah.handleGetThumbnail)
+}
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPIDocument)
 }
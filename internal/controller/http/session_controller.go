@@ -0,0 +1,80 @@
+package http
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"smb-chatbot/internal/auth"
+	"smb-chatbot/internal/usecase"
+)
+
+// webSessionSubject marks web chat tokens of anonymous sessions, which the
+// visitor may renew, unlike tokens issued to integrations.
+const webSessionSubject = "web_session"
+
+type SessionController struct {
+	sessions usecase.WebSessionService
+	signer   *auth.TokenSigner
+	tokenTTL time.Duration
+	limiter  usecase.RateLimiter
+}
+
+func NewSessionController(ws usecase.WebSessionService, signer *auth.TokenSigner, tokenTTL time.Duration, rl usecase.RateLimiter) *SessionController {
+	return &SessionController{
+		sessions: ws,
+		signer:   signer,
+		tokenTTL: tokenTTL,
+		limiter:  rl,
+	}
+}
+
+type sessionResponse struct {
+	Token     string    `json:"token"`
+	ChatID    int64     `json:"chat_id"`
+	UserID    int64     `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// handleStart gives a visitor a web chat token for a new chat. A visitor
+// presenting a valid session token gets a fresh token for the same chat
+// instead, so the conversation survives as long as the visitor returns
+// before the token expires. New sessions count against the per-IP rate
+// limit, as each one creates a chat.
+func (h *SessionController) handleStart(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength != 0 {
+		var req struct{}
+		if !decodeJSON(w, r, 1<<10, &req) {
+			return
+		}
+	}
+
+	claims, err := h.signer.Verify(bearerToken(r))
+	status := http.StatusOK
+	if err != nil || claims.Subject != webSessionSubject || claims.ChatID == 0 {
+		if !allowIP(w, r, h.limiter) {
+			return
+		}
+		session, err := h.sessions.Start(r.Context())
+		if err != nil {
+			log.Printf("ERROR: Failed to start web session: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Failed to start session")
+			return
+		}
+		claims = auth.Claims{Subject: webSessionSubject, ChatID: session.ChatID, UserID: session.UserID}
+		status = http.StatusCreated
+	}
+
+	token, err := h.signer.Sign(auth.Claims{Subject: webSessionSubject, ChatID: claims.ChatID, UserID: claims.UserID}, h.tokenTTL)
+	if err != nil {
+		log.Printf("ERROR: Failed to sign session token for chat %d: %v", claims.ChatID, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to start session")
+		return
+	}
+	writeJSON(w, status, sessionResponse{
+		Token:     token,
+		ChatID:    claims.ChatID,
+		UserID:    claims.UserID,
+		ExpiresAt: time.Now().Add(h.tokenTTL),
+	})
+}
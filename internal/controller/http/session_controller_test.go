@@ -0,0 +1,108 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/auth"
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+// countingSessions starts sessions for consecutive web chats.
+type countingSessions struct {
+	started int
+	err     error
+}
+
+func (s *countingSessions) Start(context.Context) (*entity.WebSession, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.started++
+	chatID := entity.WebChatIDBase + 100 + int64(s.started)
+	return &entity.WebSession{ChatID: chatID, UserID: chatID}, nil
+}
+
+func TestSessionStart(t *testing.T) {
+	const sessionChat = entity.WebChatIDBase + 7
+	signer := auth.NewTokenSigner("secret")
+	sign := func(signer *auth.TokenSigner, claims auth.Claims, ttl time.Duration) string {
+		token, err := signer.Sign(claims, ttl)
+		require.NoError(t, err)
+		return token
+	}
+	session := auth.Claims{Subject: webSessionSubject, ChatID: sessionChat, UserID: sessionChat}
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		limiter    usecase.RateLimiter
+		startErr   error
+		wantStatus int
+		// wantChatID is the chat of the returned token, zero for a new one.
+		wantChatID int64
+	}{
+		{name: "new visitor", wantStatus: http.StatusCreated},
+		{name: "empty JSON body", body: `{}`, wantStatus: http.StatusCreated},
+		{name: "renewal", token: sign(signer, session, time.Minute), wantStatus: http.StatusOK, wantChatID: sessionChat},
+		{name: "renewal is not rate limited", token: sign(signer, session, time.Minute), limiter: exhaustedLimiter{}, wantStatus: http.StatusOK, wantChatID: sessionChat},
+		{name: "expired session", token: sign(signer, session, -time.Minute), wantStatus: http.StatusCreated},
+		{name: "session signed with another secret", token: sign(auth.NewTokenSigner("other"), session, time.Minute), wantStatus: http.StatusCreated},
+		{
+			name:       "integration token is not renewed",
+			token:      sign(signer, auth.Claims{ChatID: sessionChat, UserID: 9}, time.Minute),
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "session token without chat",
+			token:      sign(signer, auth.Claims{Subject: webSessionSubject}, time.Minute),
+			wantStatus: http.StatusCreated,
+		},
+		{name: "new session rate limited", limiter: exhaustedLimiter{}, wantStatus: http.StatusTooManyRequests},
+		{name: "unknown field", body: `{"chat_id": 7}`, wantStatus: http.StatusBadRequest},
+		{name: "start fails", startErr: errors.New("database down"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := &countingSessions{err: tt.startErr}
+			h := NewSessionController(sessions, signer, time.Hour, tt.limiter)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions", strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			h.handleStart(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			if rec.Code != http.StatusOK && rec.Code != http.StatusCreated {
+				assert.Zero(t, sessions.started)
+				return
+			}
+			var response sessionResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			if tt.wantChatID != 0 {
+				assert.Zero(t, sessions.started, "renewal started a new session")
+				assert.Equal(t, tt.wantChatID, response.ChatID)
+			} else {
+				assert.Equal(t, 1, sessions.started)
+				assert.Equal(t, entity.WebChatIDBase+101, response.ChatID)
+			}
+			assert.WithinDuration(t, time.Now().Add(time.Hour), response.ExpiresAt, time.Minute)
+
+			claims, err := signer.Verify(response.Token)
+			require.NoError(t, err)
+			assert.Equal(t, auth.Claims{Subject: webSessionSubject, ChatID: response.ChatID, UserID: response.UserID}, auth.Claims{Subject: claims.Subject, ChatID: claims.ChatID, UserID: claims.UserID})
+		})
+	}
+}
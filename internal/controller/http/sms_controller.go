@@ -0,0 +1,137 @@
+package http
+
+import (
+	"encoding/xml"
+	"errors"
+	"log"
+	"net/http"
+
+	"smb-chatbot/internal/entity"
+	gwMessenger "smb-chatbot/internal/gateway/messenger"
+	"smb-chatbot/internal/usecase"
+)
+
+const twilioSignatureHeader = "X-Twilio-Signature"
+
+type SMSController struct {
+	inbound           usecase.InboundService
+	subscriptions     usecase.SubscriptionService
+	deliveries        usecase.DeliveryTracker
+	authToken         string
+	webhookURL        string
+	statusCallbackURL string
+}
+
+// NewSMSController creates the Twilio webhook handlers. webhookURL and
+// statusCallbackURL must be the exact public URLs configured in Twilio, as
+// they are part of the signature; when empty they are derived from the request.
+func NewSMSController(is usecase.InboundService, ss usecase.SubscriptionService, dt usecase.DeliveryTracker, authToken, webhookURL, statusCallbackURL string) *SMSController {
+	return &SMSController{
+		inbound:           is,
+		subscriptions:     ss,
+		deliveries:        dt,
+		authToken:         authToken,
+		webhookURL:        webhookURL,
+		statusCallbackURL: statusCallbackURL,
+	}
+}
+
+type twiMLResponse struct {
+	XMLName xml.Name `xml:"Response"`
+	Message string   `xml:"Message,omitempty"`
+}
+
+func (h *SMSController) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form payload", http.StatusBadRequest)
+		return
+	}
+	if !gwMessenger.VerifyTwilioSignature(h.authToken, requestURL(r, h.webhookURL), r.PostForm, r.Header.Get(twilioSignatureHeader)) {
+		log.Println("HANDLER: Rejected SMS webhook request with invalid signature")
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	input, ok := gwMessenger.TwilioInput(r.PostForm)
+	if !ok {
+		writeTwiML(w, "")
+		return
+	}
+	log.Printf("HANDLER: Received SMS %s for chat %d", input.MessageID, input.ChatID)
+
+	reply, handled, err := h.subscriptions.HandleKeyword(r.Context(), entity.ChannelSMS, input.ChatID, input.Text)
+	if err != nil {
+		log.Printf("ERROR: Failed to handle SMS keyword for chat %d: %v", input.ChatID, err)
+		http.Error(w, "Failed to process webhook", http.StatusInternalServerError)
+		return
+	}
+	if handled {
+		// The confirmation goes back inline; after STOP the outbox would refuse it.
+		writeTwiML(w, reply)
+		return
+	}
+
+	if _, err := h.inbound.Submit(r.Context(), input); err != nil {
+		log.Printf("ERROR: Failed to handle SMS %s for chat %d: %v", input.MessageID, input.ChatID, err)
+		// Twilio retries on failure; the MessageSid keeps that idempotent.
+		status := http.StatusInternalServerError
+		if errors.Is(err, usecase.ErrChatBusy) {
+			status = http.StatusTooManyRequests
+		}
+		http.Error(w, "Failed to process webhook", status)
+		return
+	}
+	// Replies are delivered through the outbox, not inline.
+	writeTwiML(w, "")
+}
+
+// handleStatusCallback records the delivery status updates Twilio posts for
+// messages sent with a StatusCallback URL.
+func (h *SMSController) handleStatusCallback(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form payload", http.StatusBadRequest)
+		return
+	}
+	if !gwMessenger.VerifyTwilioSignature(h.authToken, requestURL(r, h.statusCallbackURL), r.PostForm, r.Header.Get(twilioSignatureHeader)) {
+		log.Println("HANDLER: Rejected SMS status callback with invalid signature")
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	receipt, ok := gwMessenger.TwilioReceipt(r.PostForm)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := h.deliveries.RecordReceipt(r.Context(), receipt); err != nil {
+		log.Printf("ERROR: Failed to record SMS '%s' receipt for message %s: %v", receipt.Status, receipt.ExternalID, err)
+		http.Error(w, "Failed to process status callback", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestURL returns configured, or the URL of r as the client sent it.
+func requestURL(r *http.Request, configured string) string {
+	if configured != "" {
+		return configured
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+func writeTwiML(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/xml")
+	_, _ = w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(twiMLResponse{Message: message}); err != nil {
+		log.Printf("ERROR: Failed to encode TwiML response: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// noKeywords treats every message as a regular one.
+type noKeywords struct{}
+
+func (noKeywords) HandleKeyword(context.Context, string, int64, string) (string, bool, error) {
+	return "", false, nil
+}
+
+func twilioSignature(authToken, webhookURL string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	data := webhookURL
+	for _, k := range keys {
+		data += k + form.Get(k)
+	}
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestSMSWebhookSignatures(t *testing.T) {
+	const (
+		publicWebhookURL = "https://bot.example.com/api/sms/webhook"
+		publicStatusURL  = "https://bot.example.com/api/sms/status"
+	)
+	message := url.Values{"MessageSid": {"SM1"}, "From": {"+15551234567"}, "Body": {"hi"}}
+	status := url.Values{"MessageSid": {"SM2"}, "MessageStatus": {"delivered"}}
+
+	tests := []struct {
+		name string
+		path string
+		form url.Values
+		// configured is the public URL the controller is set up with, empty
+		// to derive it from the request.
+		configured    string
+		signedURL     string
+		signedToken   string
+		proto         string
+		wantStatus    int
+		wantSubmitted int
+		wantReceipts  int
+	}{
+		{name: "message", path: "/api/sms/webhook", form: message, configured: publicWebhookURL, signedURL: publicWebhookURL, signedToken: "auth",
+			wantStatus: http.StatusOK, wantSubmitted: 1},
+		{name: "message signed with another token", path: "/api/sms/webhook", form: message, configured: publicWebhookURL, signedURL: publicWebhookURL, signedToken: "other",
+			wantStatus: http.StatusForbidden},
+		{name: "message signed for another URL", path: "/api/sms/webhook", form: message, configured: publicWebhookURL, signedURL: "https://evil.example.com/api/sms/webhook", signedToken: "auth",
+			wantStatus: http.StatusForbidden},
+		{name: "message URL derived from proxy headers", path: "/api/sms/webhook", form: message, signedURL: publicWebhookURL, signedToken: "auth", proto: "https",
+			wantStatus: http.StatusOK, wantSubmitted: 1},
+		{name: "unsigned message", path: "/api/sms/webhook", form: message, configured: publicWebhookURL,
+			wantStatus: http.StatusForbidden},
+		{name: "status", path: "/api/sms/status", form: status, configured: publicStatusURL, signedURL: publicStatusURL, signedToken: "auth",
+			wantStatus: http.StatusNoContent, wantReceipts: 1},
+		{name: "status signed with another token", path: "/api/sms/status", form: status, configured: publicStatusURL, signedURL: publicStatusURL, signedToken: "other",
+			wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inbound := &recordingInbound{}
+			deliveries := &recordingDeliveries{}
+			h := NewSMSController(inbound, noKeywords{}, deliveries, "auth", tt.configured, tt.configured)
+
+			req := httptest.NewRequest(http.MethodPost, "http://bot.example.com"+tt.path, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if tt.signedToken != "" {
+				req.Header.Set(twilioSignatureHeader, twilioSignature(tt.signedToken, tt.signedURL, tt.form))
+			}
+			rec := httptest.NewRecorder()
+			if tt.path == "/api/sms/status" {
+				h.handleStatusCallback(rec, req)
+			} else {
+				h.handleWebhook(rec, req)
+			}
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Len(t, inbound.submitted, tt.wantSubmitted)
+			assert.Len(t, deliveries.receipts, tt.wantReceipts)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	gwMessenger "smb-chatbot/internal/gateway/messenger"
+	"smb-chatbot/internal/usecase"
+)
+
+const telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+type TelegramController struct {
+	inbound     usecase.InboundService
+	secretToken string
+}
+
+func NewTelegramController(is usecase.InboundService, secretToken string) *TelegramController {
+	return &TelegramController{
+		inbound:     is,
+		secretToken: secretToken,
+	}
+}
+
+func (h *TelegramController) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	got := r.Header.Get(telegramSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(h.secretToken)) != 1 {
+		log.Println("HANDLER: Rejected Telegram webhook request with invalid secret token")
+		http.Error(w, "Invalid secret token", http.StatusUnauthorized)
+		return
+	}
+
+	var update gwMessenger.TelegramUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&update); err != nil {
+		http.Error(w, "Invalid update payload", http.StatusBadRequest)
+		return
+	}
+	log.Printf("HANDLER: Received Telegram update %d", update.UpdateID)
+
+	input, ok := update.ToInput()
+	if !ok {
+		// Acknowledge updates we do not handle so Telegram does not redeliver them.
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	_, err := h.inbound.Submit(r.Context(), input)
+	if errors.Is(err, usecase.ErrChannelMismatch) {
+		// Redelivery cannot help, so the update is acknowledged and dropped.
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to handle Telegram update %d for chat %d: %v", update.UpdateID, input.ChatID, err)
+		// A non-2xx status makes Telegram redeliver; the message ID keeps that idempotent.
+		status := http.StatusInternalServerError
+		if errors.Is(err, usecase.ErrChatBusy) {
+			status = http.StatusTooManyRequests
+		}
+		http.Error(w, "Failed to process update", status)
+		return
+	}
+	if update.CallbackQuery != nil {
+		// Telegram executes a method returned in the webhook response, which
+		// saves an API call to clear the button's loading indicator.
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"method":            "answerCallbackQuery",
+			"callback_query_id": update.CallbackQuery.ID,
+		})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"smb-chatbot/internal/usecase"
+)
+
+// recordingInbound records submitted messages and answers them with err.
+type recordingInbound struct {
+	usecase.InboundService
+	submitted []usecase.HandleMessageInput
+	err       error
+}
+
+func (s *recordingInbound) Submit(_ context.Context, input usecase.HandleMessageInput) (usecase.SubmitResult, error) {
+	s.submitted = append(s.submitted, input)
+	return usecase.SubmitResult{}, s.err
+}
+
+func TestTelegramWebhook(t *testing.T) {
+	const update = `{"update_id": 1, "message": {"message_id": 5, "from": {"id": 7, "first_name": "Ann"}, "chat": {"id": 42}, "text": "hi"}}`
+	tests := []struct {
+		name          string
+		secret        string
+		body          string
+		submitErr     error
+		wantStatus    int
+		wantSubmitted int
+	}{
+		{name: "valid secret", secret: "s3cret", body: update, wantStatus: http.StatusOK, wantSubmitted: 1},
+		{name: "missing secret", body: update, wantStatus: http.StatusUnauthorized},
+		{name: "wrong secret", secret: "guess", body: update, wantStatus: http.StatusUnauthorized},
+		{name: "secret prefix", secret: "s3c", body: update, wantStatus: http.StatusUnauthorized},
+		{name: "invalid payload", secret: "s3cret", body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "unhandled update", secret: "s3cret", body: `{"update_id": 2}`, wantStatus: http.StatusOK},
+		{name: "chat busy", secret: "s3cret", body: update, submitErr: usecase.ErrChatBusy, wantStatus: http.StatusTooManyRequests, wantSubmitted: 1},
+		{name: "channel mismatch", secret: "s3cret", body: update, submitErr: usecase.ErrChannelMismatch, wantStatus: http.StatusOK, wantSubmitted: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inbound := &recordingInbound{err: tt.submitErr}
+			h := NewTelegramController(inbound, "s3cret")
+
+			req := httptest.NewRequest(http.MethodPost, "/api/telegram/webhook", strings.NewReader(tt.body))
+			if tt.secret != "" {
+				req.Header.Set(telegramSecretHeader, tt.secret)
+			}
+			rec := httptest.NewRecorder()
+			h.handleWebhook(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Len(t, inbound.submitted, tt.wantSubmitted)
+		})
+	}
+}
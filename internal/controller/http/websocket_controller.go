@@ -0,0 +1,275 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"smb-chatbot/internal/auth"
+	"smb-chatbot/internal/entity"
+	gwMessenger "smb-chatbot/internal/gateway/messenger"
+	"smb-chatbot/internal/usecase"
+	"smb-chatbot/internal/websocket"
+)
+
+const (
+	webSocketMaxMessage   = 16 << 10
+	webSocketPingInterval = 30 * time.Second
+	// webSocketReadTimeout must exceed the ping interval, as pongs count as reads.
+	webSocketReadTimeout = 75 * time.Second
+)
+
+type WebSocketController struct {
+	inbound    usecase.InboundService
+	deliveries usecase.DeliveryTracker
+	hub        *gwMessenger.WebSocketHub
+	signer     *auth.TokenSigner
+	tokenTTL   time.Duration
+	limiter    usecase.RateLimiter
+}
+
+// NewWebSocketController creates the web chat handlers. Messages are not
+// rate limited if rl is nil.
+func NewWebSocketController(is usecase.InboundService, dt usecase.DeliveryTracker, hub *gwMessenger.WebSocketHub, signer *auth.TokenSigner, tokenTTL time.Duration, rl usecase.RateLimiter) *WebSocketController {
+	return &WebSocketController{
+		inbound:    is,
+		deliveries: dt,
+		hub:        hub,
+		signer:     signer,
+		tokenTTL:   tokenTTL,
+		limiter:    rl,
+	}
+}
+
+type webSocketTokenRequest struct {
+	ChatID int64 `json:"chat_id"`
+	UserID int64 `json:"user_id"`
+}
+
+type webSocketTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// webSocketClientMessage is a client-to-server frame. A "message" carries
+// the customer's text and the IDs of photos uploaded before; ClientMessageID makes resending after a reconnect
+// idempotent and Payload is set when the customer tapped a quick reply.
+// "delivered" and "read" acknowledge the bot message with MessageID.
+type webSocketClientMessage struct {
+	Type            string   `json:"type"`
+	Text            string   `json:"text"`
+	Payload         string   `json:"payload"`
+	ClientMessageID string   `json:"client_message_id"`
+	MessageID       string   `json:"message_id"`
+	AttachmentIDs   []string `json:"attachment_ids"`
+}
+
+func (h *WebSocketController) handleIssueToken(w http.ResponseWriter, r *http.Request) {
+	var req webSocketTokenRequest
+	if !decodeJSON(w, r, 1<<10, &req) {
+		return
+	}
+	if req.ChatID == 0 || req.UserID == 0 {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Required fields: chat_id (number), user_id (number)")
+		return
+	}
+	// The ranges above are reserved for the chats of other channels.
+	if req.ChatID < 0 || req.ChatID >= entity.ChatIDRangeSize {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("chat_id must be between 1 and %d", entity.ChatIDRangeSize-1))
+		return
+	}
+
+	token, err := h.signer.Sign(auth.Claims{ChatID: req.ChatID, UserID: req.UserID}, h.tokenTTL)
+	if err != nil {
+		log.Printf("ERROR: Failed to sign WebSocket token for chat %d: %v", req.ChatID, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Failed to issue token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(webSocketTokenResponse{Token: token, ExpiresAt: time.Now().Add(h.tokenTTL)}); err != nil {
+		log.Printf("ERROR: Failed to encode WebSocket token response: %v", err)
+	}
+}
+
+// handleConnect upgrades GET /api/ws?token=...&epoch=E&last_seq=N. Browsers cannot
+// set headers on WebSocket requests, so the token travels in the query.
+func (h *WebSocketController) handleConnect(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.signer.Verify(r.URL.Query().Get("token"))
+	if err != nil || claims.ChatID == 0 {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "Invalid or expired token")
+		return
+	}
+	var lastSeq int64
+	if v := r.URL.Query().Get("last_seq"); v != "" {
+		lastSeq, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || lastSeq < 0 {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "last_seq must be a non-negative number")
+			return
+		}
+	}
+
+	conn, err := websocket.Upgrade(w, r, webSocketMaxMessage)
+	if err != nil {
+		log.Printf("HANDLER: WebSocket upgrade for chat %d failed: %v", claims.ChatID, err)
+		return
+	}
+	epoch := r.URL.Query().Get("epoch")
+	log.Printf("HANDLER: WebSocket connected for chat %d (epoch %q, last_seq %d)", claims.ChatID, epoch, lastSeq)
+
+	sub := h.hub.Subscribe(claims.ChatID, epoch, lastSeq)
+	done := make(chan struct{})
+	go h.writeLoop(conn, sub, done)
+
+	h.readLoop(r, conn, claims)
+
+	close(done)
+	h.hub.Unsubscribe(sub)
+	conn.Close(websocket.CloseNormal, "")
+	log.Printf("HANDLER: WebSocket disconnected for chat %d", claims.ChatID)
+}
+
+func (h *WebSocketController) writeLoop(conn *websocket.Conn, sub *gwMessenger.WebSocketSubscription, done <-chan struct{}) {
+	ticker := time.NewTicker(webSocketPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				// The hub dropped the subscription: the client fell behind or
+				// the backlog was lost.
+				conn.Close(websocket.CloseGoingAway, "reconnect to resume")
+				return
+			}
+			payload, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("ERROR: Failed to encode WebSocket event: %v", err)
+				continue
+			}
+			if err := conn.WriteMessage(string(payload)); err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ticker.C:
+			if err := conn.Ping(); err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+func (h *WebSocketController) readLoop(r *http.Request, conn *websocket.Conn, claims auth.Claims) {
+	for {
+		raw, err := conn.ReadMessage(webSocketReadTimeout)
+		if err != nil {
+			if !errors.Is(err, websocket.ErrClosed) {
+				log.Printf("HANDLER: WebSocket read for chat %d ended: %v", claims.ChatID, err)
+			}
+			return
+		}
+
+		var msg webSocketClientMessage
+		err = json.Unmarshal([]byte(raw), &msg)
+		if err == nil && (msg.Type == entity.OutboxStatusDelivered || msg.Type == entity.OutboxStatusRead) {
+			h.recordReceipt(r, conn, claims, msg)
+			continue
+		}
+		if err != nil || msg.Type != "message" || (msg.Text == "" && len(msg.AttachmentIDs) == 0) || len(msg.AttachmentIDs) > maxMessageAttachments || len(msg.ClientMessageID) > 200 || len(msg.Payload) > 255 {
+			h.sendError(conn, "Invalid frame. Expected {\"type\":\"message\",\"text\":\"...\"}")
+			continue
+		}
+
+		input := usecase.HandleMessageInput{
+			ChatID:        claims.ChatID,
+			UserID:        claims.UserID,
+			Text:          msg.Text,
+			Channel:       entity.ChannelWebSocket,
+			Payload:       msg.Payload,
+			AttachmentIDs: msg.AttachmentIDs,
+		}
+		if msg.ClientMessageID != "" {
+			input.MessageID = "ws-" + msg.ClientMessageID
+		}
+
+		if !h.allowFrame(r, conn, claims) {
+			continue
+		}
+
+		// The reply reaches the client through the hub, which ends the typing indicator.
+		h.hub.SetTyping(claims.ChatID, true)
+		if _, err := h.inbound.Submit(r.Context(), input); err != nil {
+			log.Printf("ERROR: Failed to handle WebSocket message for chat %d: %v", claims.ChatID, err)
+			h.hub.SetTyping(claims.ChatID, false)
+			if errors.Is(err, usecase.ErrChatBusy) {
+				h.sendError(conn, "Chat is busy processing a previous message, please retry")
+			} else if errors.Is(err, usecase.ErrChannelMismatch) {
+				h.sendError(conn, "This chat belongs to another channel")
+			} else if errors.Is(err, usecase.ErrInvalidAttachment) {
+				h.sendError(conn, "attachment_ids must name photos uploaded to this chat and not yet sent")
+			} else {
+				h.sendError(conn, "Failed to process message")
+			}
+		}
+	}
+}
+
+// recordReceipt applies a client acknowledgement. Receipts are restricted to
+// bot messages of the token's chat, so a client can neither mark other
+// customers' messages nor store receipts for made-up IDs.
+func (h *WebSocketController) recordReceipt(r *http.Request, conn *websocket.Conn, claims auth.Claims, msg webSocketClientMessage) {
+	if msg.MessageID == "" || len(msg.MessageID) > 64 {
+		h.sendError(conn, "Invalid receipt. Expected {\"type\":\"read\",\"message_id\":\"...\"}")
+		return
+	}
+	receipt := entity.DeliveryReceipt{
+		ExternalID: msg.MessageID,
+		ChatID:     claims.ChatID,
+		Status:     msg.Type,
+		At:         time.Now(),
+	}
+	err := h.deliveries.RecordClientReceipt(r.Context(), receipt)
+	if errors.Is(err, usecase.ErrOutboxMessageNotFound) {
+		h.sendError(conn, "Unknown message_id")
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to record WebSocket '%s' receipt for chat %d: %v", msg.Type, claims.ChatID, err)
+	}
+}
+
+// allowFrame applies the rate limits to a message frame and tells the client
+// when it may send again if the frame is rejected.
+func (h *WebSocketController) allowFrame(r *http.Request, conn *websocket.Conn, claims auth.Claims) bool {
+	if h.limiter == nil {
+		return true
+	}
+	retryAfter, err := h.limiter.Allow(r.Context(), claims.ChatID, claims.UserID, clientIP(r))
+	switch {
+	case errors.Is(err, usecase.ErrChatMuted):
+		log.Printf("HANDLER: Rejected WebSocket message to muted chat %d", claims.ChatID)
+		h.sendError(conn, fmt.Sprintf("Too many messages, the chat is paused for %d seconds", retryAfterSeconds(retryAfter)))
+	case errors.Is(err, usecase.ErrRateLimited):
+		h.sendError(conn, fmt.Sprintf("Too many messages, please retry in %d seconds", retryAfterSeconds(retryAfter)))
+	case err != nil:
+		log.Printf("ERROR: Failed to apply rate limits to chat %d: %v", claims.ChatID, err)
+		h.sendError(conn, "Failed to process message")
+	default:
+		return true
+	}
+	return false
+}
+
+func (h *WebSocketController) sendError(conn *websocket.Conn, message string) {
+	payload, _ := json.Marshal(gwMessenger.WebSocketEvent{Type: gwMessenger.WebSocketEventError, Text: message})
+	if err := conn.WriteMessage(string(payload)); err != nil {
+		log.Printf("HANDLER: Failed to send WebSocket error: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	gwMessenger "smb-chatbot/internal/gateway/messenger"
+	"smb-chatbot/internal/usecase"
+)
+
+type WhatsAppController struct {
+	inbound     usecase.InboundService
+	deliveries  usecase.DeliveryTracker
+	verifyToken string
+	appSecret   string
+}
+
+func NewWhatsAppController(is usecase.InboundService, dt usecase.DeliveryTracker, verifyToken, appSecret string) *WhatsAppController {
+	return &WhatsAppController{
+		inbound:     is,
+		deliveries:  dt,
+		verifyToken: verifyToken,
+		appSecret:   appSecret,
+	}
+}
+
+// handleVerify answers the subscription handshake Meta performs when the webhook is configured.
+func (h *WhatsAppController) handleVerify(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	token := q.Get("hub.verify_token")
+	if q.Get("hub.mode") != "subscribe" || subtle.ConstantTimeCompare([]byte(token), []byte(h.verifyToken)) != 1 {
+		log.Println("HANDLER: Rejected WhatsApp webhook verification with invalid token")
+		http.Error(w, "Verification failed", http.StatusForbidden)
+		return
+	}
+	log.Println("HANDLER: WhatsApp webhook verified")
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = io.WriteString(w, q.Get("hub.challenge"))
+}
+
+func (h *WhatsAppController) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if !gwMessenger.VerifyWhatsAppSignature(h.appSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		log.Println("HANDLER: Rejected WhatsApp webhook request with invalid signature")
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var payload gwMessenger.WhatsAppWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "Invalid webhook payload", http.StatusBadRequest)
+		return
+	}
+
+	for _, input := range payload.Inputs() {
+		log.Printf("HANDLER: Received WhatsApp message %s for chat %d", input.MessageID, input.ChatID)
+		_, err := h.inbound.Submit(r.Context(), input)
+		if errors.Is(err, usecase.ErrChannelMismatch) || errors.Is(err, usecase.ErrInvalidAttachment) {
+			// Redelivery cannot help, so the message is acknowledged and dropped.
+			log.Printf("HANDLER: Dropped WhatsApp message %s for chat %d: %v", input.MessageID, input.ChatID, err)
+			continue
+		}
+		if err != nil {
+			log.Printf("ERROR: Failed to handle WhatsApp message %s for chat %d: %v", input.MessageID, input.ChatID, err)
+			// WhatsApp redelivers on failure; message IDs make already handled messages replay.
+			http.Error(w, "Failed to process webhook", http.StatusInternalServerError)
+			return
+		}
+	}
+	for _, receipt := range payload.Receipts() {
+		if err := h.deliveries.RecordReceipt(r.Context(), receipt); err != nil {
+			log.Printf("ERROR: Failed to record WhatsApp '%s' receipt for message %s: %v", receipt.Status, receipt.ExternalID, err)
+			http.Error(w, "Failed to process webhook", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+// recordingDeliveries records the receipts it is given.
+type recordingDeliveries struct {
+	usecase.DeliveryTracker
+	receipts []entity.DeliveryReceipt
+}
+
+func (d *recordingDeliveries) RecordReceipt(_ context.Context, receipt entity.DeliveryReceipt) error {
+	d.receipts = append(d.receipts, receipt)
+	return nil
+}
+
+func TestWhatsAppWebhookSignature(t *testing.T) {
+	const body = `{"object": "whatsapp_business_account", "entry": [{"changes": [{"field": "messages", "value": {
+		"messages": [{"from": "4915112345678", "id": "wamid.in", "timestamp": "1700000000", "type": "text", "text": {"body": "hi"}}],
+		"statuses": [{"id": "wamid.out", "status": "delivered", "timestamp": "1700000000", "recipient_id": "4915112345678"}]
+	}}]}]}`
+	sign := func(secret, body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	tests := []struct {
+		name       string
+		signature  string
+		body       string
+		wantStatus int
+		wantInputs int
+	}{
+		{name: "valid signature", signature: sign("app-secret", body), body: body, wantStatus: http.StatusOK, wantInputs: 1},
+		{name: "missing signature", body: body, wantStatus: http.StatusUnauthorized},
+		{name: "signed with another secret", signature: sign("other", body), body: body, wantStatus: http.StatusUnauthorized},
+		{name: "body changed after signing", signature: sign("app-secret", body), body: strings.Replace(body, "hi", "bye", 1), wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inbound := &recordingInbound{}
+			deliveries := &recordingDeliveries{}
+			h := NewWhatsAppController(inbound, deliveries, "verify", "app-secret")
+
+			req := httptest.NewRequest(http.MethodPost, "/api/whatsapp/webhook", strings.NewReader(tt.body))
+			if tt.signature != "" {
+				req.Header.Set("X-Hub-Signature-256", tt.signature)
+			}
+			rec := httptest.NewRecorder()
+			h.handleWebhook(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Len(t, inbound.submitted, tt.wantInputs)
+			assert.Len(t, deliveries.receipts, tt.wantInputs)
+		})
+	}
+}
+
+func TestWhatsAppWebhookSubmitErrors(t *testing.T) {
+	const body = `{"object": "whatsapp_business_account", "entry": [{"changes": [{"field": "messages", "value": {
+		"messages": [{"from": "4915112345678", "id": "wamid.in", "timestamp": "1700000000", "type": "text", "text": {"body": "hi"}}],
+		"statuses": [{"id": "wamid.out", "status": "delivered", "timestamp": "1700000000", "recipient_id": "4915112345678"}]
+	}}]}]}`
+	mac := hmac.New(sha256.New, []byte("app-secret"))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name         string
+		err          error
+		wantStatus   int
+		wantReceipts int
+	}{
+		{name: "handled", wantStatus: http.StatusOK, wantReceipts: 1},
+		{name: "chat of another channel", err: usecase.ErrChannelMismatch, wantStatus: http.StatusOK, wantReceipts: 1},
+		{name: "invalid attachment", err: usecase.ErrInvalidAttachment, wantStatus: http.StatusOK, wantReceipts: 1},
+		{name: "chat busy", err: usecase.ErrChatBusy, wantStatus: http.StatusInternalServerError},
+		{name: "database down", err: errors.New("connection refused"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deliveries := &recordingDeliveries{}
+			h := NewWhatsAppController(&recordingInbound{err: tt.err}, deliveries, "verify", "app-secret")
+
+			req := httptest.NewRequest(http.MethodPost, "/api/whatsapp/webhook", strings.NewReader(body))
+			req.Header.Set("X-Hub-Signature-256", signature)
+			rec := httptest.NewRecorder()
+			h.handleWebhook(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Len(t, deliveries.receipts, tt.wantReceipts)
+		})
+	}
+}
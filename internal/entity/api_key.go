@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+// Roles of API keys and dashboard users. Owners manage keys and may delete
+// conversations, agents work with reviews and conversations, and
+// integrations send messages and read reviews on behalf of other systems.
+const (
+	RoleOwner       = "owner"
+	RoleAgent       = "agent"
+	RoleIntegration = "integration"
+)
+
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleOwner, RoleAgent, RoleIntegration:
+		return true
+	}
+	return false
+}
+
+// APIKey grants an integration access to the API with its role. Only a hash
+// of the key is stored.
+type APIKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Prefix is the start of the key, to tell keys apart without the secret.
+	Prefix    string    `json:"prefix"`
+	Role      string    `json:"role"`
+	Hash      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	// LastUsedAt is updated at most once a minute.
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+	RevokedAt  time.Time `json:"revoked_at,omitzero"`
+}
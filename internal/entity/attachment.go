@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+// Attachment is a photo a customer sent. It stays pending until a review of
+// the same chat claims it, so a photo may come before or with the review.
+type Attachment struct {
+	ID          string `json:"id"`
+	ChatID      int64  `json:"chat_id"`
+	ReviewID    string `json:"review_id,omitempty"`
+	FileName    string `json:"file_name,omitempty"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	// Source identifies media imported from a channel, so a redelivered
+	// message does not store the same file twice.
+	Source    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BlobKey is the key of the original file in the blob store.
+func (a Attachment) BlobKey() string {
+	return "attachments/" + a.ID
+}
+
+// ThumbnailKey is the key of the JPEG thumbnail in the blob store.
+func (a Attachment) ThumbnailKey() string {
+	return "attachments/" + a.ID + "_thumb.jpg"
+}
+
+// InboundMedia references a file a customer sent on a messenger channel,
+// e.g. a Telegram file ID. It is downloaded when the message is processed.
+type InboundMedia struct {
+	Ref         string `json:"ref"`
+	ContentType string `json:"content_type,omitempty"`
+}
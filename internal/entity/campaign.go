@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// Campaign proactively asks idle customers for a review.
+type Campaign struct {
+	ID      int64
+	Name    string
+	Message string
+	// MinIdle is how long a conversation must have been idle before it is targeted.
+	MinIdle time.Duration
+	// Cooldown is the minimum time between two campaign messages to the same chat.
+	Cooldown  time.Duration
+	BatchSize int
+}
+
+type CampaignStats struct {
+	CampaignID int64      `json:"campaign_id"`
+	Name       string     `json:"name"`
+	Enabled    bool       `json:"enabled"`
+	Sent       int        `json:"sent"`
+	Responded  int        `json:"responded"`
+	Reviewed   int        `json:"reviewed"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+}
@@ -0,0 +1,29 @@
+package entity
+
+// Messenger channel names. A conversation remembers the channel its customer
+// wrote on, so replies go back the same way.
+const (
+	ChannelMock      = "mock"
+	ChannelTelegram  = "telegram"
+	ChannelWhatsApp  = "whatsapp"
+	ChannelSMS       = "sms"
+	ChannelEmail     = "email"
+	ChannelWebSocket = "websocket"
+	// ChannelAPI marks messages posted by API clients. It is never stored on
+	// a conversation: such chats keep the default channel.
+	ChannelAPI = "api"
+)
+
+// Channels that cannot use the customer's own ID as chat ID get a range of
+// chat IDs each, so chats of different channels never share an ID. Email and
+// web chat sessions number their chats from database sequences starting at
+// their base; WhatsApp and SMS add the phone number to theirs. Telegram chat
+// IDs and IDs chosen by API clients must stay below ChatIDRangeSize. All IDs
+// stay below 2^53, so JavaScript clients read them exactly.
+const (
+	ChatIDRangeSize    int64 = 1_000_000_000_000_000
+	EmailChatIDBase          = 1 * ChatIDRangeSize
+	WebChatIDBase            = 2 * ChatIDRangeSize
+	WhatsAppChatIDBase       = 3 * ChatIDRangeSize
+	SMSChatIDBase            = 4 * ChatIDRangeSize
+)
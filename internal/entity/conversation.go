@@ -3,16 +3,47 @@ package entity
 import "time"
 
 type Conversation struct {
-	ChatID            int64
-	UserID            int64
-	State             string
-	LastInteractionAt time.Time
+	ChatID            int64     `json:"chat_id"`
+	UserID            int64     `json:"user_id"`
+	State             string    `json:"state"`
+	LastInteractionAt time.Time `json:"last_interaction_at"`
+	// ReminderSentAt is zero until a review reminder was sent for the current AwaitingReview state.
+	ReminderSentAt time.Time `json:"reminder_sent_at,omitzero"`
+	// Channel is the messenger the customer last wrote on, empty for the default channel.
+	Channel string `json:"channel,omitempty"`
+	// LastInboundAt is when the customer last wrote, zero if they never did.
+	LastInboundAt time.Time `json:"last_inbound_at,omitzero"`
 }
 
 type HistoryEntry struct {
+	// ID increases with every message of all chats and serves as page cursor.
+	ID            int64     `json:"id"`
 	IsUserMessage bool      `json:"is_user_message"`
 	Text          string    `json:"text"`
 	Timestamp     time.Time `json:"timestamp"`
+	// MessageID links a bot message to its outbox message, whose delivery
+	// status is reported in Status. Both are empty for customer messages.
+	MessageID string `json:"message_id,omitempty"`
+	Status    string `json:"status,omitempty"`
+}
+
+// HistoryRange selects messages of a chat by ID. Zero IDs leave the range
+// open. Limit messages are taken from the AfterID end if it is set, and from
+// the newest end otherwise.
+type HistoryRange struct {
+	AfterID  int64
+	BeforeID int64
+	Limit    int
+}
+
+// ConversationTransition records a state change that did not come from a customer message.
+type ConversationTransition struct {
+	ID        int64     `json:"id"`
+	ChatID    int64     `json:"chat_id"`
+	FromState string    `json:"from_state"`
+	ToState   string    `json:"to_state"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 const (
@@ -20,6 +51,33 @@ const (
 	StateAwaitingReview = "AwaitingReview"
 )
 
+const (
+	TransitionReasonIdleTimeout = "idle_timeout"
+	TransitionReasonCampaign    = "campaign"
+	// TransitionReasonAdmin marks states set through the conversations API.
+	TransitionReasonAdmin  = "admin"
+	TransitionReasonClosed = "closed"
+)
+
+func IsValidState(state string) bool {
+	return state == StateIdle || state == StateAwaitingReview
+}
+
+// ConversationFilter selects conversations. Zero fields do not filter.
+type ConversationFilter struct {
+	State string
+	// InactiveSince selects conversations without interaction since then.
+	InactiveSince time.Time
+	UserID        int64
+}
+
+// ConversationCursor is the position after which the next page of a
+// conversation list starts, which is ordered by last interaction.
+type ConversationCursor struct {
+	LastInteractionAt time.Time `json:"t"`
+	ChatID            int64     `json:"c"`
+}
+
 func NewConversation(chatID, userID int64) *Conversation {
 	return &Conversation{
 		ChatID:            chatID,
@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+// EmailThread links a customer's email address to a chat and remembers the
+// headers needed to reply within the same thread.
+type EmailThread struct {
+	ChatID  int64
+	Address string
+	Name    string
+	Subject string
+	// LastMessageID is the Message-ID of the latest email in the thread,
+	// used as In-Reply-To of the next reply.
+	LastMessageID string
+	// References is the space-separated References header chain.
+	References string
+	UpdatedAt  time.Time
+}
+
+// InboundEmail is a parsed customer email, with quotes and signature removed.
+type InboundEmail struct {
+	From       string
+	FromName   string
+	Subject    string
+	MessageID  string
+	InReplyTo  string
+	References []string
+	Text       string
+	// Images are the image parts of the email, inline or attached.
+	Images []EmailImage
+}
+
+type EmailImage struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
@@ -0,0 +1,127 @@
+package entity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QuickReply is a one-tap answer. Tapping it sends Payload back to the bot,
+// together with Title as the visible message text.
+type QuickReply struct {
+	Title   string `json:"title"`
+	Payload string `json:"payload"`
+}
+
+// URLButton opens URL when tapped.
+type URLButton struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// OutboundMessage is a bot message with optional interactive elements.
+// Channels render them natively where they can and send FallbackText otherwise.
+type OutboundMessage struct {
+	// ID identifies the message once it is queued; channels that report
+	// receipts by our ID, like the web chat, pass it on to the client.
+	ID           string       `json:"id,omitempty"`
+	Text         string       `json:"text"`
+	QuickReplies []QuickReply `json:"quick_replies,omitempty"`
+	Buttons      []URLButton  `json:"buttons,omitempty"`
+	// ReviewRequest marks messages the bot sends on its own to ask for a
+	// review, which channels may replace with an approved template.
+	ReviewRequest bool `json:"-"`
+}
+
+func TextMessage(text string) OutboundMessage {
+	return OutboundMessage{Text: text}
+}
+
+func (m OutboundMessage) IsRich() bool {
+	return len(m.QuickReplies) > 0 || len(m.Buttons) > 0
+}
+
+// FallbackText renders the message for text-only channels: quick replies
+// become a numbered list the customer can answer by number (see
+// MatchQuickReply) and buttons are spelled out as links.
+func (m OutboundMessage) FallbackText() string {
+	if !m.IsRich() {
+		return m.Text
+	}
+	var b strings.Builder
+	b.WriteString(m.Text)
+	if len(m.QuickReplies) > 0 {
+		b.WriteString("\n\nReply with:")
+		for i, qr := range m.QuickReplies {
+			fmt.Fprintf(&b, "\n%d. %s", i+1, qr.Title)
+		}
+	}
+	b.WriteString(m.buttonLinks())
+	return b.String()
+}
+
+// TextWithLinks is the text followed by the URL buttons as plain links, for
+// channels that render quick replies but not URL buttons.
+func (m OutboundMessage) TextWithLinks() string {
+	return m.Text + m.buttonLinks()
+}
+
+func (m OutboundMessage) buttonLinks() string {
+	var b strings.Builder
+	for _, button := range m.Buttons {
+		if strings.Contains(m.Text, button.URL) {
+			continue
+		}
+		fmt.Fprintf(&b, "\n\n%s: %s", button.Title, button.URL)
+	}
+	return b.String()
+}
+
+// MatchQuickReply maps a typed answer to one of the offered quick replies,
+// either by its number in the fallback list or by its title.
+func MatchQuickReply(options []QuickReply, text string) (QuickReply, bool) {
+	text = strings.TrimSpace(text)
+	if n, err := strconv.Atoi(strings.TrimSuffix(text, ".")); err == nil && n >= 1 && n <= len(options) {
+		return options[n-1], true
+	}
+	for _, qr := range options {
+		if strings.EqualFold(text, qr.Title) {
+			return qr, true
+		}
+	}
+	return QuickReply{}, false
+}
+
+// Quick reply payloads understood by the review flow.
+const (
+	PayloadRatingPrefix = "rating:"
+	PayloadNotNow       = "review:not_now"
+)
+
+// RatingPayload returns the payload of the n-star rating quick reply.
+func RatingPayload(stars int) string {
+	return PayloadRatingPrefix + strconv.Itoa(stars)
+}
+
+// ParseRatingPayload returns the star rating a payload stands for.
+func ParseRatingPayload(payload string) (int, bool) {
+	v, ok := strings.CutPrefix(payload, PayloadRatingPrefix)
+	if !ok {
+		return 0, false
+	}
+	stars, err := strconv.Atoi(v)
+	if err != nil || stars < 1 || stars > 5 {
+		return 0, false
+	}
+	return stars, true
+}
+
+// ReviewRequestQuickReplies offers one-tap star ratings and a way to decline.
+func ReviewRequestQuickReplies() []QuickReply {
+	// Ascending, so that in the numbered fallback "5" means five stars.
+	replies := make([]QuickReply, 0, 6)
+	for stars := 1; stars <= 5; stars++ {
+		replies = append(replies, QuickReply{Title: strings.Repeat("⭐", stars), Payload: RatingPayload(stars)})
+	}
+	return append(replies, QuickReply{Title: "Not now", Payload: PayloadNotNow})
+}
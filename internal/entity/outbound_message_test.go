@@ -0,0 +1,95 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboundMessageFallbackText(t *testing.T) {
+	review := URLButton{Title: "Leave a review", URL: "https://example.com/r/1"}
+	tests := []struct {
+		name          string
+		message       OutboundMessage
+		wantFallback  string
+		wantWithLinks string
+	}{
+		{name: "plain text", message: TextMessage("Thanks!"), wantFallback: "Thanks!", wantWithLinks: "Thanks!"},
+		{
+			name:          "quick replies",
+			message:       OutboundMessage{Text: "Rate us", QuickReplies: []QuickReply{{Title: "Good", Payload: "g"}, {Title: "Bad", Payload: "b"}}},
+			wantFallback:  "Rate us\n\nReply with:\n1. Good\n2. Bad",
+			wantWithLinks: "Rate us",
+		},
+		{
+			name:          "URL button",
+			message:       OutboundMessage{Text: "Thanks!", Buttons: []URLButton{review}},
+			wantFallback:  "Thanks!\n\nLeave a review: https://example.com/r/1",
+			wantWithLinks: "Thanks!\n\nLeave a review: https://example.com/r/1",
+		},
+		{
+			name:          "URL already in the text",
+			message:       OutboundMessage{Text: "Review us at https://example.com/r/1", Buttons: []URLButton{review}},
+			wantFallback:  "Review us at https://example.com/r/1",
+			wantWithLinks: "Review us at https://example.com/r/1",
+		},
+		{
+			name:          "quick replies and URL button",
+			message:       OutboundMessage{Text: "Thanks!", QuickReplies: []QuickReply{{Title: "Done", Payload: "d"}}, Buttons: []URLButton{review}},
+			wantFallback:  "Thanks!\n\nReply with:\n1. Done\n\nLeave a review: https://example.com/r/1",
+			wantWithLinks: "Thanks!\n\nLeave a review: https://example.com/r/1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantFallback, tt.message.FallbackText())
+			assert.Equal(t, tt.wantWithLinks, tt.message.TextWithLinks())
+		})
+	}
+}
+
+func TestMatchQuickReply(t *testing.T) {
+	options := ReviewRequestQuickReplies()
+	tests := []struct {
+		text        string
+		wantPayload string
+	}{
+		{text: "5", wantPayload: RatingPayload(5)},
+		{text: " 1. ", wantPayload: RatingPayload(1)},
+		{text: "⭐⭐⭐", wantPayload: RatingPayload(3)},
+		{text: "NOT NOW", wantPayload: PayloadNotNow},
+		{text: "6", wantPayload: PayloadNotNow},
+		{text: "7"},
+		{text: "0"},
+		{text: "great"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			qr, ok := MatchQuickReply(options, tt.text)
+			assert.Equal(t, tt.wantPayload != "", ok)
+			assert.Equal(t, tt.wantPayload, qr.Payload)
+		})
+	}
+}
+
+func TestParseRatingPayload(t *testing.T) {
+	tests := []struct {
+		payload   string
+		wantStars int
+	}{
+		{payload: "rating:1", wantStars: 1},
+		{payload: "rating:5", wantStars: 5},
+		{payload: "rating:0"},
+		{payload: "rating:6"},
+		{payload: "rating:x"},
+		{payload: PayloadNotNow},
+		{payload: "5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.payload, func(t *testing.T) {
+			stars, ok := ParseRatingPayload(tt.payload)
+			assert.Equal(t, tt.wantStars != 0, ok)
+			assert.Equal(t, tt.wantStars, stars)
+		})
+	}
+}
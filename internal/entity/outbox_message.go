@@ -0,0 +1,78 @@
+package entity
+
+import "time"
+
+const (
+	OutboxStatusQueued = "queued"
+	OutboxStatusSent   = "sent"
+	// OutboxStatusDelivered and OutboxStatusRead are reported by the channel
+	// after sending, on channels that support receipts.
+	OutboxStatusDelivered = "delivered"
+	OutboxStatusRead      = "read"
+	// OutboxStatusFailed marks a dead-lettered message that ran out of delivery
+	// attempts, or one the channel reported as undeliverable.
+	OutboxStatusFailed = "failed"
+)
+
+// OutboxMessage is an outgoing message written in the same transaction as the
+// turn that produced it and delivered later by the outbox dispatcher.
+type OutboxMessage struct {
+	ID int64
+	// MessageID is the public ID of the message, referenced by history entries.
+	MessageID     string
+	ChatID        int64
+	Text          string
+	QuickReplies  []QuickReply
+	Buttons       []URLButton
+	ReviewRequest bool
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	SentAt        time.Time
+	// ExternalID is the channel's ID of the sent message, which its delivery
+	// receipts refer to. Empty for channels without one.
+	ExternalID  string
+	DeliveredAt time.Time
+	ReadAt      time.Time
+}
+
+func (m *OutboxMessage) Outbound() OutboundMessage {
+	return OutboundMessage{ID: m.MessageID, Text: m.Text, QuickReplies: m.QuickReplies, Buttons: m.Buttons, ReviewRequest: m.ReviewRequest}
+}
+
+// DeliveryReceipt is a status update a channel reported for a sent message.
+type DeliveryReceipt struct {
+	// ExternalID is the channel's ID of the message.
+	ExternalID string
+	// ChatID restricts the update to messages of one chat, when the receipt
+	// comes from the customer's own client. Zero for provider callbacks.
+	ChatID int64
+	Status string
+	// Error describes why delivery failed, for OutboxStatusFailed.
+	Error string
+	At    time.Time
+}
+
+// deliveryLifecycle orders statuses so receipts arriving out of order never
+// move a message backwards, e.g. from read to delivered. A failure report
+// cannot override a confirmed delivery.
+var deliveryLifecycle = []string{
+	OutboxStatusQueued,
+	OutboxStatusSent,
+	OutboxStatusFailed,
+	OutboxStatusDelivered,
+	OutboxStatusRead,
+}
+
+// DeliveryStatusesBefore returns the statuses a message may move to status
+// from. It returns nil for unknown statuses.
+func DeliveryStatusesBefore(status string) []string {
+	for i, s := range deliveryLifecycle {
+		if s == status {
+			return deliveryLifecycle[:i:i]
+		}
+	}
+	return nil
+}
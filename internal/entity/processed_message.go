@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// ProcessedMessage remembers the reply to a client-identified inbound message
+// so retried deliveries can be answered without processing them again.
+type ProcessedMessage struct {
+	ChatID      int64
+	MessageID   string
+	Reply       string
+	ProcessedAt time.Time
+}
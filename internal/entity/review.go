@@ -3,9 +3,94 @@ package entity
 import "time"
 
 type Review struct {
-	ID         string
-	CustomerID int64 `json:"customer_id"`
-	ChatID     int64 `json:"chat_id"`
-	Text       string
+	ID         string `json:"id"`
+	CustomerID int64  `json:"customer_id"`
+	// CustomerName is the name the channel reported for the customer, if any.
+	CustomerName string `json:"customer_name,omitempty"`
+	ChatID       int64  `json:"chat_id"`
+	// Channel is the messenger the review arrived on, empty for the default channel.
+	Channel    string    `json:"channel,omitempty"`
+	Text       string    `json:"text"`
+	Rating     int       `json:"rating"`
 	ReceivedAt time.Time `json:"received_at"`
+	// Attachments are the photos sent with the review.
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Sentiment is derived from the rating, see SentimentForRating.
+func (r Review) Sentiment() string {
+	return SentimentForRating(r.Rating)
+}
+
+const (
+	SentimentPositive = "positive"
+	SentimentNeutral  = "neutral"
+	SentimentNegative = "negative"
+)
+
+// sentimentRatings are the ratings each sentiment covers.
+var sentimentRatings = map[string][2]int{
+	SentimentPositive: {4, 5},
+	SentimentNeutral:  {3, 3},
+	SentimentNegative: {1, 2},
+}
+
+// SentimentForRating maps 4-5 stars to positive, 3 to neutral and 1-2 to
+// negative. Unrated reviews have no sentiment.
+func SentimentForRating(rating int) string {
+	for sentiment, ratings := range sentimentRatings {
+		if rating >= ratings[0] && rating <= ratings[1] {
+			return sentiment
+		}
+	}
+	return ""
+}
+
+// SentimentRatings returns the rating range of sentiment.
+func SentimentRatings(sentiment string) (minRating, maxRating int, ok bool) {
+	ratings, ok := sentimentRatings[sentiment]
+	return ratings[0], ratings[1], ok
+}
+
+// Review list orders. The prefix "-" sorts descending; ties are broken by ID.
+const (
+	ReviewSortNewest     = "-received_at"
+	ReviewSortOldest     = "received_at"
+	ReviewSortRatingDesc = "-rating"
+	ReviewSortRatingAsc  = "rating"
+)
+
+func IsValidReviewSort(sort string) bool {
+	switch sort {
+	case ReviewSortNewest, ReviewSortOldest, ReviewSortRatingDesc, ReviewSortRatingAsc:
+		return true
+	}
+	return false
+}
+
+// ReviewFilter selects reviews. Zero fields do not filter.
+type ReviewFilter struct {
+	// From is inclusive, To exclusive.
+	From       time.Time
+	To         time.Time
+	ChatID     int64
+	CustomerID int64
+	MinRating  int
+	MaxRating  int
+	// Search matches reviews containing the text, ignoring case.
+	Search string
+}
+
+// ReviewCursor is the position after which the next page of a review list
+// starts: the sort key of the last review returned and its ID.
+type ReviewCursor struct {
+	Sort       string    `json:"s"`
+	ReceivedAt time.Time `json:"t,omitempty"`
+	Rating     int       `json:"r,omitempty"`
+	ID         string    `json:"id"`
+}
+
+// CursorAfter returns the cursor continuing a list sorted by sort after r.
+func (r Review) CursorAfter(sort string) ReviewCursor {
+	return ReviewCursor{Sort: sort, ReceivedAt: r.ReceivedAt, Rating: r.Rating, ID: r.ID}
 }
@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PlatformGoogle   = "google"
+	PlatformYelp     = "yelp"
+	PlatformFacebook = "facebook"
+)
+
+// ReviewDestination is a public review platform a business wants happy
+// customers to be sent to. URLTemplate may contain the placeholders
+// {review_id}, {chat_id} and {customer_id}.
+type ReviewDestination struct {
+	ID          int64
+	BusinessID  string
+	Platform    string
+	URLTemplate string
+	Priority    int
+}
+
+// ReviewLink is a tracked redirect handed out to a customer for a review destination.
+type ReviewLink struct {
+	ID         string
+	ReviewID   string
+	ChatID     int64
+	Platform   string
+	TargetURL  string
+	CreatedAt  time.Time
+	ClickCount int
+}
+
+func (d ReviewDestination) BuildURL(review *Review) string {
+	r := strings.NewReplacer(
+		"{review_id}", review.ID,
+		"{chat_id}", strconv.FormatInt(review.ChatID, 10),
+		"{customer_id}", strconv.FormatInt(review.CustomerID, 10),
+	)
+	return r.Replace(d.URLTemplate)
+}
+
+func PlatformDisplayName(platform string) string {
+	switch platform {
+	case PlatformGoogle:
+		return "Google"
+	case PlatformYelp:
+		return "Yelp"
+	case PlatformFacebook:
+		return "Facebook"
+	default:
+		return platform
+	}
+}
@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+// WebSession is an anonymous web chat visitor. Its chat and user ID are
+// assigned by the server and are the same number.
+type WebSession struct {
+	ChatID    int64
+	UserID    int64
+	CreatedAt time.Time
+}
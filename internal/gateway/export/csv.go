@@ -0,0 +1,55 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func newCSVWriter(w io.Writer) usecase.ReviewWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) Write(review entity.Review) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	fields := textFields(review)
+	for i, field := range fields {
+		fields[i] = escapeFormula(field)
+	}
+	return c.w.Write(fields)
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeFormula keeps spreadsheets from evaluating customer text that starts
+// like a formula, by prefixing it with an apostrophe.
+func escapeFormula(field string) string {
+	if field != "" && strings.ContainsRune("=+-@\t\r", rune(field[0])) {
+		return "'" + field
+	}
+	return field
+}
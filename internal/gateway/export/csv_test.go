@@ -0,0 +1,101 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/entity"
+)
+
+func TestLookupFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "csv", want: "csv"},
+		{name: "ndjson", want: "ndjson"},
+		{name: "jsonl", want: "ndjson"},
+		{name: "xlsx", want: "xlsx"},
+		{name: "", wantErr: true},
+		{name: "CSV", wantErr: true},
+		{name: "xls", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := LookupFormat(tt.name)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, format.Name)
+		})
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	receivedAt := time.Date(2026, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		name    string
+		reviews []entity.Review
+		want    [][]string
+	}{
+		{name: "no reviews", want: [][]string{columns}},
+		{
+			name:    "rated review",
+			reviews: []entity.Review{{ID: "r1", ReceivedAt: receivedAt, CustomerID: 7, CustomerName: "Ann", ChatID: 42, Channel: "telegram", Rating: 5, Text: "Great"}},
+			want: [][]string{columns,
+				{"r1", "2026-03-01T08:30:00Z", "7", "Ann", "42", "telegram", "5", entity.SentimentPositive, "Great"}},
+		},
+		{
+			name:    "unrated review on the default channel",
+			reviews: []entity.Review{{ID: "r2", ReceivedAt: receivedAt, CustomerID: 7, ChatID: 42, Text: "Hello"}},
+			want: [][]string{columns,
+				{"r2", "2026-03-01T08:30:00Z", "7", "", "42", "default", "", "", "Hello"}},
+		},
+		{
+			name:    "quotes, commas and line breaks",
+			reviews: []entity.Review{{ID: "r3", ReceivedAt: receivedAt, ChatID: 1, Rating: 3, Text: "Good, \"mostly\"\nwould return"}},
+			want: [][]string{columns,
+				{"r3", "2026-03-01T08:30:00Z", "0", "", "1", "default", "3", entity.SentimentNeutral, "Good, \"mostly\"\nwould return"}},
+		},
+		{
+			name: "formulas are escaped",
+			reviews: []entity.Review{
+				{ID: "r4", ReceivedAt: receivedAt, ChatID: 1, CustomerName: "=HYPERLINK(\"x\")", Text: "+1 great"},
+				{ID: "r5", ReceivedAt: receivedAt, ChatID: 1, CustomerName: "@admin", Text: "-bad"},
+			},
+			want: [][]string{columns,
+				{"r4", "2026-03-01T08:30:00Z", "0", "'=HYPERLINK(\"x\")", "1", "default", "", "", "'+1 great"},
+				{"r5", "2026-03-01T08:30:00Z", "0", "'@admin", "1", "default", "", "", "'-bad"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := newCSVWriter(&buf)
+			for _, review := range tt.reviews {
+				require.NoError(t, w.Write(review))
+			}
+			require.NoError(t, w.Close())
+
+			records, err := csv.NewReader(&buf).ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, records)
+		})
+	}
+}
+
+func TestCSVWriterWritesNothingBeforeFirstReview(t *testing.T) {
+	var buf bytes.Buffer
+	w := newCSVWriter(&buf)
+	assert.Zero(t, buf.Len())
+	require.NoError(t, w.Close())
+	assert.NotZero(t, buf.Len())
+}
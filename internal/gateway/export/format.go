@@ -0,0 +1,91 @@
+// Package export encodes reviews as CSV, JSON Lines or XLSX. All writers
+// stream: every review is written as it arrives.
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	newWriter   func(w io.Writer) usecase.ReviewWriter
+}
+
+var formats = map[string]Format{
+	"csv": {
+		Name:        "csv",
+		ContentType: "text/csv; charset=utf-8",
+		Extension:   ".csv",
+		newWriter:   newCSVWriter,
+	},
+	"ndjson": {
+		Name:        "ndjson",
+		ContentType: "application/x-ndjson",
+		Extension:   ".ndjson",
+		newWriter:   newNDJSONWriter,
+	},
+	"xlsx": {
+		Name:        "xlsx",
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		Extension:   ".xlsx",
+		newWriter:   newXLSXWriter,
+	},
+}
+
+// LookupFormat returns the format with name, one of "csv", "ndjson" and
+// "xlsx". "jsonl" is accepted for "ndjson".
+func LookupFormat(name string) (Format, error) {
+	if name == "jsonl" {
+		name = "ndjson"
+	}
+	format, ok := formats[name]
+	if !ok {
+		return Format{}, fmt.Errorf("unknown export format '%s', expected csv, ndjson or xlsx", name)
+	}
+	return format, nil
+}
+
+// NewWriter returns a writer encoding reviews to w. Nothing is written to w
+// before the first review or Close.
+func (f Format) NewWriter(w io.Writer) usecase.ReviewWriter {
+	return f.newWriter(w)
+}
+
+// columns are the fields of every export, in order.
+var columns = []string{"id", "received_at", "customer_id", "customer_name", "chat_id", "channel", "rating", "sentiment", "text"}
+
+// channelName reports the default channel, which reviews store as empty.
+func channelName(review entity.Review) string {
+	if review.Channel == "" {
+		return "default"
+	}
+	return review.Channel
+}
+
+// textFields returns the columns of review as text, with an empty rating
+// for unrated reviews.
+func textFields(review entity.Review) []string {
+	rating := ""
+	if review.Rating > 0 {
+		rating = strconv.Itoa(review.Rating)
+	}
+	return []string{
+		review.ID,
+		review.ReceivedAt.UTC().Format(time.RFC3339),
+		strconv.FormatInt(review.CustomerID, 10),
+		review.CustomerName,
+		strconv.FormatInt(review.ChatID, 10),
+		channelName(review),
+		rating,
+		review.Sentiment(),
+		review.Text,
+	}
+}
@@ -0,0 +1,53 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+type ndjsonRecord struct {
+	ID           string    `json:"id"`
+	ReceivedAt   time.Time `json:"received_at"`
+	CustomerID   int64     `json:"customer_id"`
+	CustomerName string    `json:"customer_name"`
+	ChatID       int64     `json:"chat_id"`
+	Channel      string    `json:"channel"`
+	// Rating is null for unrated reviews.
+	Rating    *int   `json:"rating"`
+	Sentiment string `json:"sentiment"`
+	Text      string `json:"text"`
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) usecase.ReviewWriter {
+	return &ndjsonWriter{enc: json.NewEncoder(w)}
+}
+
+func (n *ndjsonWriter) Write(review entity.Review) error {
+	record := ndjsonRecord{
+		ID:           review.ID,
+		ReceivedAt:   review.ReceivedAt.UTC(),
+		CustomerID:   review.CustomerID,
+		CustomerName: review.CustomerName,
+		ChatID:       review.ChatID,
+		Channel:      channelName(review),
+		Sentiment:    review.Sentiment(),
+		Text:         review.Text,
+	}
+	if review.Rating > 0 {
+		record.Rating = &review.Rating
+	}
+	// Encode ends every record with a newline.
+	return n.enc.Encode(record)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,161 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+// The static parts of a workbook with a single sheet. Style 1 formats
+// dates, style 2 is the bold header.
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Reviews" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+	{"xl/styles.xml", xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+		`</styleSheet>`},
+}
+
+const (
+	xlsxStyleDate   = 1
+	xlsxStyleHeader = 2
+	// xlsxMaxCellText is the most characters a spreadsheet cell holds.
+	xlsxMaxCellText = 32767
+)
+
+// xlsxColumnWidths are the widths of columns, in characters.
+var xlsxColumnWidths = []int{38, 20, 16, 24, 16, 12, 8, 10, 80}
+
+// xlsxWriter writes a workbook with one sheet, whose rows use inline strings
+// so no shared string table has to be kept in memory.
+type xlsxWriter struct {
+	zip     *zip.Writer
+	sheet   *bufio.Writer
+	started bool
+}
+
+func newXLSXWriter(w io.Writer) usecase.ReviewWriter {
+	return &xlsxWriter{zip: zip.NewWriter(w)}
+}
+
+// start writes the static parts and opens the sheet, which has to be the
+// last part as zip entries are written one after another.
+func (x *xlsxWriter) start() error {
+	if x.started {
+		return nil
+	}
+	x.started = true
+	for _, part := range xlsxParts {
+		f, err := x.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+
+	f, err := x.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	x.sheet = bufio.NewWriter(f)
+	x.sheet.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	x.sheet.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" state="frozen"/></sheetView></sheetViews><cols>`)
+	for i, width := range xlsxColumnWidths {
+		fmt.Fprintf(x.sheet, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, width)
+	}
+	x.sheet.WriteString(`</cols><sheetData><row>`)
+	for _, column := range columns {
+		x.writeString(column, xlsxStyleHeader)
+	}
+	_, err = x.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (x *xlsxWriter) Write(review entity.Review) error {
+	if err := x.start(); err != nil {
+		return err
+	}
+	fields := textFields(review)
+	x.sheet.WriteString(`<row>`)
+	for i, column := range columns {
+		switch {
+		case column == "received_at":
+			x.writeDate(review.ReceivedAt)
+		case column == "rating" && review.Rating > 0:
+			fmt.Fprintf(x.sheet, `<c><v>%d</v></c>`, review.Rating)
+		default:
+			// IDs stay text, as email chat IDs exceed the 15 digits
+			// spreadsheets keep of numbers.
+			x.writeString(fields[i], 0)
+		}
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	if err := x.start(); err != nil {
+		return err
+	}
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
+
+func (x *xlsxWriter) writeString(s string, style int) {
+	if utf8.RuneCountInString(s) > xlsxMaxCellText {
+		s = string([]rune(s)[:xlsxMaxCellText])
+	}
+	if style != 0 {
+		fmt.Fprintf(x.sheet, `<c s="%d" t="inlineStr"><is><t xml:space="preserve">`, style)
+	} else {
+		x.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+	}
+	// EscapeText also replaces characters XML cannot hold.
+	xml.EscapeText(x.sheet, []byte(s))
+	x.sheet.WriteString(`</t></is></c>`)
+}
+
+// writeDate writes t in UTC as a spreadsheet date serial: days since
+// 1899-12-30.
+func (x *xlsxWriter) writeDate(t time.Time) {
+	serial := float64(t.UTC().Unix())/86400 + 25569
+	fmt.Fprintf(x.sheet, `<c s="%d"><v>%s</v></c>`, xlsxStyleDate, strconv.FormatFloat(serial, 'f', -1, 64))
+}
@@ -0,0 +1,147 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/entity"
+)
+
+// xlsxCell is a cell of the sheet: an inline string, or a number in V.
+type xlsxCell struct {
+	Style  int    `xml:"s,attr"`
+	Type   string `xml:"t,attr"`
+	Inline string `xml:"is>t"`
+	Value  string `xml:"v"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX checks that every part of the workbook is well-formed XML and
+// returns the sheet.
+func readXLSX(t *testing.T, data []byte) xlsxSheet {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	var names []string
+	var sheet xlsxSheet
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+		r, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		r.Close()
+
+		decoder := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err, "part %s", f.Name)
+		}
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			require.NoError(t, xml.Unmarshal(content, &sheet))
+		}
+	}
+	assert.Equal(t, []string{
+		"[Content_Types].xml",
+		"_rels/.rels",
+		"xl/workbook.xml",
+		"xl/_rels/workbook.xml.rels",
+		"xl/styles.xml",
+		"xl/worksheets/sheet1.xml",
+	}, names)
+	return sheet
+}
+
+func TestXLSXWriter(t *testing.T) {
+	receivedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		reviews []entity.Review
+		// want are the rows after the header.
+		want [][]xlsxCell
+	}{
+		{name: "no reviews"},
+		{
+			name:    "rated review",
+			reviews: []entity.Review{{ID: "r1", ReceivedAt: receivedAt, CustomerID: 7, CustomerName: "Ann", ChatID: 42, Channel: "telegram", Rating: 5, Text: "Great"}},
+			want: [][]xlsxCell{{
+				{Type: "inlineStr", Inline: "r1"},
+				// 2026-03-01 is day 46082 since 1899-12-30.
+				{Style: xlsxStyleDate, Value: "46082.5"},
+				{Type: "inlineStr", Inline: "7"},
+				{Type: "inlineStr", Inline: "Ann"},
+				{Type: "inlineStr", Inline: "42"},
+				{Type: "inlineStr", Inline: "telegram"},
+				{Value: "5"},
+				{Type: "inlineStr", Inline: entity.SentimentPositive},
+				{Type: "inlineStr", Inline: "Great"},
+			}},
+		},
+		{
+			name:    "unrated review with markup and a long chat ID",
+			reviews: []entity.Review{{ID: "r2", ReceivedAt: receivedAt, ChatID: 9000000000000000001, Text: "<b>A & B</b>\n=SUM(1)"}},
+			want: [][]xlsxCell{{
+				{Type: "inlineStr", Inline: "r2"},
+				{Style: xlsxStyleDate, Value: "46082.5"},
+				{Type: "inlineStr", Inline: "0"},
+				{Type: "inlineStr", Inline: ""},
+				{Type: "inlineStr", Inline: "9000000000000000001"},
+				{Type: "inlineStr", Inline: "default"},
+				{Type: "inlineStr", Inline: ""},
+				{Type: "inlineStr", Inline: ""},
+				{Type: "inlineStr", Inline: "<b>A & B</b>\n=SUM(1)"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := newXLSXWriter(&buf)
+			for _, review := range tt.reviews {
+				require.NoError(t, w.Write(review))
+			}
+			require.NoError(t, w.Close())
+
+			sheet := readXLSX(t, buf.Bytes())
+			require.Len(t, sheet.Rows, len(tt.want)+1)
+			var header []string
+			for _, cell := range sheet.Rows[0].Cells {
+				assert.Equal(t, xlsxStyleHeader, cell.Style)
+				header = append(header, cell.Inline)
+			}
+			assert.Equal(t, columns, header)
+			for i, row := range tt.want {
+				assert.Equal(t, row, sheet.Rows[i+1].Cells)
+			}
+		})
+	}
+}
+
+func TestXLSXWriterCutsLongText(t *testing.T) {
+	var buf bytes.Buffer
+	w := newXLSXWriter(&buf)
+	require.NoError(t, w.Write(entity.Review{ID: "r1", Text: strings.Repeat("ä", xlsxMaxCellText+10)}))
+	require.NoError(t, w.Close())
+
+	sheet := readXLSX(t, buf.Bytes())
+	require.Len(t, sheet.Rows, 2)
+	text := sheet.Rows[1].Cells[len(columns)-1].Inline
+	assert.Equal(t, strings.Repeat("ä", xlsxMaxCellText), text)
+}
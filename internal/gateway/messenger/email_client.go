@@ -0,0 +1,128 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+
+	"github.com/google/uuid"
+)
+
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password are optional; without them no AUTH is attempted,
+	// which suits a local SMTP stand-in.
+	Username    string
+	Password    string
+	FromAddress string
+	FromName    string
+}
+
+// EmailClient sends replies by SMTP. Every reply continues the customer's
+// thread through In-Reply-To and References, so mail clients group it with
+// the rest of the conversation.
+type EmailClient struct {
+	cfg        SMTPConfig
+	threadRepo usecase.EmailThreadRepository
+}
+
+func NewEmailClient(cfg SMTPConfig, etr usecase.EmailThreadRepository) *EmailClient {
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	return &EmailClient{
+		cfg:        cfg,
+		threadRepo: etr,
+	}
+}
+
+func (c *EmailClient) SendMessage(ctx context.Context, chatID int64, text string) error {
+	thread, err := c.threadRepo.FindByChatID(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("failed to find email thread for chat %d: %w", chatID, err)
+	}
+
+	messageID := fmt.Sprintf("%s@%s", uuid.NewString(), c.domain())
+	subject := thread.Subject
+	if subject == "" {
+		subject = "Your feedback"
+	}
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+	references := strings.Fields(thread.References)
+
+	var header bytes.Buffer
+	from := mail.Address{Name: c.cfg.FromName, Address: c.cfg.FromAddress}
+	to := mail.Address{Name: thread.Name, Address: thread.Address}
+	fmt.Fprintf(&header, "From: %s\r\n", from.String())
+	fmt.Fprintf(&header, "To: %s\r\n", to.String())
+	fmt.Fprintf(&header, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&header, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&header, "Message-ID: <%s>\r\n", messageID)
+	if thread.LastMessageID != "" {
+		fmt.Fprintf(&header, "In-Reply-To: <%s>\r\n", thread.LastMessageID)
+	}
+	if len(references) > 0 {
+		fmt.Fprintf(&header, "References: <%s>\r\n", strings.Join(references, "> <"))
+	}
+	header.WriteString("MIME-Version: 1.0\r\n")
+	header.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	header.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&header)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n"))); err != nil {
+		return fmt.Errorf("failed to encode email body: %w", err)
+	}
+	if err := qp.Close(); err != nil {
+		return fmt.Errorf("failed to encode email body: %w", err)
+	}
+
+	addr := c.cfg.Host + ":" + strconv.Itoa(c.cfg.Port)
+	var auth smtp.Auth
+	if c.cfg.Username != "" {
+		auth = smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, c.cfg.Host)
+	}
+	if err := smtp.SendMail(addr, auth, c.cfg.FromAddress, []string{thread.Address}, header.Bytes()); err != nil {
+		log.Printf("ERROR: Email to chat %d failed: %v", chatID, err)
+		return fmt.Errorf("smtp delivery failed: %w", err)
+	}
+
+	// The customer's reply will reference our Message-ID.
+	if err := c.threadRepo.SaveMessageID(ctx, messageID, chatID); err != nil {
+		return err
+	}
+	thread.LastMessageID = messageID
+	thread.References = strings.Join(append(references, messageID), " ")
+	thread.UpdatedAt = time.Now()
+	if err := c.threadRepo.Update(ctx, thread); err != nil {
+		return err
+	}
+	log.Printf("EMAIL: Sent message to chat %d", chatID)
+	return nil
+}
+
+// SendRichMessage sends the text fallback, in which buttons become plain links.
+// Email has no delivery receipts, so no ID is returned.
+func (c *EmailClient) SendRichMessage(ctx context.Context, chatID int64, message entity.OutboundMessage) (string, error) {
+	return "", c.SendMessage(ctx, chatID, message.FallbackText())
+}
+
+func (c *EmailClient) domain() string {
+	if _, domain, ok := strings.Cut(c.cfg.FromAddress, "@"); ok && domain != "" {
+		return domain
+	}
+	return "localhost"
+}
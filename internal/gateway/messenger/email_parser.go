@@ -0,0 +1,238 @@
+package messenger
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"smb-chatbot/internal/entity"
+)
+
+// ErrAutoReply marks out-of-office and other automatic emails, which must not
+// be answered to avoid mail loops.
+var ErrAutoReply = errors.New("email is an automatic reply")
+
+var headerDecoder = &mime.WordDecoder{}
+
+// ParseInboundEmail parses a raw RFC 5322 message. The text part is preferred
+// over HTML, and quoted replies and signatures are stripped from it. Image
+// parts are collected as they are, validation is left to the caller.
+func ParseInboundEmail(r io.Reader) (entity.InboundEmail, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return entity.InboundEmail{}, fmt.Errorf("invalid email: %w", err)
+	}
+	if auto := msg.Header.Get("Auto-Submitted"); auto != "" && !strings.EqualFold(auto, "no") {
+		return entity.InboundEmail{}, ErrAutoReply
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return entity.InboundEmail{}, fmt.Errorf("invalid From header: %w", err)
+	}
+	subject, err := headerDecoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+
+	var images []entity.EmailImage
+	body, err := extractText(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body, &images)
+	if err != nil {
+		return entity.InboundEmail{}, err
+	}
+
+	return entity.InboundEmail{
+		From:       from.Address,
+		FromName:   from.Name,
+		Subject:    strings.TrimSpace(subject),
+		MessageID:  trimMessageID(msg.Header.Get("Message-ID")),
+		InReplyTo:  trimMessageID(msg.Header.Get("In-Reply-To")),
+		References: parseReferences(msg.Header.Get("References")),
+		Text:       StripQuotedReply(body),
+		Images:     images,
+	}, nil
+}
+
+func trimMessageID(id string) string {
+	return strings.Trim(strings.TrimSpace(id), "<>")
+}
+
+func parseReferences(header string) []string {
+	var refs []string
+	for _, f := range strings.Fields(header) {
+		if id := trimMessageID(f); id != "" {
+			refs = append(refs, id)
+		}
+	}
+	return refs
+}
+
+// extractText returns the plain text of a message body, descending into
+// multipart bodies and converting HTML when there is no text/plain part.
+// Image parts are appended to images.
+func extractText(contentType, transferEncoding string, body io.Reader, images *[]entity.EmailImage) (string, error) {
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("invalid Content-Type '%s': %w", contentType, err)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		var plainText, htmlText string
+		for {
+			part, err := reader.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return "", fmt.Errorf("invalid multipart body: %w", err)
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if strings.HasPrefix(partType, "image/") {
+				image, err := readImagePart(part, partType)
+				if err != nil {
+					return "", err
+				}
+				*images = append(*images, image)
+				continue
+			}
+			if disposition, _, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); disposition == "attachment" {
+				continue
+			}
+			text, err := extractText(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part, images)
+			if err != nil {
+				return "", err
+			}
+			// multipart/alternative lists the plain text first; keep the first one we see.
+			if partType == "text/html" {
+				if htmlText == "" {
+					htmlText = text
+				}
+			} else if plainText == "" {
+				plainText = text
+			}
+		}
+		if plainText != "" {
+			return plainText, nil
+		}
+		return htmlText, nil
+	}
+
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", nil
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(decodeTransfer(body, transferEncoding), 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read email body: %w", err)
+	}
+
+	text := decodeCharset(raw, params["charset"])
+	if mediaType == "text/html" {
+		text = htmlToText(text)
+	}
+	return text, nil
+}
+
+func decodeTransfer(body io.Reader, transferEncoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(transferEncoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+func readImagePart(part *multipart.Part, contentType string) (entity.EmailImage, error) {
+	data, err := io.ReadAll(decodeTransfer(part, part.Header.Get("Content-Transfer-Encoding")))
+	if err != nil {
+		return entity.EmailImage{}, fmt.Errorf("failed to read email image: %w", err)
+	}
+	return entity.EmailImage{FileName: part.FileName(), ContentType: contentType, Data: data}, nil
+}
+
+// decodeCharset converts Latin-1 bodies to UTF-8. Other charsets are assumed
+// to be UTF-8 compatible.
+func decodeCharset(raw []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252":
+		runes := make([]rune, len(raw))
+		for i, b := range raw {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	default:
+		return string(raw)
+	}
+}
+
+var (
+	htmlDropBlocks = regexp.MustCompile(`(?is)<(style|script|head|blockquote)[^>]*>.*?</(style|script|head|blockquote)>`)
+	htmlGmailQuote = regexp.MustCompile(`(?is)<div[^>]*class="gmail_quote"[^>]*>.*$`)
+	htmlLineBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|h[1-6])>`)
+	htmlTags       = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+func htmlToText(s string) string {
+	s = htmlGmailQuote.ReplaceAllString(s, "")
+	s = htmlDropBlocks.ReplaceAllString(s, "")
+	s = htmlLineBreaks.ReplaceAllString(s, "\n")
+	s = htmlTags.ReplaceAllString(s, "")
+	return html.UnescapeString(s)
+}
+
+var (
+	replyHeader     = regexp.MustCompile(`(?i)^(on\s.+wrote:|am\s.+schrieb.*:|le\s.+a écrit\s?:)$`)
+	originalMessage = regexp.MustCompile(`(?i)^-{2,}\s*(original message|forwarded message)\s*-{2,}$`)
+	outlookRule     = regexp.MustCompile(`^_{10,}$`)
+	outlookHeader   = regexp.MustCompile(`(?i)^(from|sent|date|to|subject):\s`)
+	mobileSignature = regexp.MustCompile(`(?i)^sent from my \w+`)
+)
+
+// StripQuotedReply removes the quoted previous message and the signature
+// from an email reply, keeping only what the customer wrote.
+func StripQuotedReply(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	var kept []string
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+
+		// "-- " is the standard signature delimiter.
+		if line == "--" {
+			break
+		}
+		if originalMessage.MatchString(trimmed) || outlookRule.MatchString(trimmed) || mobileSignature.MatchString(trimmed) {
+			break
+		}
+		if replyHeader.MatchString(trimmed) {
+			break
+		}
+		// Clients wrap long "On ... wrote:" lines.
+		if i+1 < len(lines) && replyHeader.MatchString(trimmed+" "+strings.TrimSpace(lines[i+1])) {
+			break
+		}
+		// An Outlook header block: "From:" directly followed by "Sent:", "To:", ...
+		if strings.HasPrefix(strings.ToLower(trimmed), "from:") && i+1 < len(lines) && outlookHeader.MatchString(strings.TrimSpace(lines[i+1])) {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
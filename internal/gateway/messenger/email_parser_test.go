@@ -0,0 +1,128 @@
+package messenger
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/entity"
+)
+
+func TestStripQuotedReply(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "no quote", text: "Great service!\n\nThanks", want: "Great service!\n\nThanks"},
+		{name: "quoted lines", text: "5 stars\n> How was your visit?\n> Reply with 1-5", want: "5 stars"},
+		{name: "gmail reply header", text: "Loved it\r\n\r\nOn Mon, 2 Mar 2026 at 10:00, Shop <shop@example.com> wrote:\r\n> How was your visit?", want: "Loved it"},
+		{name: "wrapped reply header", text: "Loved it\n\nOn Mon, 2 Mar 2026 at 10:00, Shop\n<shop@example.com> wrote:\n> How was your visit?", want: "Loved it"},
+		{name: "german reply header", text: "Super\n\nAm 02.03.2026 um 10:00 schrieb Shop <shop@example.com>:\n> Wie war Ihr Besuch?", want: "Super"},
+		{name: "signature", text: "Nice staff\n-- \nAnn Smith\nACME Corp", want: "Nice staff"},
+		{name: "mobile signature", text: "4\n\nSent from my iPhone", want: "4"},
+		{name: "original message", text: "Bad\n\n-----Original Message-----\nFrom: Shop", want: "Bad"},
+		{name: "outlook header block", text: "OK\n\nFrom: Shop <shop@example.com>\nSent: Monday, March 2, 2026\nTo: Ann", want: "OK"},
+		{name: "outlook rule", text: "OK\n________________________________\nFrom: Shop", want: "OK"},
+		{name: "from in the text", text: "From: what I saw, the shop is great", want: "From: what I saw, the shop is great"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, StripQuotedReply(tt.text))
+		})
+	}
+}
+
+func TestParseInboundEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    entity.InboundEmail
+		wantErr error
+	}{
+		{
+			name: "plain reply in a thread",
+			raw: "From: Ann Smith <Ann@Example.com>\r\n" +
+				"Subject: =?utf-8?q?Re:_Your_visit_=E2=98=85?=\r\n" +
+				"Message-ID: <reply-2@mail.example.com>\r\n" +
+				"In-Reply-To: <bot-1@shop.example>\r\n" +
+				"References: <first@mail.example.com>\r\n <bot-1@shop.example>\r\n" +
+				"\r\n" +
+				"Five stars!\r\n\r\nOn Mon, 2 Mar 2026, Shop wrote:\r\n> How was your visit?\r\n",
+			want: entity.InboundEmail{
+				From:       "Ann@Example.com",
+				FromName:   "Ann Smith",
+				Subject:    "Re: Your visit ★",
+				MessageID:  "reply-2@mail.example.com",
+				InReplyTo:  "bot-1@shop.example",
+				References: []string{"first@mail.example.com", "bot-1@shop.example"},
+				Text:       "Five stars!",
+			},
+		},
+		{
+			name: "alternative parts prefer plain text",
+			raw: "From: ann@example.com\r\n" +
+				"Content-Type: multipart/alternative; boundary=b1\r\n" +
+				"\r\n" +
+				"--b1\r\nContent-Type: text/plain; charset=utf-8\r\n\r\nPlain text\r\n" +
+				"--b1\r\nContent-Type: text/html\r\n\r\n<p>HTML text</p>\r\n" +
+				"--b1--\r\n",
+			want: entity.InboundEmail{From: "ann@example.com", Text: "Plain text"},
+		},
+		{
+			name: "html only without the quote",
+			raw: "From: ann@example.com\r\n" +
+				"Content-Type: text/html; charset=utf-8\r\n" +
+				"\r\n" +
+				"<div>Tasty &amp; quick<br>4 stars</div><div class=\"gmail_quote\">On Monday Shop wrote: <blockquote>How was it?</blockquote></div>",
+			want: entity.InboundEmail{From: "ann@example.com", Text: "Tasty & quick\n4 stars"},
+		},
+		{
+			name: "quoted-printable latin-1",
+			raw: "From: ann@example.com\r\n" +
+				"Content-Type: text/plain; charset=iso-8859-1\r\n" +
+				"Content-Transfer-Encoding: quoted-printable\r\n" +
+				"\r\n" +
+				"Sch=F6n und g=FCnstig\r\n",
+			want: entity.InboundEmail{From: "ann@example.com", Text: "Schön und günstig"},
+		},
+		{
+			name: "image parts are collected, other attachments skipped",
+			raw: "From: ann@example.com\r\n" +
+				"Content-Type: multipart/mixed; boundary=b1\r\n" +
+				"\r\n" +
+				"--b1\r\nContent-Type: text/plain\r\n\r\nSee the photo\r\n" +
+				"--b1\r\nContent-Type: image/png\r\nContent-Disposition: attachment; filename=\"table.png\"\r\nContent-Transfer-Encoding: base64\r\n\r\naW1hZ2U=\r\n" +
+				"--b1\r\nContent-Type: text/plain\r\nContent-Disposition: attachment; filename=\"notes.txt\"\r\n\r\nnot the body\r\n" +
+				"--b1--\r\n",
+			want: entity.InboundEmail{
+				From:   "ann@example.com",
+				Text:   "See the photo",
+				Images: []entity.EmailImage{{FileName: "table.png", ContentType: "image/png", Data: []byte("image")}},
+			},
+		},
+		{
+			name:    "out of office",
+			raw:     "From: ann@example.com\r\nAuto-Submitted: auto-replied\r\n\r\nI am away.\r\n",
+			wantErr: ErrAutoReply,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email, err := ParseInboundEmail(strings.NewReader(tt.raw))
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, email)
+		})
+	}
+}
+
+func TestParseInboundEmailRejectsMissingSender(t *testing.T) {
+	_, err := ParseInboundEmail(strings.NewReader("Subject: hi\r\n\r\nhello\r\n"))
+	assert.Error(t, err)
+}
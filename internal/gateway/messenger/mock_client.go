@@ -40,6 +40,10 @@ func (m *MockMessengerClient) SendMessage(ctx context.Context, chatID int64, tex
 	return nil
 }
 
+func (m *MockMessengerClient) SendRichMessage(ctx context.Context, chatID int64, message entity.OutboundMessage) (string, error) {
+	return "", m.SendMessage(ctx, chatID, message.FallbackText())
+}
+
 func (m *MockMessengerClient) AddHistory(chatID int64, isUser bool, text string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -0,0 +1,37 @@
+package messenger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"smb-chatbot/internal/entity"
+)
+
+// PhoneToID maps an E.164 phone number ("+1 555 0100", "15550100") to the
+// numeric ID used for chats and users of the channel whose chat IDs start at
+// base. Up to 15 digits always fit the range.
+func PhoneToID(base int64, phone string) (int64, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	if digits == "" || len(digits) > 15 {
+		return 0, fmt.Errorf("invalid phone number '%s'", phone)
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return base + n, nil
+}
+
+// IDToPhone is the inverse of PhoneToID, without the leading '+'.
+func IDToPhone(base, id int64) (string, error) {
+	if id <= base || id >= base+entity.ChatIDRangeSize {
+		return "", fmt.Errorf("chat %d is not a phone number chat of this channel", id)
+	}
+	return strconv.FormatInt(id-base, 10), nil
+}
@@ -0,0 +1,413 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+const DefaultTelegramBaseURL = "https://api.telegram.org"
+
+// telegramMaxCallbackData is Telegram's limit for callback_data in bytes.
+const telegramMaxCallbackData = 64
+
+var telegramAllowedUpdates = []string{"message", "callback_query"}
+
+// TelegramClient talks to the Telegram Bot API. The base URL is configurable
+// so a local stand-in can replace api.telegram.org.
+type TelegramClient struct {
+	token      string
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewTelegramClient(token, baseURL string) *TelegramClient {
+	if baseURL == "" {
+		baseURL = DefaultTelegramBaseURL
+	}
+	return &TelegramClient{
+		token:      token,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 90 * time.Second},
+	}
+}
+
+type TelegramUpdate struct {
+	UpdateID      int64                  `json:"update_id"`
+	Message       *TelegramMessage       `json:"message,omitempty"`
+	CallbackQuery *TelegramCallbackQuery `json:"callback_query,omitempty"`
+}
+
+type TelegramMessage struct {
+	MessageID   int64                         `json:"message_id"`
+	From        *TelegramUser                 `json:"from,omitempty"`
+	Chat        TelegramChat                  `json:"chat"`
+	Date        int64                         `json:"date"`
+	Text        string                        `json:"text,omitempty"`
+	Photo       []TelegramPhotoSize           `json:"photo,omitempty"`
+	Caption     string                        `json:"caption,omitempty"`
+	ReplyMarkup *TelegramInlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+// TelegramPhotoSize is one resolution of a photo; Telegram lists them from
+// smallest to largest.
+type TelegramPhotoSize struct {
+	FileID   string `json:"file_id"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FileSize int64  `json:"file_size,omitempty"`
+}
+
+// TelegramCallbackQuery is sent when a customer taps an inline keyboard button.
+type TelegramCallbackQuery struct {
+	ID      string           `json:"id"`
+	From    TelegramUser     `json:"from"`
+	Message *TelegramMessage `json:"message,omitempty"`
+	Data    string           `json:"data,omitempty"`
+}
+
+type TelegramInlineKeyboardMarkup struct {
+	InlineKeyboard [][]TelegramInlineKeyboardButton `json:"inline_keyboard"`
+}
+
+type TelegramInlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data,omitempty"`
+	URL          string `json:"url,omitempty"`
+}
+
+type TelegramUser struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+type TelegramChat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+}
+
+func (u *TelegramUser) displayName() string {
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if name == "" {
+		name = u.Username
+	}
+	return name
+}
+
+// ToInput maps a Telegram update to a HandleMessageInput. Updates without a
+// text message, photo or button tap (edits, stickers, joins, ...) are
+// reported as not handled. A photo's caption becomes the text.
+func (u TelegramUpdate) ToInput() (usecase.HandleMessageInput, bool) {
+	if q := u.CallbackQuery; q != nil {
+		if q.Message == nil || q.Data == "" {
+			return usecase.HandleMessageInput{}, false
+		}
+		return usecase.HandleMessageInput{
+			ChatID:    q.Message.Chat.ID,
+			UserID:    q.From.ID,
+			UserName:  q.From.displayName(),
+			Text:      q.buttonTitle(),
+			MessageID: "telegram-callback-" + q.ID,
+			Channel:   entity.ChannelTelegram,
+			Payload:   q.Data,
+		}, true
+	}
+
+	if u.Message == nil || u.Message.From == nil || (u.Message.Text == "" && len(u.Message.Photo) == 0) {
+		return usecase.HandleMessageInput{}, false
+	}
+	input := usecase.HandleMessageInput{
+		ChatID:   u.Message.Chat.ID,
+		UserID:   u.Message.From.ID,
+		UserName: u.Message.From.displayName(),
+		Text:     u.Message.Text,
+		// Telegram message IDs are unique per chat, like processed message IDs.
+		MessageID: fmt.Sprintf("telegram-%d", u.Message.MessageID),
+		Channel:   entity.ChannelTelegram,
+	}
+	if photos := u.Message.Photo; len(photos) > 0 {
+		input.Text = u.Message.Caption
+		input.Media = []entity.InboundMedia{{Ref: photos[len(photos)-1].FileID, ContentType: "image/jpeg"}}
+	}
+	return input, true
+}
+
+// buttonTitle looks up the label of the tapped button in the message's
+// keyboard, so the conversation history shows what the customer chose.
+func (q *TelegramCallbackQuery) buttonTitle() string {
+	if q.Message.ReplyMarkup != nil {
+		for _, row := range q.Message.ReplyMarkup.InlineKeyboard {
+			for _, button := range row {
+				if button.CallbackData == q.Data {
+					return button.Text
+				}
+			}
+		}
+	}
+	return q.Data
+}
+
+func (c *TelegramClient) SendMessage(ctx context.Context, chatID int64, text string) error {
+	_, err := c.SendRichMessage(ctx, chatID, entity.TextMessage(text))
+	return err
+}
+
+// SendRichMessage renders quick replies and URL buttons as an inline keyboard,
+// one button per row. Bots get no delivery or read receipts from Telegram;
+// the returned message ID only identifies the message within the chat.
+func (c *TelegramClient) SendRichMessage(ctx context.Context, chatID int64, message entity.OutboundMessage) (string, error) {
+	payload := map[string]any{
+		"chat_id": chatID,
+		"text":    message.Text,
+	}
+	if message.IsRich() {
+		var keyboard [][]TelegramInlineKeyboardButton
+		for _, qr := range message.QuickReplies {
+			if len(qr.Payload) > telegramMaxCallbackData {
+				log.Printf("WARN: Dropping Telegram quick reply '%s' for chat %d, payload exceeds %d bytes", qr.Title, chatID, telegramMaxCallbackData)
+				continue
+			}
+			keyboard = append(keyboard, []TelegramInlineKeyboardButton{{Text: qr.Title, CallbackData: qr.Payload}})
+		}
+		for _, button := range message.Buttons {
+			keyboard = append(keyboard, []TelegramInlineKeyboardButton{{Text: button.Title, URL: button.URL}})
+		}
+		payload["reply_markup"] = TelegramInlineKeyboardMarkup{InlineKeyboard: keyboard}
+	}
+	var sent TelegramMessage
+	if err := c.call(ctx, "sendMessage", payload, &sent); err != nil {
+		log.Printf("ERROR: Telegram sendMessage to chat %d failed: %v", chatID, err)
+		return "", err
+	}
+	log.Printf("TELEGRAM: Sent message %d to chat %d", sent.MessageID, chatID)
+	return strconv.FormatInt(sent.MessageID, 10), nil
+}
+
+// GetUpdates long-polls for updates with an ID of at least offset.
+func (c *TelegramClient) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]TelegramUpdate, error) {
+	payload := map[string]any{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": telegramAllowedUpdates,
+	}
+	var updates []TelegramUpdate
+	if err := c.call(ctx, "getUpdates", payload, &updates); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// SetWebhook registers url with Telegram. Telegram sends secretToken back in
+// the X-Telegram-Bot-Api-Secret-Token header of every webhook request.
+func (c *TelegramClient) SetWebhook(ctx context.Context, url, secretToken string) error {
+	payload := map[string]any{
+		"url":             url,
+		"allowed_updates": telegramAllowedUpdates,
+	}
+	if secretToken != "" {
+		payload["secret_token"] = secretToken
+	}
+	return c.call(ctx, "setWebhook", payload, nil)
+}
+
+// AnswerCallbackQuery stops the loading indicator on the tapped button.
+func (c *TelegramClient) AnswerCallbackQuery(ctx context.Context, callbackQueryID string) error {
+	return c.call(ctx, "answerCallbackQuery", map[string]any{"callback_query_id": callbackQueryID}, nil)
+}
+
+// FetchMedia downloads the file with the given file ID. Bots can download
+// files of up to 20 MB.
+func (c *TelegramClient) FetchMedia(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	var file struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := c.call(ctx, "getFile", map[string]any{"file_id": fileID}, &file); err != nil {
+		return nil, err
+	}
+	if file.FilePath == "" {
+		return nil, fmt.Errorf("telegram file %s is not available for download", fileID)
+	}
+
+	endpoint := fmt.Sprintf("%s/file/bot%s/%s", c.baseURL, c.token, file.FilePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create telegram file request: %w", withoutURL(err))
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("telegram file request failed: %w", withoutURL(err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("telegram file download failed with status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// DeleteWebhook is required before getUpdates can be used.
+func (c *TelegramClient) DeleteWebhook(ctx context.Context) error {
+	return c.call(ctx, "deleteWebhook", map[string]any{}, nil)
+}
+
+func (c *TelegramClient) call(ctx context.Context, method string, payload any, result any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode telegram %s request: %w", method, err)
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/%s", c.baseURL, c.token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create telegram %s request: %w", method, withoutURL(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram %s request failed: %w", method, withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read telegram %s response: %w", method, err)
+	}
+	var tr telegramResponse
+	if err := json.Unmarshal(respBody, &tr); err != nil {
+		return fmt.Errorf("telegram %s returned status %d with invalid body: %w", method, resp.StatusCode, err)
+	}
+	if !tr.OK {
+		return fmt.Errorf("telegram %s failed with status %d: %s", method, resp.StatusCode, tr.Description)
+	}
+	if result != nil {
+		if err := json.Unmarshal(tr.Result, result); err != nil {
+			return fmt.Errorf("failed to decode telegram %s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// withoutURL strips the request URL from transport errors. Bot API URLs
+// contain the bot token, which must not end up in logs or the outbox.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// TelegramPoller receives updates via getUpdates long polling, for setups
+// where Telegram cannot reach a public webhook URL.
+type TelegramPoller struct {
+	client      *TelegramClient
+	pollTimeout time.Duration
+	retryDelay  time.Duration
+	// maxAttempts bounds how often an update is handled before it is
+	// skipped, so one failing update cannot block all later ones.
+	maxAttempts int
+}
+
+func NewTelegramPoller(client *TelegramClient) *TelegramPoller {
+	return &TelegramPoller{
+		client:      client,
+		pollTimeout: 30 * time.Second,
+		retryDelay:  5 * time.Second,
+		maxAttempts: 5,
+	}
+}
+
+func (p *TelegramPoller) Run(ctx context.Context, handle func(ctx context.Context, input usecase.HandleMessageInput) error) {
+	if err := p.client.DeleteWebhook(ctx); err != nil {
+		log.Printf("WARN: Failed to delete Telegram webhook before polling: %v", err)
+	}
+	log.Println("TELEGRAM: Started long polling for updates")
+
+	var offset int64
+	attempts := make(map[int64]int)
+	for ctx.Err() == nil {
+		updates, err := p.client.GetUpdates(ctx, offset, p.pollTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("ERROR: Telegram getUpdates failed, retrying in %s: %v", p.retryDelay, err)
+			p.wait(ctx)
+			continue
+		}
+
+		for _, update := range updates {
+			if !p.handleUpdate(ctx, update, attempts, handle) {
+				// The offset stays at the failed update, so the next call
+				// returns it again along with the updates after it.
+				p.wait(ctx)
+				break
+			}
+			delete(attempts, update.UpdateID)
+			offset = update.UpdateID + 1
+		}
+	}
+	log.Println("TELEGRAM: Stopped long polling")
+}
+
+// handleUpdate reports whether the update is done with: handled, not meant
+// for the bot, or failed too often to be retried again.
+func (p *TelegramPoller) handleUpdate(ctx context.Context, update TelegramUpdate, attempts map[int64]int, handle func(ctx context.Context, input usecase.HandleMessageInput) error) bool {
+	input, ok := update.ToInput()
+	if !ok {
+		p.answerCallbackQuery(ctx, update)
+		return true
+	}
+	if err := handle(ctx, input); err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		attempts[update.UpdateID]++
+		if errors.Is(err, usecase.ErrChannelMismatch) || attempts[update.UpdateID] >= p.maxAttempts {
+			log.Printf("ERROR: Giving up on Telegram update %d for chat %d after %d attempt(s): %v", update.UpdateID, input.ChatID, attempts[update.UpdateID], err)
+			return true
+		}
+		log.Printf("ERROR: Failed to handle Telegram update %d for chat %d, retrying in %s: %v", update.UpdateID, input.ChatID, p.retryDelay, err)
+		return false
+	}
+	// Like the webhook, the button's loading indicator is only cleared once
+	// the tap was handled.
+	p.answerCallbackQuery(ctx, update)
+	return true
+}
+
+func (p *TelegramPoller) answerCallbackQuery(ctx context.Context, update TelegramUpdate) {
+	if update.CallbackQuery == nil {
+		return
+	}
+	if err := p.client.AnswerCallbackQuery(ctx, update.CallbackQuery.ID); err != nil {
+		log.Printf("WARN: Failed to answer Telegram callback query %s: %v", update.CallbackQuery.ID, err)
+	}
+}
+
+func (p *TelegramPoller) wait(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(p.retryDelay):
+	}
+}
@@ -0,0 +1,268 @@
+package messenger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+const testBotToken = "123456:secret-bot-token"
+
+func TestTelegramUpdateToInput(t *testing.T) {
+	from := &TelegramUser{ID: 7, FirstName: "Ann", LastName: "Lee"}
+	tests := []struct {
+		name   string
+		update TelegramUpdate
+		want   usecase.HandleMessageInput
+		wantOK bool
+	}{
+		{
+			name:   "text message",
+			update: TelegramUpdate{Message: &TelegramMessage{MessageID: 5, From: from, Chat: TelegramChat{ID: 42}, Text: "hi"}},
+			want:   usecase.HandleMessageInput{ChatID: 42, UserID: 7, UserName: "Ann Lee", Text: "hi", MessageID: "telegram-5", Channel: entity.ChannelTelegram},
+			wantOK: true,
+		},
+		{
+			name: "photo with caption",
+			update: TelegramUpdate{Message: &TelegramMessage{MessageID: 6, From: from, Chat: TelegramChat{ID: 42}, Caption: "look",
+				Photo: []TelegramPhotoSize{{FileID: "small"}, {FileID: "large"}}}},
+			want: usecase.HandleMessageInput{ChatID: 42, UserID: 7, UserName: "Ann Lee", Text: "look", MessageID: "telegram-6", Channel: entity.ChannelTelegram,
+				Media: []entity.InboundMedia{{Ref: "large", ContentType: "image/jpeg"}}},
+			wantOK: true,
+		},
+		{
+			name: "button tap",
+			update: TelegramUpdate{CallbackQuery: &TelegramCallbackQuery{ID: "cb1", From: *from, Data: "rating:5", Message: &TelegramMessage{
+				Chat:        TelegramChat{ID: 42},
+				ReplyMarkup: &TelegramInlineKeyboardMarkup{InlineKeyboard: [][]TelegramInlineKeyboardButton{{{Text: "⭐⭐⭐⭐⭐", CallbackData: "rating:5"}}}},
+			}}},
+			want:   usecase.HandleMessageInput{ChatID: 42, UserID: 7, UserName: "Ann Lee", Text: "⭐⭐⭐⭐⭐", MessageID: "telegram-callback-cb1", Channel: entity.ChannelTelegram, Payload: "rating:5"},
+			wantOK: true,
+		},
+		{
+			name:   "sticker",
+			update: TelegramUpdate{Message: &TelegramMessage{MessageID: 7, From: from, Chat: TelegramChat{ID: 42}}},
+		},
+		{
+			name:   "message without sender",
+			update: TelegramUpdate{Message: &TelegramMessage{MessageID: 8, Chat: TelegramChat{ID: 42}, Text: "hi"}},
+		},
+		{
+			name:   "button tap without data",
+			update: TelegramUpdate{CallbackQuery: &TelegramCallbackQuery{ID: "cb2", From: *from, Message: &TelegramMessage{Chat: TelegramChat{ID: 42}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.update.ToInput()
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTelegramClientErrorsOmitToken(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"ok":false,"description":"Unauthorized"}`))
+	}))
+	defer failing.Close()
+
+	tests := []struct {
+		name    string
+		baseURL string
+	}{
+		{name: "unreachable API", baseURL: closed.URL},
+		{name: "API error", baseURL: failing.URL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewTelegramClient(testBotToken, tt.baseURL)
+			_, err := client.SendRichMessage(context.Background(), 42, entity.TextMessage("hi"))
+			require.Error(t, err)
+			assert.NotContains(t, err.Error(), testBotToken)
+
+			_, err = client.FetchMedia(context.Background(), "file")
+			require.Error(t, err)
+			assert.NotContains(t, err.Error(), testBotToken)
+		})
+	}
+}
+
+// telegramStandIn serves getUpdates from a fixed list of updates, honoring
+// the offset, and records the offsets it was polled with.
+type telegramStandIn struct {
+	mu      sync.Mutex
+	updates []TelegramUpdate
+	offsets []int64
+}
+
+func (s *telegramStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var result any = true
+	if r.URL.Path == "/bot"+testBotToken+"/getUpdates" {
+		var req struct {
+			Offset int64 `json:"offset"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		s.mu.Lock()
+		s.offsets = append(s.offsets, req.Offset)
+		pending := []TelegramUpdate{}
+		for _, update := range s.updates {
+			if update.UpdateID >= req.Offset {
+				pending = append(pending, update)
+			}
+		}
+		s.mu.Unlock()
+		result = pending
+	}
+	raw, _ := json.Marshal(result)
+	_ = json.NewEncoder(w).Encode(telegramResponse{OK: true, Result: raw})
+}
+
+func TestTelegramPollerRetriesFailedUpdateInOrder(t *testing.T) {
+	message := func(updateID, messageID int64) TelegramUpdate {
+		return TelegramUpdate{UpdateID: updateID, Message: &TelegramMessage{
+			MessageID: messageID, From: &TelegramUser{ID: 7}, Chat: TelegramChat{ID: 42}, Text: "hi",
+		}}
+	}
+	tests := []struct {
+		name string
+		// failures is how often handling each message ID fails.
+		failures    map[string]int
+		maxAttempts int
+		err         error
+		want        []string
+	}{
+		{
+			name:        "retryable error",
+			failures:    map[string]int{"telegram-1": 2},
+			maxAttempts: 5,
+			err:         errors.New("database down"),
+			want:        []string{"telegram-1", "telegram-1", "telegram-1", "telegram-2"},
+		},
+		{
+			name:        "gives up after max attempts",
+			failures:    map[string]int{"telegram-1": 10},
+			maxAttempts: 2,
+			err:         errors.New("database down"),
+			want:        []string{"telegram-1", "telegram-1", "telegram-2"},
+		},
+		{
+			name:        "channel mismatch is not retried",
+			failures:    map[string]int{"telegram-1": 10},
+			maxAttempts: 5,
+			err:         usecase.ErrChannelMismatch,
+			want:        []string{"telegram-1", "telegram-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn := &telegramStandIn{updates: []TelegramUpdate{message(10, 1), message(11, 2)}}
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			poller := NewTelegramPoller(NewTelegramClient(testBotToken, server.URL))
+			poller.pollTimeout = 0
+			poller.retryDelay = time.Millisecond
+			poller.maxAttempts = tt.maxAttempts
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			var handled []string
+			poller.Run(ctx, func(_ context.Context, input usecase.HandleMessageInput) error {
+				handled = append(handled, input.MessageID)
+				if tt.failures[input.MessageID] > 0 {
+					tt.failures[input.MessageID]--
+					return tt.err
+				}
+				if input.MessageID == "telegram-2" {
+					cancel()
+				}
+				return nil
+			})
+
+			assert.Equal(t, tt.want, handled)
+			// Update 11 is only acknowledged after update 10 was done with.
+			standIn.mu.Lock()
+			defer standIn.mu.Unlock()
+			for _, offset := range standIn.offsets {
+				assert.Zero(t, offset, "offset moved past the failed update")
+			}
+		})
+	}
+}
+
+func TestTelegramSendRichMessageKeyboard(t *testing.T) {
+	tests := []struct {
+		name    string
+		message entity.OutboundMessage
+		// want is the inline keyboard; nil expects none.
+		want [][]TelegramInlineKeyboardButton
+	}{
+		{name: "plain text", message: entity.TextMessage("hi")},
+		{
+			name: "quick replies and URL button",
+			message: entity.OutboundMessage{
+				Text:         "Rate us",
+				QuickReplies: []entity.QuickReply{{Title: "⭐", Payload: "rating:1"}, {Title: "Not now", Payload: "review:not_now"}},
+				Buttons:      []entity.URLButton{{Title: "Review", URL: "https://example.com/r/1"}},
+			},
+			want: [][]TelegramInlineKeyboardButton{
+				{{Text: "⭐", CallbackData: "rating:1"}},
+				{{Text: "Not now", CallbackData: "review:not_now"}},
+				{{Text: "Review", URL: "https://example.com/r/1"}},
+			},
+		},
+		{
+			name: "payload over the callback data limit",
+			message: entity.OutboundMessage{
+				Text:         "Pick",
+				QuickReplies: []entity.QuickReply{{Title: "Long", Payload: strings.Repeat("p", telegramMaxCallbackData+1)}, {Title: "Short", Payload: "s"}},
+			},
+			want: [][]TelegramInlineKeyboardButton{{{Text: "Short", CallbackData: "s"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent struct {
+				ChatID      int64                         `json:"chat_id"`
+				Text        string                        `json:"text"`
+				ReplyMarkup *TelegramInlineKeyboardMarkup `json:"reply_markup"`
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/bot"+testBotToken+"/sendMessage", r.URL.Path)
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&sent))
+				_ = json.NewEncoder(w).Encode(telegramResponse{OK: true, Result: json.RawMessage(`{"message_id": 99, "chat": {"id": 42}}`)})
+			}))
+			defer server.Close()
+
+			id, err := NewTelegramClient(testBotToken, server.URL).SendRichMessage(context.Background(), 42, tt.message)
+			require.NoError(t, err)
+			assert.Equal(t, "99", id)
+			assert.Equal(t, int64(42), sent.ChatID)
+			assert.Equal(t, tt.message.Text, sent.Text)
+			if tt.want == nil {
+				assert.Nil(t, sent.ReplyMarkup)
+				return
+			}
+			require.NotNil(t, sent.ReplyMarkup)
+			assert.Equal(t, tt.want, sent.ReplyMarkup.InlineKeyboard)
+		})
+	}
+}
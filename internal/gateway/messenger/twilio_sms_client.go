@@ -0,0 +1,339 @@
+package messenger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+const (
+	DefaultTwilioBaseURL = "https://api.twilio.com"
+
+	// twilioUnsubscribedErrorCode is returned when the recipient replied STOP
+	// to the sending number.
+	twilioUnsubscribedErrorCode = 21610
+)
+
+type TwilioConfig struct {
+	AccountSID string
+	AuthToken  string
+	FromNumber string
+	// BaseURL replaces the Twilio REST API URL, e.g. with a local stand-in.
+	BaseURL string
+	// StatusCallbackURL, when set, receives Twilio's delivery status updates.
+	StatusCallbackURL string
+}
+
+// TwilioSMSClient sends text messages through the Twilio REST API. Like
+// WhatsApp, customers are identified by their phone number (see PhoneToID).
+type TwilioSMSClient struct {
+	cfg        TwilioConfig
+	optOutRepo usecase.OptOutRepository
+	httpClient *http.Client
+}
+
+func NewTwilioSMSClient(cfg TwilioConfig, or usecase.OptOutRepository) *TwilioSMSClient {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultTwilioBaseURL
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	return &TwilioSMSClient{
+		cfg:        cfg,
+		optOutRepo: or,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type twilioError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  int    `json:"status"`
+}
+
+func (e *twilioError) Error() string {
+	return fmt.Sprintf("twilio error %d: %s", e.Code, e.Message)
+}
+
+// SendMessage sends text as one message, which Twilio splits into segments
+// the phone joins again; text beyond Twilio's limit goes out in further
+// messages. Customers who replied STOP are skipped with
+// usecase.ErrRecipientOptedOut.
+func (c *TwilioSMSClient) SendMessage(ctx context.Context, chatID int64, text string) error {
+	_, err := c.sendText(ctx, chatID, text)
+	return err
+}
+
+// SendRichMessage sends the text fallback; quick replies are answered by number.
+// The returned ID is the MessageSid that status callbacks refer to, of the
+// last message if the text needed several.
+func (c *TwilioSMSClient) SendRichMessage(ctx context.Context, chatID int64, message entity.OutboundMessage) (string, error) {
+	return c.sendText(ctx, chatID, message.FallbackText())
+}
+
+func (c *TwilioSMSClient) sendText(ctx context.Context, chatID int64, text string) (string, error) {
+	optedOut, err := c.optOutRepo.IsOptedOut(ctx, entity.ChannelSMS, chatID)
+	if err != nil {
+		return "", err
+	}
+	if optedOut {
+		log.Printf("SMS: Not sending to chat %d, recipient opted out", chatID)
+		return "", usecase.ErrRecipientOptedOut
+	}
+
+	to, err := IDToPhone(entity.SMSChatIDBase, chatID)
+	if err != nil {
+		return "", err
+	}
+	var sid string
+	parts := SplitSMS(text)
+	for i, part := range parts {
+		sid, err = c.send(ctx, to, part)
+		if err != nil {
+			log.Printf("ERROR: SMS %d/%d to chat %d failed: %v", i+1, len(parts), chatID, err)
+			return "", err
+		}
+		log.Printf("SMS: Sent message %s (%d/%d) to chat %d in %d segment(s)", sid, i+1, len(parts), chatID, SMSSegments(part))
+	}
+	return sid, nil
+}
+
+// send posts one message and returns its MessageSid.
+func (c *TwilioSMSClient) send(ctx context.Context, to, body string) (string, error) {
+	form := url.Values{}
+	form.Set("To", "+"+to)
+	form.Set("From", c.cfg.FromNumber)
+	form.Set("Body", body)
+	if c.cfg.StatusCallbackURL != "" {
+		form.Set("StatusCallback", c.cfg.StatusCallbackURL)
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", c.cfg.BaseURL, c.cfg.AccountSID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create twilio request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(c.cfg.AccountSID, c.cfg.AuthToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("twilio request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		var sent struct {
+			SID string `json:"sid"`
+		}
+		_ = json.Unmarshal(respBody, &sent)
+		return sent.SID, nil
+	}
+	var twErr twilioError
+	if json.Unmarshal(respBody, &twErr) == nil && twErr.Code != 0 {
+		if twErr.Code == twilioUnsubscribedErrorCode {
+			return "", fmt.Errorf("%w: %v", usecase.ErrRecipientOptedOut, &twErr)
+		}
+		return "", &twErr
+	}
+	return "", fmt.Errorf("twilio request failed with status %d: %s", resp.StatusCode, respBody)
+}
+
+// Segment limits: 160 characters in the GSM-7 alphabet, 70 once any
+// character needs UCS-2. Concatenated segments lose room to the header that
+// lets the phone join them.
+const (
+	gsmSegmentLength        = 160
+	gsmConcatSegmentLength  = 153
+	ucs2SegmentLength       = 70
+	ucs2ConcatSegmentLength = 67
+)
+
+// twilioMaxBodyLength is the longest body Twilio accepts for one message;
+// longer texts are split by SplitSMS.
+const twilioMaxBodyLength = 1600
+
+// SplitSMS splits text into message bodies Twilio accepts, breaking at
+// spaces or line breaks where possible. Several parts are numbered like
+// "(1/2) ", as carriers may deliver them out of order.
+func SplitSMS(text string) []string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= twilioMaxBodyLength {
+		return []string{text}
+	}
+
+	// Reserve room for the "(nn/nn) " prefix.
+	chunks := splitOnWords(text, twilioMaxBodyLength-len("(99/99) "))
+	parts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		parts[i] = fmt.Sprintf("(%d/%d) %s", i+1, len(chunks), chunk)
+	}
+	return parts
+}
+
+// splitOnWords cuts text into chunks of at most limit characters, at the
+// last space or line break in the second half of each chunk if there is one.
+func splitOnWords(text string, limit int) []string {
+	var chunks []string
+	runes := []rune(text)
+	for len(runes) > limit {
+		cut := limit
+		for i := limit; i > limit/2; i-- {
+			if runes[i] == ' ' || runes[i] == '\n' {
+				cut = i
+				break
+			}
+		}
+		chunks = append(chunks, strings.TrimSpace(string(runes[:cut])))
+		runes = []rune(strings.TrimSpace(string(runes[cut:])))
+	}
+	if len(runes) > 0 {
+		chunks = append(chunks, string(runes))
+	}
+	return chunks
+}
+
+// gsmAlphabet is the GSM 03.38 basic character set. The extension table
+// (e.g. '€', '[') costs two characters and is treated as UCS-2 for simplicity.
+const gsmAlphabet = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+func isGSM(text string) bool {
+	for _, r := range text {
+		if !strings.ContainsRune(gsmAlphabet, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// SMSSegments returns how many SMS segments text takes, which is what
+// carriers deliver and Twilio bills separately.
+func SMSSegments(text string) int {
+	length := utf8.RuneCountInString(text)
+	single, concat := gsmSegmentLength, gsmConcatSegmentLength
+	if !isGSM(text) {
+		single, concat = ucs2SegmentLength, ucs2ConcatSegmentLength
+	}
+	if length <= single {
+		return 1
+	}
+	return (length + concat - 1) / concat
+}
+
+// VerifyTwilioSignature checks the X-Twilio-Signature header: a base64
+// HMAC-SHA1, keyed with the auth token, of the full webhook URL followed by
+// every POST parameter name and value sorted by name.
+func VerifyTwilioSignature(authToken, webhookURL string, params url.Values, header string) bool {
+	got, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return false
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(webhookURL)
+	for _, k := range keys {
+		for _, v := range params[k] {
+			b.WriteString(k)
+			b.WriteString(v)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(b.String()))
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// TwilioInput maps an inbound SMS webhook form to a HandleMessageInput. MMS
+// images become media referenced by their Twilio URL. Messages without a
+// sender, body or image are reported as not handled.
+func TwilioInput(form url.Values) (usecase.HandleMessageInput, bool) {
+	body := strings.TrimSpace(form.Get("Body"))
+	var media []entity.InboundMedia
+	numMedia, _ := strconv.Atoi(form.Get("NumMedia"))
+	for i := 0; i < numMedia; i++ {
+		contentType := form.Get(fmt.Sprintf("MediaContentType%d", i))
+		if mediaURL := form.Get(fmt.Sprintf("MediaUrl%d", i)); mediaURL != "" && strings.HasPrefix(contentType, "image/") {
+			media = append(media, entity.InboundMedia{Ref: mediaURL, ContentType: contentType})
+		}
+	}
+	if body == "" && len(media) == 0 {
+		return usecase.HandleMessageInput{}, false
+	}
+	id, err := PhoneToID(entity.SMSChatIDBase, form.Get("From"))
+	if err != nil {
+		log.Printf("WARN: Skipping SMS %s: %v", form.Get("MessageSid"), err)
+		return usecase.HandleMessageInput{}, false
+	}
+	return usecase.HandleMessageInput{
+		ChatID:    id,
+		UserID:    id,
+		UserName:  form.Get("From"),
+		Text:      body,
+		MessageID: form.Get("MessageSid"),
+		Channel:   entity.ChannelSMS,
+		Media:     media,
+	}, true
+}
+
+// FetchMedia downloads an MMS image. Only URLs of the configured Twilio API
+// are fetched, as the account credentials are sent along.
+func (c *TwilioSMSClient) FetchMedia(ctx context.Context, mediaURL string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(mediaURL, c.cfg.BaseURL+"/") {
+		return nil, fmt.Errorf("refusing to download media from %s", mediaURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create twilio media request: %w", err)
+	}
+	req.SetBasicAuth(c.cfg.AccountSID, c.cfg.AuthToken)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("twilio media request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("twilio media download failed with status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// TwilioReceipt maps a status callback form to a delivery receipt. Statuses
+// before the message left Twilio (queued, sending, ...) are reported as not handled.
+func TwilioReceipt(form url.Values) (entity.DeliveryReceipt, bool) {
+	receipt := entity.DeliveryReceipt{ExternalID: form.Get("MessageSid"), At: time.Now()}
+	switch status := form.Get("MessageStatus"); status {
+	case "sent", "delivered", "read":
+		receipt.Status = status
+	case "undelivered", "failed":
+		receipt.Status = entity.OutboxStatusFailed
+		receipt.Error = "twilio reported the message " + status
+		if code := form.Get("ErrorCode"); code != "" {
+			receipt.Error += " (error " + code + ")"
+		}
+	default:
+		return entity.DeliveryReceipt{}, false
+	}
+	return receipt, receipt.ExternalID != ""
+}
@@ -0,0 +1,191 @@
+package messenger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+func TestVerifyTwilioSignature(t *testing.T) {
+	// The example from Twilio's webhook security documentation.
+	const (
+		authToken  = "12345"
+		webhookURL = "https://mycompany.com/myapp.php?foo=1&bar=2"
+		signature  = "0/KCTR6DLpKmkAf8muzZqo1nDgQ="
+	)
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	tampered := url.Values{}
+	for k, v := range params {
+		tampered[k] = v
+	}
+	tampered.Set("Digits", "4321")
+
+	tests := []struct {
+		name       string
+		authToken  string
+		webhookURL string
+		params     url.Values
+		header     string
+		want       bool
+	}{
+		{name: "valid", authToken: authToken, webhookURL: webhookURL, params: params, header: signature, want: true},
+		{name: "missing", authToken: authToken, webhookURL: webhookURL, params: params, header: ""},
+		{name: "not base64", authToken: authToken, webhookURL: webhookURL, params: params, header: "not base64!"},
+		{name: "other auth token", authToken: "54321", webhookURL: webhookURL, params: params, header: signature},
+		{name: "other URL", authToken: authToken, webhookURL: "https://mycompany.com/myapp.php", params: params, header: signature},
+		{name: "tampered parameter", authToken: authToken, webhookURL: webhookURL, params: tampered, header: signature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, VerifyTwilioSignature(tt.authToken, tt.webhookURL, tt.params, tt.header))
+		})
+	}
+}
+
+func TestSMSSegments(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{name: "empty", text: "", want: 1},
+		{name: "short", text: "Thanks for your review!", want: 1},
+		{name: "full GSM segment", text: strings.Repeat("a", 160), want: 1},
+		{name: "one GSM character over", text: strings.Repeat("a", 161), want: 2},
+		{name: "two concatenated GSM segments", text: strings.Repeat("a", 306), want: 2},
+		{name: "three concatenated GSM segments", text: strings.Repeat("a", 307), want: 3},
+		{name: "GSM accents", text: strings.Repeat("é", 160), want: 1},
+		{name: "full UCS-2 segment", text: strings.Repeat("⭐", 70), want: 1},
+		{name: "one UCS-2 character over", text: strings.Repeat("⭐", 71), want: 2},
+		{name: "one emoji makes it UCS-2", text: strings.Repeat("a", 100) + "⭐", want: 2},
+		{name: "extension character counts as UCS-2", text: strings.Repeat("a", 70) + "€", want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SMSSegments(tt.text))
+		})
+	}
+}
+
+func TestSplitSMS(t *testing.T) {
+	limit := twilioMaxBodyLength - len("(99/99) ")
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "short", text: "  Thanks!\n", want: []string{"Thanks!"}},
+		{name: "without spaces", text: strings.Repeat("a", limit+10),
+			want: []string{"(1/2) " + strings.Repeat("a", limit), "(2/2) " + strings.Repeat("a", 10)}},
+		{name: "at a line break", text: strings.Repeat("a", limit-5) + "\n" + strings.Repeat("b", 20),
+			want: []string{"(1/2) " + strings.Repeat("a", limit-5), "(2/2) " + strings.Repeat("b", 20)}},
+		{name: "counts characters, not bytes", text: strings.Repeat("ä", twilioMaxBodyLength), want: []string{strings.Repeat("ä", twilioMaxBodyLength)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SplitSMS(tt.text))
+		})
+	}
+}
+
+// stubOptOuts reports the chats in optedOut as opted out.
+type stubOptOuts struct {
+	usecase.OptOutRepository
+	optedOut map[int64]bool
+}
+
+func (r *stubOptOuts) IsOptedOut(_ context.Context, _ string, chatID int64) (bool, error) {
+	return r.optedOut[chatID], nil
+}
+
+// twilioStandIn accepts messages at the Messages endpoint, or rejects them
+// with errorCode, and records their bodies.
+type twilioStandIn struct {
+	errorCode int
+	bodies    []string
+}
+
+func (s *twilioStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/2010-04-01/Accounts/AC123/Messages.json" {
+		http.NotFound(w, r)
+		return
+	}
+	_ = r.ParseForm()
+	s.bodies = append(s.bodies, r.PostForm.Get("Body"))
+	if s.errorCode != 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(twilioError{Code: s.errorCode, Message: "rejected", Status: http.StatusBadRequest})
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]string{"sid": fmt.Sprintf("SM%d", len(s.bodies))})
+}
+
+func TestTwilioSendRichMessage(t *testing.T) {
+	chatID := entity.SMSChatIDBase + 15551234567
+	// 2000 characters: 400 words of four letters and a space each.
+	long := strings.TrimSpace(strings.Repeat("word ", 400))
+	tests := []struct {
+		name       string
+		text       string
+		optedOut   bool
+		errorCode  int
+		wantBodies []string
+		wantSID    string
+		wantErr    error
+		wantSends  int
+	}{
+		{name: "short message", text: "Thanks!", wantBodies: []string{"Thanks!"}, wantSID: "SM1", wantSends: 1},
+		{name: "long message is one request", text: strings.Repeat("a", 500), wantBodies: []string{strings.Repeat("a", 500)}, wantSID: "SM1", wantSends: 1},
+		{name: "full message is one request", text: strings.Repeat("a", twilioMaxBodyLength), wantBodies: []string{strings.Repeat("a", twilioMaxBodyLength)}, wantSID: "SM1", wantSends: 1},
+		{name: "2000 characters are two requests", text: long, wantBodies: []string{
+			// The first part ends at the last space that fits beside the prefix.
+			"(1/2) " + long[:1589],
+			"(2/2) " + long[1590:],
+		}, wantSID: "SM2", wantSends: 2},
+		{name: "opted out locally", text: "Thanks!", optedOut: true, wantErr: usecase.ErrRecipientOptedOut},
+		{name: "unsubscribed at Twilio", text: "Thanks!", errorCode: twilioUnsubscribedErrorCode, wantErr: usecase.ErrRecipientOptedOut, wantSends: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn := &twilioStandIn{errorCode: tt.errorCode}
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			client := NewTwilioSMSClient(TwilioConfig{AccountSID: "AC123", AuthToken: "token", FromNumber: "+15550000000", BaseURL: server.URL},
+				&stubOptOuts{optedOut: map[int64]bool{chatID: tt.optedOut}})
+			sid, err := client.SendRichMessage(context.Background(), chatID, entity.TextMessage(tt.text))
+
+			require.Len(t, standIn.bodies, tt.wantSends)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got error %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSID, sid)
+			assert.Equal(t, tt.wantBodies, standIn.bodies)
+			for _, body := range standIn.bodies {
+				assert.LessOrEqual(t, utf8.RuneCountInString(body), twilioMaxBodyLength)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package messenger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+const (
+	// webSocketBacklogSize is how many recent messages per chat are kept for
+	// clients resuming after a reconnect.
+	webSocketBacklogSize = 100
+	webSocketSendBuffer  = 32
+)
+
+const (
+	WebSocketEventReady   = "ready"
+	WebSocketEventMessage = "message"
+	WebSocketEventTyping  = "typing"
+	WebSocketEventResync  = "resync"
+	WebSocketEventError   = "error"
+)
+
+// WebSocketEvent is a server-to-client frame of the web chat protocol. Only
+// message events carry a sequence number, which increases per chat, and a
+// message ID the client acknowledges with delivered and read receipts. The
+// ready event carries the epoch the sequence numbers belong to.
+type WebSocketEvent struct {
+	Type         string              `json:"type"`
+	Epoch        string              `json:"epoch,omitempty"`
+	Seq          int64               `json:"seq,omitempty"`
+	MessageID    string              `json:"message_id,omitempty"`
+	Text         string              `json:"text,omitempty"`
+	QuickReplies []entity.QuickReply `json:"quick_replies,omitempty"`
+	Buttons      []entity.URLButton  `json:"buttons,omitempty"`
+	Active       *bool               `json:"active,omitempty"`
+	SentAt       *time.Time          `json:"sent_at,omitempty"`
+}
+
+// WebSocketSubscription receives the events of one chat for one connection.
+// Events is closed when the subscriber falls too far behind or the hub may
+// have missed messages; the client then reconnects and resumes.
+type WebSocketSubscription struct {
+	ChatID int64
+	Events chan WebSocketEvent
+}
+
+type webSocketChat struct {
+	epoch       string
+	lastSeq     int64
+	backlog     []WebSocketEvent
+	subscribers map[*WebSocketSubscription]struct{}
+	// activeAt is when the chat last got a message or lost a subscriber.
+	activeAt time.Time
+}
+
+// WebSocketHub is the MessengerClient of the web chat. Messages are pushed to
+// every open connection of the chat and kept in a short in-memory backlog.
+// Sequence numbers restart whenever a chat's backlog is created, after a
+// server restart or once Prune dropped it, so each backlog gets a new epoch
+// and clients resuming from another epoch get a resync.
+//
+// With a relay, sent messages reach the hubs of all app instances through
+// it, and every instance numbers them in its own epochs. A client moving to
+// another instance therefore resyncs from the history.
+type WebSocketHub struct {
+	mu    sync.Mutex
+	chats map[int64]*webSocketChat
+	// idleTTL is how long the backlog of a chat without connections is kept.
+	idleTTL    time.Duration
+	relay      usecase.WebChatRelay
+	outboxRepo usecase.OutboxRepository
+}
+
+// NewWebSocketHub creates a hub. Without relay, messages only reach the
+// connections of this instance; or then loads the relayed messages.
+func NewWebSocketHub(idleTTL time.Duration, relay usecase.WebChatRelay, or usecase.OutboxRepository) *WebSocketHub {
+	return &WebSocketHub{
+		chats:      make(map[int64]*webSocketChat),
+		idleTTL:    idleTTL,
+		relay:      relay,
+		outboxRepo: or,
+	}
+}
+
+func (h *WebSocketHub) chat(chatID int64) *webSocketChat {
+	c, ok := h.chats[chatID]
+	if !ok {
+		c = &webSocketChat{
+			epoch:       newWebSocketEpoch(),
+			subscribers: make(map[*WebSocketSubscription]struct{}),
+			activeAt:    time.Now(),
+		}
+		h.chats[chatID] = c
+	}
+	return c
+}
+
+func newWebSocketEpoch() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (h *WebSocketHub) SendMessage(ctx context.Context, chatID int64, text string) error {
+	_, err := h.SendRichMessage(ctx, chatID, entity.TextMessage(text))
+	return err
+}
+
+// SendRichMessage returns message.ID, as clients acknowledge messages by the
+// ID the outbox gave them. With a relay the message is only published; it
+// reaches the connections of every instance, this one included, once the
+// caller's transaction committed.
+func (h *WebSocketHub) SendRichMessage(ctx context.Context, chatID int64, message entity.OutboundMessage) (string, error) {
+	if h.relay != nil && message.ID != "" {
+		if err := h.relay.Publish(ctx, chatID, message.ID); err != nil {
+			return "", err
+		}
+		return message.ID, nil
+	}
+	h.push(chatID, message)
+	return message.ID, nil
+}
+
+// Run delivers the messages published through the relay until ctx ends.
+func (h *WebSocketHub) Run(ctx context.Context) {
+	if h.relay == nil {
+		return
+	}
+	h.relay.Listen(ctx, h.dropAll, func(chatID int64, messageID string) {
+		message, err := h.outboxRepo.FindByMessageID(ctx, chatID, messageID)
+		if err != nil {
+			log.Printf("ERROR: Failed to load relayed web chat message '%s' of chat %d: %v", messageID, chatID, err)
+			return
+		}
+		h.push(chatID, message.Outbound())
+	})
+}
+
+// dropAll forgets every backlog and disconnects all clients, which resync
+// from the history when they reconnect. The relay calls it when messages may
+// have been missed.
+func (h *WebSocketHub) dropAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for chatID, c := range h.chats {
+		for sub := range c.subscribers {
+			close(sub.Events)
+		}
+		delete(h.chats, chatID)
+	}
+}
+
+func (h *WebSocketHub) push(chatID int64, message entity.OutboundMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := h.chat(chatID)
+	c.lastSeq++
+	now := time.Now()
+	c.activeAt = now
+	event := WebSocketEvent{
+		Type:         WebSocketEventMessage,
+		Seq:          c.lastSeq,
+		MessageID:    message.ID,
+		Text:         message.Text,
+		QuickReplies: message.QuickReplies,
+		Buttons:      message.Buttons,
+		SentAt:       &now,
+	}
+	c.backlog = append(c.backlog, event)
+	if len(c.backlog) > webSocketBacklogSize {
+		c.backlog = c.backlog[len(c.backlog)-webSocketBacklogSize:]
+	}
+	h.broadcast(c, event)
+	log.Printf("WEBSOCKET: Pushed message %d to %d connection(s) of chat %d", event.Seq, len(c.subscribers), chatID)
+}
+
+// SetTyping tells the chat's connections whether the bot is composing a reply.
+func (h *WebSocketHub) SetTyping(chatID int64, active bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if c, ok := h.chats[chatID]; ok {
+		h.broadcast(c, WebSocketEvent{Type: WebSocketEventTyping, Active: &active})
+	}
+}
+
+func (h *WebSocketHub) broadcast(c *webSocketChat, event WebSocketEvent) {
+	for sub := range c.subscribers {
+		select {
+		case sub.Events <- event:
+		default:
+			log.Printf("WARN: WebSocket subscriber of chat %d is too slow, disconnecting", sub.ChatID)
+			delete(c.subscribers, sub)
+			close(sub.Events)
+		}
+	}
+}
+
+// Subscribe registers a connection. Messages after lastSeq of epoch are queued
+// on the subscription right away; if some of them already left the backlog or
+// epoch is not the current one, a resync event tells the client to reload the
+// history instead.
+func (h *WebSocketHub) Subscribe(chatID int64, epoch string, lastSeq int64) *WebSocketSubscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := h.chat(chatID)
+	sub := &WebSocketSubscription{
+		ChatID: chatID,
+		Events: make(chan WebSocketEvent, webSocketSendBuffer+webSocketBacklogSize),
+	}
+
+	sub.Events <- WebSocketEvent{Type: WebSocketEventReady, Epoch: c.epoch, Seq: c.lastSeq}
+	switch {
+	case lastSeq > 0 && (epoch != c.epoch || lastSeq > c.lastSeq):
+		// The client saw sequence numbers of a dropped backlog.
+		sub.Events <- WebSocketEvent{Type: WebSocketEventResync, Seq: c.lastSeq}
+	case lastSeq > 0 && len(c.backlog) > 0 && c.backlog[0].Seq > lastSeq+1:
+		sub.Events <- WebSocketEvent{Type: WebSocketEventResync, Seq: c.lastSeq}
+	case lastSeq > 0:
+		for _, event := range c.backlog {
+			if event.Seq > lastSeq {
+				sub.Events <- event
+			}
+		}
+	}
+	c.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (h *WebSocketHub) Unsubscribe(sub *WebSocketSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c, ok := h.chats[sub.ChatID]
+	if !ok {
+		return
+	}
+	if _, ok := c.subscribers[sub]; ok {
+		delete(c.subscribers, sub)
+		close(sub.Events)
+	}
+	c.activeAt = time.Now()
+}
+
+// Prune drops the backlogs of chats that had no connection and no message
+// for idleTTL, so the hub does not grow with every chat ever served.
+func (h *WebSocketHub) Prune(_ context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	before := time.Now().Add(-h.idleTTL)
+	pruned := 0
+	for chatID, c := range h.chats {
+		if len(c.subscribers) == 0 && c.activeAt.Before(before) {
+			delete(h.chats, chatID)
+			pruned++
+		}
+	}
+	if pruned > 0 {
+		log.Printf("WEBSOCKET: Dropped the backlog of %d idle chat(s)", pruned)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package messenger
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+// drain returns the events queued on sub.
+func drain(sub *WebSocketSubscription) []WebSocketEvent {
+	var events []WebSocketEvent
+	for {
+		select {
+		case event, ok := <-sub.Events:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func eventTypes(events []WebSocketEvent) []string {
+	types := make([]string, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func sendTexts(t *testing.T, hub *WebSocketHub, chatID int64, n int) {
+	t.Helper()
+	for i := range n {
+		_, err := hub.SendRichMessage(context.Background(), chatID, entity.OutboundMessage{ID: fmt.Sprintf("m%d", i+1), Text: "hi"})
+		require.NoError(t, err)
+	}
+}
+
+func TestWebSocketHubResume(t *testing.T) {
+	const chatID = entity.WebChatIDBase + 1
+	tests := []struct {
+		name string
+		// sent messages before the client resumes.
+		sent       int
+		staleEpoch bool
+		lastSeq    int64
+		wantTypes  []string
+		wantSeqs   []int64
+	}{
+		{name: "new connection", sent: 3, lastSeq: 0, wantTypes: []string{WebSocketEventReady}},
+		{name: "up to date", sent: 3, lastSeq: 3, wantTypes: []string{WebSocketEventReady}},
+		{name: "missed messages", sent: 3, lastSeq: 1,
+			wantTypes: []string{WebSocketEventReady, WebSocketEventMessage, WebSocketEventMessage}, wantSeqs: []int64{2, 3}},
+		{name: "stale epoch", sent: 3, staleEpoch: true, lastSeq: 1,
+			wantTypes: []string{WebSocketEventReady, WebSocketEventResync}},
+		{name: "sequence ahead of the hub", sent: 3, lastSeq: 7,
+			wantTypes: []string{WebSocketEventReady, WebSocketEventResync}},
+		{name: "missed messages left the backlog", sent: webSocketBacklogSize + 5, lastSeq: 3,
+			wantTypes: []string{WebSocketEventReady, WebSocketEventResync}},
+		{name: "missed the whole backlog", sent: webSocketBacklogSize + 5, lastSeq: 5,
+			wantTypes: append([]string{WebSocketEventReady}, repeat(WebSocketEventMessage, webSocketBacklogSize)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewWebSocketHub(time.Hour, nil, nil)
+			first := hub.Subscribe(chatID, "", 0)
+			ready := drain(first)
+			require.Len(t, ready, 1)
+			epoch := ready[0].Epoch
+			hub.Unsubscribe(first)
+
+			sendTexts(t, hub, chatID, tt.sent)
+			if tt.staleEpoch {
+				epoch = "stale"
+			}
+			sub := hub.Subscribe(chatID, epoch, tt.lastSeq)
+			events := drain(sub)
+
+			assert.Equal(t, tt.wantTypes, eventTypes(events))
+			assert.Equal(t, epoch != "stale", events[0].Epoch == epoch)
+			assert.Equal(t, int64(tt.sent), events[0].Seq)
+			for i, seq := range tt.wantSeqs {
+				assert.Equal(t, seq, events[i+1].Seq)
+			}
+		})
+	}
+}
+
+func repeat(s string, n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = s
+	}
+	return out
+}
+
+func TestWebSocketHubDisconnectsSlowSubscribers(t *testing.T) {
+	const chatID = entity.WebChatIDBase + 1
+	hub := NewWebSocketHub(time.Hour, nil, nil)
+	sub := hub.Subscribe(chatID, "", 0)
+
+	// The subscription holds the ready event and its buffer.
+	sendTexts(t, hub, chatID, webSocketSendBuffer+webSocketBacklogSize)
+	events := drain(sub)
+	_, open := <-sub.Events
+	assert.False(t, open, "subscription of a slow client stays open")
+	assert.Len(t, events, webSocketSendBuffer+webSocketBacklogSize)
+
+	// Unsubscribing the closed subscription must not close it again.
+	hub.Unsubscribe(sub)
+}
+
+func TestWebSocketHubPrune(t *testing.T) {
+	hub := NewWebSocketHub(time.Hour, nil, nil)
+	sendTexts(t, hub, 1, 1)
+	sendTexts(t, hub, 2, 1)
+	sendTexts(t, hub, 3, 1)
+	connected := hub.Subscribe(3, "", 0)
+	for _, chatID := range []int64{1, 3} {
+		hub.chats[chatID].activeAt = time.Now().Add(-2 * time.Hour)
+	}
+	epoch := hub.chats[1].epoch
+
+	require.NoError(t, hub.Prune(context.Background()))
+
+	assert.NotContains(t, hub.chats, int64(1), "idle chat is kept")
+	assert.Contains(t, hub.chats, int64(2), "recently active chat is dropped")
+	assert.Contains(t, hub.chats, int64(3), "connected chat is dropped")
+
+	// A client of the pruned chat resumes into a new epoch and resyncs.
+	events := drain(hub.Subscribe(1, epoch, 1))
+	assert.Equal(t, []string{WebSocketEventReady, WebSocketEventResync}, eventTypes(events))
+	assert.NotEqual(t, epoch, events[0].Epoch)
+	hub.Unsubscribe(connected)
+}
+
+func TestWebSocketHubSetTypingKeepsNoChat(t *testing.T) {
+	hub := NewWebSocketHub(time.Hour, nil, nil)
+	hub.SetTyping(1, true)
+	assert.Empty(t, hub.chats)
+}
+
+// loopbackRelay delivers published messages right away, as if every
+// instance shared this hub.
+type loopbackRelay struct {
+	published []string
+	deliver   func(chatID int64, messageID string)
+	ready     func()
+}
+
+func (r *loopbackRelay) Publish(_ context.Context, chatID int64, messageID string) error {
+	r.published = append(r.published, messageID)
+	r.deliver(chatID, messageID)
+	return nil
+}
+
+func (r *loopbackRelay) Listen(_ context.Context, ready func(), deliver func(chatID int64, messageID string)) {
+	r.ready, r.deliver = ready, deliver
+	ready()
+}
+
+// stubOutbox finds the messages it holds.
+type stubOutbox struct {
+	usecase.OutboxRepository
+	messages map[string]*entity.OutboxMessage
+}
+
+func (o *stubOutbox) FindByMessageID(_ context.Context, chatID int64, messageID string) (*entity.OutboxMessage, error) {
+	if m, ok := o.messages[messageID]; ok && m.ChatID == chatID {
+		return m, nil
+	}
+	return nil, usecase.ErrOutboxMessageNotFound
+}
+
+func TestWebSocketHubRelay(t *testing.T) {
+	const chatID = entity.WebChatIDBase + 1
+	relay := &loopbackRelay{}
+	outbox := &stubOutbox{messages: map[string]*entity.OutboxMessage{
+		"m1": {MessageID: "m1", ChatID: chatID, Text: "from the outbox"},
+	}}
+	hub := NewWebSocketHub(time.Hour, relay, outbox)
+	hub.Run(context.Background())
+	sub := hub.Subscribe(chatID, "", 0)
+	drain(sub)
+
+	id, err := hub.SendRichMessage(context.Background(), chatID, entity.OutboundMessage{ID: "m1", Text: "ignored"})
+	require.NoError(t, err)
+	assert.Equal(t, "m1", id)
+	assert.Equal(t, []string{"m1"}, relay.published)
+	events := drain(sub)
+	require.Len(t, events, 1)
+	assert.Equal(t, "from the outbox", events[0].Text)
+	assert.Equal(t, "m1", events[0].MessageID)
+
+	// A message the outbox does not know for the chat is not pushed.
+	relay.deliver(chatID, "unknown")
+	assert.Empty(t, drain(sub))
+
+	// After the relay reconnects, clients are dropped to resync.
+	relay.ready()
+	_, open := <-sub.Events
+	assert.False(t, open)
+	assert.Empty(t, hub.chats)
+}
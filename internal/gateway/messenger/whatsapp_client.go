@@ -0,0 +1,519 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+const DefaultWhatsAppBaseURL = "https://graph.facebook.com/v19.0"
+
+// Limits of interactive messages.
+const (
+	whatsAppMaxReplyButtons     = 3
+	whatsAppMaxButtonTitle      = 20
+	whatsAppMaxListRows         = 10
+	whatsAppMaxListRowTitle     = 24
+	whatsAppMaxInteractiveBody  = 1024
+	whatsAppMaxInteractiveReply = 256
+)
+
+// whatsAppReengagementErrorCode is returned when a free-form message is sent
+// outside the 24-hour customer service window.
+const whatsAppReengagementErrorCode = 131047
+
+// whatsAppServiceWindow is how long after the customer's last message free-form
+// messages are delivered. The margin keeps messages queued close to its end
+// from failing.
+const (
+	whatsAppServiceWindow       = 24 * time.Hour
+	whatsAppServiceWindowMargin = 5 * time.Minute
+)
+
+type WhatsAppConfig struct {
+	AccessToken   string
+	PhoneNumberID string
+	// BaseURL replaces the Graph API URL, e.g. with a local stand-in.
+	BaseURL string
+	// ReviewTemplate is the approved template sent instead of a review
+	// request when the customer has not written within the last 24 hours.
+	ReviewTemplate   string
+	TemplateLanguage string
+}
+
+// WhatsAppClient sends messages through the WhatsApp Cloud API. Customers are
+// identified by their phone number, which doubles as chat and user ID (see PhoneToID).
+type WhatsAppClient struct {
+	cfg        WhatsAppConfig
+	convoRepo  usecase.ConversationRepository
+	httpClient *http.Client
+}
+
+func NewWhatsAppClient(cfg WhatsAppConfig, cr usecase.ConversationRepository) *WhatsAppClient {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultWhatsAppBaseURL
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if cfg.TemplateLanguage == "" {
+		cfg.TemplateLanguage = "en_US"
+	}
+	return &WhatsAppClient{
+		cfg:        cfg,
+		convoRepo:  cr,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type whatsAppError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *whatsAppError) Error() string {
+	return fmt.Sprintf("whatsapp error %d: %s", e.Code, e.Message)
+}
+
+func (c *WhatsAppClient) SendMessage(ctx context.Context, chatID int64, text string) error {
+	_, err := c.SendRichMessage(ctx, chatID, entity.TextMessage(text))
+	return err
+}
+
+// SendRichMessage renders up to three quick replies as reply buttons, up to
+// ten as a list, and a lone URL button as a call-to-action button. Anything
+// WhatsApp cannot show natively is sent as text. Outside the customer's 24h
+// window review requests are replaced by the review template, if one is
+// configured; other messages fail with usecase.ErrOutsideServiceWindow, so
+// the outbox and history do not claim a text was sent that was not. The
+// returned wamid is what status webhooks refer to.
+func (c *WhatsAppClient) SendRichMessage(ctx context.Context, chatID int64, message entity.OutboundMessage) (string, error) {
+	to, err := IDToPhone(entity.WhatsAppChatIDBase, chatID)
+	if err != nil {
+		return "", err
+	}
+	if c.cfg.ReviewTemplate != "" {
+		open, err := c.inServiceWindow(ctx, chatID)
+		if err != nil {
+			return "", err
+		}
+		if !open {
+			return c.sendOutsideWindow(ctx, chatID, message)
+		}
+	}
+	payload := map[string]any{
+		"messaging_product": "whatsapp",
+		"to":                to,
+	}
+	if interactive, ok := whatsAppInteractive(message); ok {
+		payload["type"] = "interactive"
+		payload["interactive"] = interactive
+	} else {
+		payload["type"] = "text"
+		payload["text"] = map[string]any{"body": message.FallbackText()}
+	}
+	messageID, err := c.send(ctx, payload)
+
+	// The window may still close between the check and the send.
+	var waErr *whatsAppError
+	if errors.As(err, &waErr) && waErr.Code == whatsAppReengagementErrorCode {
+		return c.sendOutsideWindow(ctx, chatID, message)
+	}
+	if err != nil {
+		log.Printf("ERROR: WhatsApp message to chat %d failed: %v", chatID, err)
+		return "", err
+	}
+	log.Printf("WHATSAPP: Sent message %s to chat %d", messageID, chatID)
+	return messageID, nil
+}
+
+// sendOutsideWindow sends the review template in place of a review request
+// and refuses other messages.
+func (c *WhatsAppClient) sendOutsideWindow(ctx context.Context, chatID int64, message entity.OutboundMessage) (string, error) {
+	if !message.ReviewRequest || c.cfg.ReviewTemplate == "" {
+		log.Printf("WHATSAPP: Chat %d is outside the 24h window, not sending message %s", chatID, message.ID)
+		return "", fmt.Errorf("%w for WhatsApp chat %d", usecase.ErrOutsideServiceWindow, chatID)
+	}
+	log.Printf("WHATSAPP: Chat %d is outside the 24h window, sending template '%s' instead", chatID, c.cfg.ReviewTemplate)
+	return c.SendTemplate(ctx, chatID, c.cfg.ReviewTemplate, nil)
+}
+
+// inServiceWindow reports whether the customer wrote within the last 24 hours.
+func (c *WhatsAppClient) inServiceWindow(ctx context.Context, chatID int64) (bool, error) {
+	conversation, err := c.convoRepo.FindExisting(ctx, chatID)
+	if errors.Is(err, usecase.ErrConversationNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return time.Since(conversation.LastInboundAt) < whatsAppServiceWindow-whatsAppServiceWindowMargin, nil
+}
+
+func whatsAppInteractive(message entity.OutboundMessage) (map[string]any, bool) {
+	if !message.IsRich() {
+		return nil, false
+	}
+
+	if len(message.QuickReplies) == 0 {
+		if len(message.Buttons) != 1 || utf8.RuneCountInString(message.Text) > whatsAppMaxInteractiveBody {
+			return nil, false
+		}
+		button := message.Buttons[0]
+		return map[string]any{
+			"type": "cta_url",
+			"body": map[string]any{"text": message.Text},
+			"action": map[string]any{
+				"name":       "cta_url",
+				"parameters": map[string]any{"display_text": truncateRunes(button.Title, whatsAppMaxButtonTitle), "url": button.URL},
+			},
+		}, true
+	}
+
+	body := message.TextWithLinks()
+	if utf8.RuneCountInString(body) > whatsAppMaxInteractiveBody || len(message.QuickReplies) > whatsAppMaxListRows {
+		return nil, false
+	}
+	for _, qr := range message.QuickReplies {
+		if len(qr.Payload) > whatsAppMaxInteractiveReply {
+			return nil, false
+		}
+	}
+
+	if len(message.QuickReplies) <= whatsAppMaxReplyButtons {
+		buttons := make([]map[string]any, 0, len(message.QuickReplies))
+		for _, qr := range message.QuickReplies {
+			buttons = append(buttons, map[string]any{
+				"type":  "reply",
+				"reply": map[string]any{"id": qr.Payload, "title": truncateRunes(qr.Title, whatsAppMaxButtonTitle)},
+			})
+		}
+		return map[string]any{
+			"type":   "button",
+			"body":   map[string]any{"text": body},
+			"action": map[string]any{"buttons": buttons},
+		}, true
+	}
+
+	rows := make([]map[string]any, 0, len(message.QuickReplies))
+	for _, qr := range message.QuickReplies {
+		rows = append(rows, map[string]any{"id": qr.Payload, "title": truncateRunes(qr.Title, whatsAppMaxListRowTitle)})
+	}
+	return map[string]any{
+		"type": "list",
+		"body": map[string]any{"text": body},
+		"action": map[string]any{
+			"button":   "Choose",
+			"sections": []map[string]any{{"title": "Options", "rows": rows}},
+		},
+	}, true
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// SendTemplate sends a pre-approved template message, which WhatsApp allows
+// outside the 24-hour window. params fill the template's body placeholders.
+func (c *WhatsAppClient) SendTemplate(ctx context.Context, chatID int64, name string, params []string) (string, error) {
+	to, err := IDToPhone(entity.WhatsAppChatIDBase, chatID)
+	if err != nil {
+		return "", err
+	}
+	template := map[string]any{
+		"name":     name,
+		"language": map[string]any{"code": c.cfg.TemplateLanguage},
+	}
+	if len(params) > 0 {
+		parameters := make([]map[string]any, 0, len(params))
+		for _, p := range params {
+			parameters = append(parameters, map[string]any{"type": "text", "text": p})
+		}
+		template["components"] = []map[string]any{{"type": "body", "parameters": parameters}}
+	}
+	payload := map[string]any{
+		"messaging_product": "whatsapp",
+		"to":                to,
+		"type":              "template",
+		"template":          template,
+	}
+	messageID, err := c.send(ctx, payload)
+	if err != nil {
+		log.Printf("ERROR: WhatsApp template '%s' to chat %d failed: %v", name, chatID, err)
+		return "", err
+	}
+	log.Printf("WHATSAPP: Sent template '%s' to chat %d", name, chatID)
+	return messageID, nil
+}
+
+// send posts a message and returns its wamid.
+func (c *WhatsAppClient) send(ctx context.Context, payload map[string]any) (string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode whatsapp request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/%s/messages", c.cfg.BaseURL, c.cfg.PhoneNumberID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create whatsapp request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.cfg.AccessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("whatsapp request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		var sent struct {
+			Messages []struct {
+				ID string `json:"id"`
+			} `json:"messages"`
+		}
+		if json.Unmarshal(respBody, &sent) != nil || len(sent.Messages) == 0 {
+			// The message went out; only its receipts cannot be matched.
+			log.Printf("WARN: WhatsApp response without message id: %s", respBody)
+			return "", nil
+		}
+		return sent.Messages[0].ID, nil
+	}
+	var errResp struct {
+		Error *whatsAppError `json:"error"`
+	}
+	if json.Unmarshal(respBody, &errResp) == nil && errResp.Error != nil {
+		return "", errResp.Error
+	}
+	return "", fmt.Errorf("whatsapp request failed with status %d: %s", resp.StatusCode, respBody)
+}
+
+// FetchMedia downloads the media with the given ID. The Graph API first
+// returns a short-lived URL, which also requires the access token.
+func (c *WhatsAppClient) FetchMedia(ctx context.Context, mediaID string) (io.ReadCloser, error) {
+	resp, err := c.get(ctx, fmt.Sprintf("%s/%s", c.cfg.BaseURL, url.PathEscape(mediaID)))
+	if err != nil {
+		return nil, err
+	}
+	var media struct {
+		URL string `json:"url"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&media)
+	resp.Body.Close()
+	if err != nil || media.URL == "" {
+		return nil, fmt.Errorf("whatsapp media %s has no download url", mediaID)
+	}
+
+	resp, err = c.get(ctx, media.URL)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *WhatsAppClient) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create whatsapp request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.cfg.AccessToken)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("whatsapp request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("whatsapp request failed with status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// VerifyWhatsAppSignature checks the X-Hub-Signature-256 header, an HMAC-SHA256
+// of the raw request body keyed with the app secret.
+func VerifyWhatsAppSignature(appSecret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+type WhatsAppWebhookPayload struct {
+	Object string `json:"object"`
+	Entry  []struct {
+		ID      string `json:"id"`
+		Changes []struct {
+			Field string              `json:"field"`
+			Value WhatsAppChangeValue `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+type WhatsAppChangeValue struct {
+	MessagingProduct string `json:"messaging_product"`
+	Contacts         []struct {
+		WaID    string `json:"wa_id"`
+		Profile struct {
+			Name string `json:"name"`
+		} `json:"profile"`
+	} `json:"contacts"`
+	Messages []WhatsAppMessage `json:"messages"`
+	Statuses []WhatsAppStatus  `json:"statuses"`
+}
+
+// WhatsAppStatus reports the delivery status of a message we sent.
+type WhatsAppStatus struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	Timestamp   string `json:"timestamp"`
+	RecipientID string `json:"recipient_id"`
+	Errors      []struct {
+		Code  int    `json:"code"`
+		Title string `json:"title"`
+	} `json:"errors,omitempty"`
+}
+
+type WhatsAppMessage struct {
+	From      string `json:"from"`
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Text      *struct {
+		Body string `json:"body"`
+	} `json:"text,omitempty"`
+	Interactive *struct {
+		Type        string               `json:"type"`
+		ButtonReply *WhatsAppReplyOption `json:"button_reply,omitempty"`
+		ListReply   *WhatsAppReplyOption `json:"list_reply,omitempty"`
+	} `json:"interactive,omitempty"`
+	Image *struct {
+		ID       string `json:"id"`
+		MimeType string `json:"mime_type"`
+		Caption  string `json:"caption,omitempty"`
+	} `json:"image,omitempty"`
+}
+
+type WhatsAppReplyOption struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// content returns the text of a message and, for a tapped reply button or
+// list row, its payload.
+func (m WhatsAppMessage) content() (text, payload string) {
+	switch m.Type {
+	case "text":
+		if m.Text != nil {
+			return m.Text.Body, ""
+		}
+	case "interactive":
+		if m.Interactive == nil {
+			return "", ""
+		}
+		for _, option := range []*WhatsAppReplyOption{m.Interactive.ButtonReply, m.Interactive.ListReply} {
+			if option != nil {
+				return option.Title, option.ID
+			}
+		}
+	}
+	return "", ""
+}
+
+// Inputs maps every text, image and reply message in the webhook payload to
+// a HandleMessageInput.
+func (p WhatsAppWebhookPayload) Inputs() []usecase.HandleMessageInput {
+	var inputs []usecase.HandleMessageInput
+	for _, entry := range p.Entry {
+		for _, change := range entry.Changes {
+			if change.Field != "messages" {
+				continue
+			}
+			names := make(map[string]string, len(change.Value.Contacts))
+			for _, contact := range change.Value.Contacts {
+				names[contact.WaID] = contact.Profile.Name
+			}
+			for _, msg := range change.Value.Messages {
+				text, payload := msg.content()
+				var media []entity.InboundMedia
+				if msg.Type == "image" && msg.Image != nil {
+					text = msg.Image.Caption
+					media = []entity.InboundMedia{{Ref: msg.Image.ID, ContentType: msg.Image.MimeType}}
+				}
+				if text == "" && len(media) == 0 {
+					continue
+				}
+				id, err := PhoneToID(entity.WhatsAppChatIDBase, msg.From)
+				if err != nil {
+					log.Printf("WARN: Skipping WhatsApp message %s: %v", msg.ID, err)
+					continue
+				}
+				inputs = append(inputs, usecase.HandleMessageInput{
+					ChatID:    id,
+					UserID:    id,
+					UserName:  names[msg.From],
+					Text:      text,
+					MessageID: msg.ID,
+					Channel:   entity.ChannelWhatsApp,
+					Payload:   payload,
+					Media:     media,
+				})
+			}
+		}
+	}
+	return inputs
+}
+
+// Receipts maps the status updates in the webhook payload to delivery receipts.
+func (p WhatsAppWebhookPayload) Receipts() []entity.DeliveryReceipt {
+	var receipts []entity.DeliveryReceipt
+	for _, entry := range p.Entry {
+		for _, change := range entry.Changes {
+			if change.Field != "messages" {
+				continue
+			}
+			for _, st := range change.Value.Statuses {
+				status := st.Status
+				switch status {
+				case entity.OutboxStatusSent, entity.OutboxStatusDelivered, entity.OutboxStatusRead, entity.OutboxStatusFailed:
+				default:
+					continue
+				}
+				receipt := entity.DeliveryReceipt{ExternalID: st.ID, Status: status, At: time.Now()}
+				if sec, err := strconv.ParseInt(st.Timestamp, 10, 64); err == nil {
+					receipt.At = time.Unix(sec, 0)
+				}
+				if len(st.Errors) > 0 {
+					receipt.Error = fmt.Sprintf("whatsapp error %d: %s", st.Errors[0].Code, st.Errors[0].Title)
+				}
+				receipts = append(receipts, receipt)
+			}
+		}
+	}
+	return receipts
+}
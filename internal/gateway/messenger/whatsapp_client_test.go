@@ -0,0 +1,266 @@
+package messenger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+func whatsAppSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWhatsAppSignature(t *testing.T) {
+	body := []byte(`{"object":"whatsapp_business_account"}`)
+	valid := whatsAppSignature("app-secret", body)
+	tests := []struct {
+		name   string
+		body   []byte
+		header string
+		want   bool
+	}{
+		{name: "valid", body: body, header: valid, want: true},
+		{name: "missing", body: body, header: ""},
+		{name: "without prefix", body: body, header: valid[len("sha256="):]},
+		{name: "not hex", body: body, header: "sha256=zz"},
+		{name: "other secret", body: body, header: whatsAppSignature("other-secret", body)},
+		{name: "tampered body", body: []byte(`{"object":"page"}`), header: valid},
+		{name: "truncated", body: body, header: valid[:len(valid)-2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, VerifyWhatsAppSignature("app-secret", tt.body, tt.header))
+		})
+	}
+}
+
+// stubConversations answers FindExisting from a map.
+type stubConversations struct {
+	usecase.ConversationRepository
+	conversations map[int64]*entity.Conversation
+}
+
+func (r *stubConversations) FindExisting(_ context.Context, chatID int64) (*entity.Conversation, error) {
+	if c, ok := r.conversations[chatID]; ok {
+		return c, nil
+	}
+	return nil, usecase.ErrConversationNotFound
+}
+
+// whatsAppStandIn accepts messages at the Graph API messages endpoint and
+// records their types. Free-form messages are rejected with rejectCode, if set.
+type whatsAppStandIn struct {
+	rejectCode int
+	sent       []string
+}
+
+func (s *whatsAppStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Type string `json:"type"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+	s.sent = append(s.sent, payload.Type)
+	if s.rejectCode != 0 && payload.Type != "template" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]any{"error": whatsAppError{Code: s.rejectCode, Message: "rejected"}})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"messages": []map[string]string{{"id": "wamid.1"}}})
+}
+
+func TestWhatsAppSendRichMessageServiceWindow(t *testing.T) {
+	chatID := entity.WhatsAppChatIDBase + 4915112345678
+	tests := []struct {
+		name          string
+		lastInboundAt time.Time
+		noTemplate    bool
+		noChat        bool
+		// reply is a message other than a review request.
+		reply      bool
+		rejectCode int
+		want       []string
+		wantErr    bool
+		// wantClosed expects usecase.ErrOutsideServiceWindow.
+		wantClosed bool
+	}{
+		{name: "customer wrote recently", lastInboundAt: time.Now().Add(-time.Hour), want: []string{"text"}},
+		{name: "window closed", lastInboundAt: time.Now().Add(-25 * time.Hour), want: []string{"template"}},
+		{name: "window about to close", lastInboundAt: time.Now().Add(-24*time.Hour + time.Minute), want: []string{"template"}},
+		{name: "customer never wrote", want: []string{"template"}},
+		{name: "unknown chat", noChat: true, want: []string{"template"}},
+		{name: "reply in the window", lastInboundAt: time.Now().Add(-time.Hour), reply: true, want: []string{"text"}},
+		{name: "reply after the window closed", lastInboundAt: time.Now().Add(-25 * time.Hour), reply: true, wantErr: true, wantClosed: true},
+		{
+			name:          "window closed without template",
+			lastInboundAt: time.Now().Add(-25 * time.Hour),
+			noTemplate:    true,
+			rejectCode:    whatsAppReengagementErrorCode,
+			want:          []string{"text"},
+			wantErr:       true,
+			wantClosed:    true,
+		},
+		{
+			name:          "rejected for the window anyway",
+			lastInboundAt: time.Now().Add(-time.Hour),
+			rejectCode:    whatsAppReengagementErrorCode,
+			want:          []string{"text", "template"},
+		},
+		{
+			name:          "reply rejected for the window",
+			lastInboundAt: time.Now().Add(-time.Hour),
+			reply:         true,
+			rejectCode:    whatsAppReengagementErrorCode,
+			want:          []string{"text"},
+			wantErr:       true,
+			wantClosed:    true,
+		},
+		{
+			name:          "rejected for another reason",
+			lastInboundAt: time.Now().Add(-time.Hour),
+			rejectCode:    131026,
+			want:          []string{"text"},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn := &whatsAppStandIn{rejectCode: tt.rejectCode}
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			conversations := &stubConversations{conversations: map[int64]*entity.Conversation{}}
+			if !tt.noChat {
+				conversations.conversations[chatID] = &entity.Conversation{ChatID: chatID, LastInboundAt: tt.lastInboundAt}
+			}
+			cfg := WhatsAppConfig{AccessToken: "token", PhoneNumberID: "123", BaseURL: server.URL, ReviewTemplate: "review_request"}
+			if tt.noTemplate {
+				cfg.ReviewTemplate = ""
+			}
+			client := NewWhatsAppClient(cfg, conversations)
+
+			message := entity.OutboundMessage{Text: "How was your visit?", ReviewRequest: !tt.reply}
+			id, err := client.SendRichMessage(context.Background(), chatID, message)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantClosed, errors.Is(err, usecase.ErrOutsideServiceWindow))
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "wamid.1", id)
+			}
+			assert.Equal(t, tt.want, standIn.sent)
+		})
+	}
+}
+
+func TestWhatsAppInteractive(t *testing.T) {
+	replies := func(n int) []entity.QuickReply {
+		out := make([]entity.QuickReply, n)
+		for i := range out {
+			out[i] = entity.QuickReply{Title: fmt.Sprintf("Option %d", i+1), Payload: fmt.Sprintf("p%d", i+1)}
+		}
+		return out
+	}
+	review := entity.URLButton{Title: "Leave a review on our page", URL: "https://example.com/r/1"}
+	tests := []struct {
+		name    string
+		message entity.OutboundMessage
+		// want is the interactive object; nil expects a text message.
+		want map[string]any
+	}{
+		{name: "plain text", message: entity.TextMessage("hi")},
+		{
+			name:    "reply buttons",
+			message: entity.OutboundMessage{Text: "Rate us", QuickReplies: replies(2)},
+			want: map[string]any{
+				"type": "button",
+				"body": map[string]any{"text": "Rate us"},
+				"action": map[string]any{"buttons": []any{
+					map[string]any{"type": "reply", "reply": map[string]any{"id": "p1", "title": "Option 1"}},
+					map[string]any{"type": "reply", "reply": map[string]any{"id": "p2", "title": "Option 2"}},
+				}},
+			},
+		},
+		{
+			name:    "list",
+			message: entity.OutboundMessage{Text: "Rate us", QuickReplies: replies(4)},
+			want: map[string]any{
+				"type": "list",
+				"body": map[string]any{"text": "Rate us"},
+				"action": map[string]any{
+					"button": "Choose",
+					"sections": []any{map[string]any{"title": "Options", "rows": []any{
+						map[string]any{"id": "p1", "title": "Option 1"},
+						map[string]any{"id": "p2", "title": "Option 2"},
+						map[string]any{"id": "p3", "title": "Option 3"},
+						map[string]any{"id": "p4", "title": "Option 4"},
+					}}},
+				},
+			},
+		},
+		{name: "more options than list rows", message: entity.OutboundMessage{Text: "Pick", QuickReplies: replies(whatsAppMaxListRows + 1)}},
+		{
+			name:    "URL button",
+			message: entity.OutboundMessage{Text: "Thanks!", Buttons: []entity.URLButton{review}},
+			want: map[string]any{
+				"type": "cta_url",
+				"body": map[string]any{"text": "Thanks!"},
+				"action": map[string]any{
+					"name":       "cta_url",
+					"parameters": map[string]any{"display_text": "Leave a review on o…", "url": "https://example.com/r/1"},
+				},
+			},
+		},
+		{name: "two URL buttons", message: entity.OutboundMessage{Text: "Thanks!", Buttons: []entity.URLButton{review, review}}},
+		{
+			name:    "quick reply with URL button",
+			message: entity.OutboundMessage{Text: "Thanks!", QuickReplies: replies(1), Buttons: []entity.URLButton{review}},
+			want: map[string]any{
+				"type": "button",
+				"body": map[string]any{"text": "Thanks!\n\nLeave a review on our page: https://example.com/r/1"},
+				"action": map[string]any{"buttons": []any{
+					map[string]any{"type": "reply", "reply": map[string]any{"id": "p1", "title": "Option 1"}},
+				}},
+			},
+		},
+		{
+			name:    "body too long",
+			message: entity.OutboundMessage{Text: strings.Repeat("a", whatsAppMaxInteractiveBody+1), QuickReplies: replies(2)},
+		},
+		{
+			name:    "payload too long",
+			message: entity.OutboundMessage{Text: "Pick", QuickReplies: []entity.QuickReply{{Title: "A", Payload: strings.Repeat("p", whatsAppMaxInteractiveReply+1)}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interactive, ok := whatsAppInteractive(tt.message)
+			assert.Equal(t, tt.want != nil, ok)
+			if tt.want == nil {
+				return
+			}
+			// Compare the JSON sent to the API rather than the Go types.
+			raw, err := json.Marshal(interactive)
+			require.NoError(t, err)
+			var got map[string]any
+			require.NoError(t, json.Unmarshal(raw, &got))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
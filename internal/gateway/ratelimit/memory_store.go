@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"smb-chatbot/internal/usecase"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// MemoryStore keeps token buckets and mutes in process memory, so each app
+// instance enforces the limits on its own.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	mutes   map[string]time.Time
+	// now is the clock buckets are refilled and mutes end by; tests replace it.
+	now func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		mutes:   make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit usecase.RateLimit) (bool, time.Duration, error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updatedAt: now}
+		s.buckets[key] = b
+	}
+	b.tokens = min(float64(limit.Burst), b.tokens+now.Sub(b.updatedAt).Seconds()*limit.PerSecond())
+	b.updatedAt = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / limit.PerSecond() * float64(time.Second)), nil
+	}
+	b.tokens--
+	return true, 0, nil
+}
+
+func (s *MemoryStore) Mute(_ context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mutes[key] = until
+	return nil
+}
+
+func (s *MemoryStore) MutedUntil(_ context.Context, key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.mutes[key]
+	if !ok || !until.After(s.now()) {
+		return time.Time{}, nil
+	}
+	return until, nil
+}
+
+func (s *MemoryStore) Prune(_ context.Context, before time.Time) error {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, b := range s.buckets {
+		if b.updatedAt.Before(before) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, until := range s.mutes {
+		if !until.After(now) {
+			delete(s.mutes, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/usecase"
+)
+
+// manualClock only moves when the test advances it.
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time { return c.now }
+
+func (c *manualClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestStore() (*MemoryStore, *manualClock) {
+	clock := &manualClock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = clock.Now
+	return store, clock
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	// Three tokens, one every 100ms.
+	limit := usecase.RateLimit{Burst: 3, Interval: 300 * time.Millisecond}
+	tests := []struct {
+		name string
+		// waits advance the clock before each take.
+		waits          []time.Duration
+		want           []bool
+		wantRetryAfter []time.Duration
+	}{
+		{name: "burst", waits: []time.Duration{0, 0, 0}, want: []bool{true, true, true}},
+		{name: "empty after burst", waits: []time.Duration{0, 0, 0, 0}, want: []bool{true, true, true, false},
+			wantRetryAfter: []time.Duration{0, 0, 0, 100 * time.Millisecond}},
+		{name: "partly refilled", waits: []time.Duration{0, 0, 0, 40 * time.Millisecond}, want: []bool{true, true, true, false},
+			wantRetryAfter: []time.Duration{0, 0, 0, 60 * time.Millisecond}},
+		{name: "refills one token", waits: []time.Duration{0, 0, 0, 0, 100 * time.Millisecond, 0}, want: []bool{true, true, true, false, true, false},
+			wantRetryAfter: []time.Duration{0, 0, 0, 100 * time.Millisecond, 0, 100 * time.Millisecond}},
+		{name: "refills no more than burst", waits: []time.Duration{0, time.Hour, 0, 0, 0}, want: []bool{true, true, true, true, false},
+			wantRetryAfter: []time.Duration{0, 0, 0, 0, 100 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, clock := newTestStore()
+			for i, wait := range tt.waits {
+				clock.Advance(wait)
+				ok, retryAfter, err := store.Take(context.Background(), "ip:1", limit)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want[i], ok, "take %d", i)
+				var want time.Duration
+				if tt.wantRetryAfter != nil {
+					want = tt.wantRetryAfter[i]
+				}
+				assert.InDelta(t, want, retryAfter, float64(time.Microsecond), "retry after of take %d", i)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeysAreSeparate(t *testing.T) {
+	store := NewMemoryStore()
+	limit := usecase.RateLimit{Burst: 1, Interval: time.Hour}
+
+	ok, _, err := store.Take(context.Background(), "ip:1", limit)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, _, err = store.Take(context.Background(), "ip:1", limit)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, _, err = store.Take(context.Background(), "ip:2", limit)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestMemoryStoreMute(t *testing.T) {
+	tests := []struct {
+		name  string
+		mute  time.Duration
+		wait  time.Duration
+		want  time.Duration
+		check string
+	}{
+		{name: "muted", mute: time.Minute, want: time.Minute, check: "chat:1"},
+		{name: "mute about to end", mute: time.Minute, wait: time.Minute - time.Nanosecond, want: time.Minute, check: "chat:1"},
+		{name: "mute ended", mute: time.Minute, wait: time.Minute, check: "chat:1"},
+		{name: "other key", mute: time.Minute, check: "chat:2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, clock := newTestStore()
+			start := clock.Now()
+			require.NoError(t, store.Mute(context.Background(), "chat:1", start.Add(tt.mute)))
+			clock.Advance(tt.wait)
+			until, err := store.MutedUntil(context.Background(), tt.check)
+			require.NoError(t, err)
+			if tt.want == 0 {
+				assert.True(t, until.IsZero(), "muted until %v", until)
+			} else {
+				assert.Equal(t, start.Add(tt.want), until)
+			}
+		})
+	}
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	store, clock := newTestStore()
+	limit := usecase.RateLimit{Burst: 1, Interval: time.Hour}
+	ctx := context.Background()
+
+	_, _, err := store.Take(ctx, "old", limit)
+	require.NoError(t, err)
+	clock.Advance(time.Minute)
+	cutoff := clock.Now()
+	clock.Advance(time.Minute)
+	_, _, err = store.Take(ctx, "recent", limit)
+	require.NoError(t, err)
+	require.NoError(t, store.Mute(ctx, "expired", clock.Now().Add(-time.Second)))
+	require.NoError(t, store.Mute(ctx, "active", clock.Now().Add(time.Minute)))
+
+	require.NoError(t, store.Prune(ctx, cutoff))
+
+	assert.NotContains(t, store.buckets, "old")
+	assert.Contains(t, store.buckets, "recent")
+	assert.NotContains(t, store.mutes, "expired")
+	assert.Contains(t, store.mutes, "active")
+
+	// A pruned bucket starts full again.
+	ok, _, err := store.Take(ctx, "old", limit)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
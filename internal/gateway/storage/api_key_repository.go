@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+
+	"github.com/google/uuid"
+)
+
+type apiKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) usecase.APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, name, prefix, key_hash, role, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row rowScanner) (*entity.APIKey, error) {
+	var k entity.APIKey
+	var lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Hash, &k.Role, &k.CreatedAt, &lastUsedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	k.LastUsedAt = lastUsedAt.Time
+	k.RevokedAt = revokedAt.Time
+	return &k, nil
+}
+
+func (r *apiKeyRepository) Save(ctx context.Context, key *entity.APIKey) error {
+	query := `
+		INSERT INTO api_keys (id, name, prefix, key_hash, role, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6);`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, key.ID, key.Name, key.Prefix, key.Hash, key.Role, key.CreatedAt)
+	if err != nil {
+		log.Printf("ERROR: Failed to save API key %s: %v", key.ID, err)
+		return fmt.Errorf("database error saving api key: %w", err)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) List(ctx context.Context) ([]entity.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY created_at DESC, id;`
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("ERROR: Failed to list API keys: %v", err)
+		return nil, fmt.Errorf("database error listing api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []entity.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("database error scanning api key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error iterating api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) FindActiveByHash(ctx context.Context, hash string) (*entity.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL;`
+
+	key, err := scanAPIKey(executor(ctx, r.db).QueryRowContext(ctx, query, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, usecase.ErrAPIKeyNotFound
+		}
+		log.Printf("ERROR: Failed to find API key: %v", err)
+		return nil, fmt.Errorf("database error finding api key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return usecase.ErrAPIKeyNotFound
+	}
+	query := `UPDATE api_keys SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL;`
+
+	result, err := executor(ctx, r.db).ExecContext(ctx, query, id, at)
+	if err != nil {
+		log.Printf("ERROR: Failed to revoke API key %s: %v", id, err)
+		return fmt.Errorf("database error revoking api key: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return usecase.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) MarkUsed(ctx context.Context, id string, at time.Time) error {
+	query := `
+		UPDATE api_keys SET last_used_at = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2 - INTERVAL '1 minute');`
+
+	if _, err := executor(ctx, r.db).ExecContext(ctx, query, id, at); err != nil {
+		return fmt.Errorf("database error recording api key use: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+
+	"github.com/google/uuid"
+)
+
+type attachmentRepository struct {
+	db *sql.DB
+}
+
+func NewAttachmentRepository(db *sql.DB) usecase.AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+const attachmentColumns = `id, chat_id, review_id, file_name, content_type, size_bytes, width, height, source, created_at`
+
+func scanAttachment(row rowScanner) (*entity.Attachment, error) {
+	var a entity.Attachment
+	var reviewID, source sql.NullString
+	err := row.Scan(&a.ID, &a.ChatID, &reviewID, &a.FileName, &a.ContentType, &a.Size, &a.Width, &a.Height, &source, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	a.ReviewID = reviewID.String
+	a.Source = source.String
+	return &a, nil
+}
+
+func (r *attachmentRepository) Save(ctx context.Context, attachment *entity.Attachment) error {
+	query := `
+		INSERT INTO attachments (id, chat_id, review_id, file_name, content_type, size_bytes, width, height, source, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`
+
+	reviewID := sql.NullString{String: attachment.ReviewID, Valid: attachment.ReviewID != ""}
+	source := sql.NullString{String: attachment.Source, Valid: attachment.Source != ""}
+	_, err := executor(ctx, r.db).ExecContext(ctx, query,
+		attachment.ID, attachment.ChatID, reviewID, attachment.FileName, attachment.ContentType,
+		attachment.Size, attachment.Width, attachment.Height, source, attachment.CreatedAt,
+	)
+	if err != nil {
+		log.Printf("ERROR: Failed to save attachment %s for chat %d: %v", attachment.ID, attachment.ChatID, err)
+		return fmt.Errorf("database error saving attachment: %w", err)
+	}
+	return nil
+}
+
+func (r *attachmentRepository) FindByID(ctx context.Context, id string) (*entity.Attachment, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, usecase.ErrAttachmentNotFound
+	}
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id = $1;`
+	return r.findOne(ctx, id, query, id)
+}
+
+func (r *attachmentRepository) FindBySource(ctx context.Context, chatID int64, source string) (*entity.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE chat_id = $1 AND source = $2;`
+	return r.findOne(ctx, source, query, chatID, source)
+}
+
+func (r *attachmentRepository) findOne(ctx context.Context, key, query string, args ...any) (*entity.Attachment, error) {
+	attachment, err := scanAttachment(executor(ctx, r.db).QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, usecase.ErrAttachmentNotFound
+		}
+		log.Printf("ERROR: Failed to find attachment '%s': %v", key, err)
+		return nil, fmt.Errorf("database error finding attachment: %w", err)
+	}
+	return attachment, nil
+}
+
+func (r *attachmentRepository) CountPending(ctx context.Context, chatID int64, ids []string) (int, error) {
+	query := `SELECT count(*) FROM attachments WHERE chat_id = $1 AND review_id IS NULL AND id = ANY($2::UUID[]);`
+
+	var count int
+	if err := executor(ctx, r.db).QueryRowContext(ctx, query, chatID, ids).Scan(&count); err != nil {
+		log.Printf("ERROR: Failed to count pending attachments of chat %d: %v", chatID, err)
+		return 0, fmt.Errorf("database error counting attachments: %w", err)
+	}
+	return count, nil
+}
+
+func (r *attachmentRepository) CountPendingByChat(ctx context.Context, chatID int64) (int, error) {
+	query := `SELECT count(*) FROM attachments WHERE chat_id = $1 AND review_id IS NULL;`
+
+	var count int
+	if err := executor(ctx, r.db).QueryRowContext(ctx, query, chatID).Scan(&count); err != nil {
+		log.Printf("ERROR: Failed to count pending attachments of chat %d: %v", chatID, err)
+		return 0, fmt.Errorf("database error counting attachments: %w", err)
+	}
+	return count, nil
+}
+
+func (r *attachmentRepository) DeletePendingBefore(ctx context.Context, before time.Time) ([]entity.Attachment, error) {
+	query := `
+		DELETE FROM attachments
+		WHERE review_id IS NULL AND created_at < $1
+		RETURNING ` + attachmentColumns + `;`
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query, before)
+	if err != nil {
+		log.Printf("ERROR: Failed to delete attachments pending since before %v: %v", before, err)
+		return nil, fmt.Errorf("database error deleting attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var attachments []entity.Attachment
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("database error scanning attachment: %w", err)
+		}
+		attachments = append(attachments, *attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error iterating attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+func (r *attachmentRepository) ClaimPending(ctx context.Context, chatID int64, since time.Time, ids []string, reviewID string) ([]entity.Attachment, error) {
+	query := `
+		UPDATE attachments SET review_id = $3
+		WHERE chat_id = $1 AND review_id IS NULL AND (created_at > $2 OR id = ANY($4::UUID[]))
+		RETURNING ` + attachmentColumns + `;`
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query, chatID, since, reviewID, ids)
+	if err != nil {
+		log.Printf("ERROR: Failed to claim attachments of chat %d for review %s: %v", chatID, reviewID, err)
+		return nil, fmt.Errorf("database error claiming attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var attachments []entity.Attachment
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("database error scanning attachment: %w", err)
+		}
+		attachments = append(attachments, *attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error iterating attachments: %w", err)
+	}
+
+	if len(attachments) > 0 {
+		log.Printf("GATEWAY (Postgres): Attached %d photo(s) of chat %d to review %s", len(attachments), chatID, reviewID)
+	}
+	return attachments, nil
+}
+
+func (r *attachmentRepository) FindByReviewIDs(ctx context.Context, reviewIDs []string) ([]entity.Attachment, error) {
+	query := `
+		SELECT ` + attachmentColumns + ` FROM attachments
+		WHERE review_id = ANY($1::UUID[])
+		ORDER BY created_at, id;`
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query, reviewIDs)
+	if err != nil {
+		log.Printf("ERROR: Failed to find attachments of %d reviews: %v", len(reviewIDs), err)
+		return nil, fmt.Errorf("database error finding attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var attachments []entity.Attachment
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("database error scanning attachment: %w", err)
+		}
+		attachments = append(attachments, *attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error iterating attachments: %w", err)
+	}
+	return attachments, nil
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+type campaignRepository struct {
+	db *sql.DB
+}
+
+func NewCampaignRepository(db *sql.DB) usecase.CampaignRepository {
+	return &campaignRepository{db: db}
+}
+
+func (r *campaignRepository) FindEnabled(ctx context.Context) ([]entity.Campaign, error) {
+	query := `
+		SELECT id, name, message, min_idle_hours, cooldown_hours, batch_size
+		FROM review_campaigns
+		WHERE enabled
+		ORDER BY id;`
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("ERROR: Failed to query campaigns: %v", err)
+		return nil, fmt.Errorf("database error getting campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	var campaigns []entity.Campaign
+	for rows.Next() {
+		var c entity.Campaign
+		var minIdleHours, cooldownHours int
+		if err := rows.Scan(&c.ID, &c.Name, &c.Message, &minIdleHours, &cooldownHours, &c.BatchSize); err != nil {
+			log.Printf("ERROR: Failed to scan campaign row: %v", err)
+			return nil, fmt.Errorf("database error scanning campaign: %w", err)
+		}
+		c.MinIdle = time.Duration(minIdleHours) * time.Hour
+		c.Cooldown = time.Duration(cooldownHours) * time.Hour
+		campaigns = append(campaigns, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error iterating campaigns: %w", err)
+	}
+	return campaigns, nil
+}
+
+func (r *campaignRepository) FindCandidates(ctx context.Context, campaign entity.Campaign, now time.Time) ([]*entity.Conversation, error) {
+	query := `
+		SELECT c.chat_id, c.user_id, c.state, c.last_interaction_at, c.reminder_sent_at, c.channel
+		FROM conversations c
+		WHERE c.state = $1
+			AND c.last_interaction_at < $2
+			AND NOT EXISTS (SELECT 1 FROM reviews r WHERE r.chat_id = c.chat_id)
+			AND NOT EXISTS (SELECT 1 FROM campaign_sends s WHERE s.chat_id = c.chat_id AND s.sent_at > $3)
+		ORDER BY c.last_interaction_at
+		LIMIT $4;`
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query, entity.StateIdle, now.Add(-campaign.MinIdle), now.Add(-campaign.Cooldown), campaign.BatchSize)
+	if err != nil {
+		log.Printf("ERROR: Failed to query candidates for campaign '%s': %v", campaign.Name, err)
+		return nil, fmt.Errorf("database error finding campaign candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var conversations []*entity.Conversation
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			log.Printf("ERROR: Failed to scan campaign candidate row: %v", err)
+			return nil, fmt.Errorf("database error scanning conversation: %w", err)
+		}
+		conversations = append(conversations, conversation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error iterating campaign candidates: %w", err)
+	}
+
+	log.Printf("GATEWAY (Postgres): Found %d candidates for campaign '%s'", len(conversations), campaign.Name)
+	return conversations, nil
+}
+
+func (r *campaignRepository) RecordSend(ctx context.Context, campaignID, chatID int64, sentAt time.Time) error {
+	query := `INSERT INTO campaign_sends (campaign_id, chat_id, sent_at) VALUES ($1, $2, $3);`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, campaignID, chatID, sentAt)
+	if err != nil {
+		log.Printf("ERROR: Failed to record send of campaign %d to chat %d: %v", campaignID, chatID, err)
+		return fmt.Errorf("database error recording campaign send: %w", err)
+	}
+	return nil
+}
+
+// Stats attributes a customer message or review to a send when it arrived
+// after that send and before the next campaign message to the same chat.
+func (r *campaignRepository) Stats(ctx context.Context) ([]entity.CampaignStats, error) {
+	query := `
+		WITH sends AS (
+			SELECT s.id, s.campaign_id, s.chat_id, s.sent_at,
+				LEAD(s.sent_at) OVER (PARTITION BY s.chat_id ORDER BY s.sent_at) AS next_sent_at
+			FROM campaign_sends s
+		)
+		SELECT c.id, c.name, c.enabled,
+			COUNT(s.id),
+			COUNT(s.id) FILTER (WHERE EXISTS (
+				SELECT 1 FROM message_history h
+				WHERE h.chat_id = s.chat_id AND h.is_user_message
+					AND h."timestamp" > s.sent_at
+					AND (s.next_sent_at IS NULL OR h."timestamp" < s.next_sent_at))),
+			COUNT(s.id) FILTER (WHERE EXISTS (
+				SELECT 1 FROM reviews rv
+				WHERE rv.chat_id = s.chat_id
+					AND rv.received_at > s.sent_at
+					AND (s.next_sent_at IS NULL OR rv.received_at < s.next_sent_at))),
+			MAX(s.sent_at)
+		FROM review_campaigns c
+		LEFT JOIN sends s ON s.campaign_id = c.id
+		GROUP BY c.id, c.name, c.enabled
+		ORDER BY c.id;`
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("ERROR: Failed to query campaign stats: %v", err)
+		return nil, fmt.Errorf("database error getting campaign stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []entity.CampaignStats{}
+	for rows.Next() {
+		var st entity.CampaignStats
+		var lastSentAt sql.NullTime
+		if err := rows.Scan(&st.CampaignID, &st.Name, &st.Enabled, &st.Sent, &st.Responded, &st.Reviewed, &lastSentAt); err != nil {
+			log.Printf("ERROR: Failed to scan campaign stats row: %v", err)
+			return nil, fmt.Errorf("database error scanning campaign stats: %w", err)
+		}
+		if lastSentAt.Valid {
+			st.LastSentAt = &lastSentAt.Time
+		}
+		stats = append(stats, st)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error iterating campaign stats: %w", err)
+	}
+	return stats, nil
+}
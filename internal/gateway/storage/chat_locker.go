@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/usecase"
+)
+
+const chatLockPollInterval = 50 * time.Millisecond
+
+// chatLocker uses session-level Postgres advisory locks keyed by chat ID, so
+// every app instance sharing the database sees the same lock. Each held lock
+// pins one pooled connection until it is released.
+type chatLocker struct {
+	db   *sql.DB
+	wait time.Duration
+}
+
+func NewChatLocker(db *sql.DB, wait time.Duration) usecase.ChatLocker {
+	return &chatLocker{db: db, wait: wait}
+}
+
+func (l *chatLocker) Lock(ctx context.Context, chatID int64) (func(), error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for chat lock: %w", err)
+	}
+
+	deadline := time.Now().Add(l.wait)
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1);`, chatID).Scan(&acquired); err != nil {
+			conn.Close()
+			log.Printf("ERROR: Failed to acquire advisory lock for chat %d: %v", chatID, err)
+			return nil, fmt.Errorf("database error acquiring chat lock: %w", err)
+		}
+		if acquired {
+			break
+		}
+		if time.Now().After(deadline) {
+			conn.Close()
+			log.Printf("GATEWAY (Postgres): Gave up waiting %s for lock on chat %d", l.wait, chatID)
+			return nil, usecase.ErrChatBusy
+		}
+
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return nil, ctx.Err()
+		case <-time.After(chatLockPollInterval):
+		}
+	}
+
+	unlock := func() {
+		// Unlock even if the caller's context was cancelled meanwhile.
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.ExecContext(unlockCtx, `SELECT pg_advisory_unlock($1);`, chatID); err != nil {
+			log.Printf("ERROR: Failed to release advisory lock for chat %d, discarding connection: %v", chatID, err)
+			// A session lock lives as long as its connection, so never hand it back to the pool.
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return unlock, nil
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"smb-chatbot/internal/entity"
 	"smb-chatbot/internal/usecase"
@@ -25,14 +27,20 @@ func (r *conversationRepository) Save(ctx context.Context, conversation *entity.
 	}
 
 	query := `
-		INSERT INTO conversations (chat_id, user_id, state, last_interaction_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO conversations (chat_id, user_id, state, last_interaction_at, reminder_sent_at, channel, last_inbound_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		ON CONFLICT (chat_id) DO UPDATE SET
 			user_id = EXCLUDED.user_id,
 			state = EXCLUDED.state,
-			last_interaction_at = EXCLUDED.last_interaction_at;`
+			last_interaction_at = EXCLUDED.last_interaction_at,
+			reminder_sent_at = EXCLUDED.reminder_sent_at,
+			channel = EXCLUDED.channel,
+			last_inbound_at = EXCLUDED.last_inbound_at;`
 
-	_, err := r.db.ExecContext(ctx, query, conversation.ChatID, conversation.UserID, conversation.State, conversation.LastInteractionAt)
+	reminderSentAt := sql.NullTime{Time: conversation.ReminderSentAt, Valid: !conversation.ReminderSentAt.IsZero()}
+	lastInboundAt := sql.NullTime{Time: conversation.LastInboundAt, Valid: !conversation.LastInboundAt.IsZero()}
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, conversation.ChatID, conversation.UserID, conversation.State, conversation.LastInteractionAt, reminderSentAt, conversation.Channel, lastInboundAt)
 	if err != nil {
 		log.Printf("ERROR: Failed to save conversation for chat %d: %v", conversation.ChatID, err)
 		return fmt.Errorf("database error saving conversation: %w", err)
@@ -43,12 +51,11 @@ func (r *conversationRepository) Save(ctx context.Context, conversation *entity.
 }
 
 func (r *conversationRepository) FindByChatID(ctx context.Context, chatID int64) (*entity.Conversation, error) {
-	query := `SELECT chat_id, user_id, state, last_interaction_at FROM conversations WHERE chat_id = $1;`
+	query := `SELECT chat_id, user_id, state, last_interaction_at, reminder_sent_at, channel, last_inbound_at FROM conversations WHERE chat_id = $1;`
 
-	row := r.db.QueryRowContext(ctx, query, chatID)
+	row := executor(ctx, r.db).QueryRowContext(ctx, query, chatID)
 
-	var conversation entity.Conversation
-	err := row.Scan(&conversation.ChatID, &conversation.UserID, &conversation.State, &conversation.LastInteractionAt)
+	conversation, err := scanConversation(row)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -67,5 +74,184 @@ func (r *conversationRepository) FindByChatID(ctx context.Context, chatID int64)
 	}
 
 	log.Printf("GATEWAY (Postgres): Found conversation state '%s' for chat %d", conversation.State, chatID)
+	return conversation, nil
+}
+
+func (r *conversationRepository) FindExisting(ctx context.Context, chatID int64) (*entity.Conversation, error) {
+	query := `SELECT chat_id, user_id, state, last_interaction_at, reminder_sent_at, channel, last_inbound_at FROM conversations WHERE chat_id = $1;`
+
+	conversation, err := scanConversation(executor(ctx, r.db).QueryRowContext(ctx, query, chatID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, usecase.ErrConversationNotFound
+		}
+		log.Printf("ERROR: Failed to find conversation for chat %d: %v", chatID, err)
+		return nil, fmt.Errorf("database error finding conversation: %w", err)
+	}
+	return conversation, nil
+}
+
+func (r *conversationRepository) List(ctx context.Context, filter entity.ConversationFilter, after *entity.ConversationCursor, limit int) ([]*entity.Conversation, error) {
+	var conditions []string
+	var args []any
+	add := func(condition string, values ...any) {
+		for _, value := range values {
+			args = append(args, value)
+			condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		conditions = append(conditions, condition)
+	}
+	if filter.State != "" {
+		add("state = ?", filter.State)
+	}
+	if !filter.InactiveSince.IsZero() {
+		add("last_interaction_at < ?", filter.InactiveSince)
+	}
+	if filter.UserID != 0 {
+		add("user_id = ?", filter.UserID)
+	}
+	if after != nil {
+		add("(last_interaction_at, chat_id) > (?, ?)", after.LastInteractionAt, after.ChatID)
+	}
+
+	query := `SELECT chat_id, user_id, state, last_interaction_at, reminder_sent_at, channel, last_inbound_at FROM conversations`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` ORDER BY last_interaction_at, chat_id LIMIT $%d;`, len(args))
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to list conversations: %v", err)
+		return nil, fmt.Errorf("database error listing conversations: %w", err)
+	}
+	defer rows.Close()
+
+	var conversations []*entity.Conversation
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("database error scanning conversation: %w", err)
+		}
+		conversations = append(conversations, conversation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error iterating conversations: %w", err)
+	}
+	return conversations, nil
+}
+
+func (r *conversationRepository) Delete(ctx context.Context, chatID int64) error {
+	// History, transitions and campaign sends are removed by ON DELETE CASCADE.
+	result, err := executor(ctx, r.db).ExecContext(ctx, `DELETE FROM conversations WHERE chat_id = $1;`, chatID)
+	if err != nil {
+		log.Printf("ERROR: Failed to delete conversation for chat %d: %v", chatID, err)
+		return fmt.Errorf("database error deleting conversation: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return usecase.ErrConversationNotFound
+	}
+
+	log.Printf("GATEWAY (Postgres): Deleted conversation for chat %d", chatID)
+	return nil
+}
+
+func (r *conversationRepository) FindStale(ctx context.Context, filter usecase.StaleConversationFilter) ([]*entity.Conversation, error) {
+	query := `
+		SELECT chat_id, user_id, state, last_interaction_at, reminder_sent_at, channel, last_inbound_at
+		FROM conversations
+		WHERE state = $1
+			AND last_interaction_at < $2
+			AND (NOT $3 OR reminder_sent_at IS NULL)
+		ORDER BY last_interaction_at
+		LIMIT $4;`
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query, filter.State, filter.InactiveSince, filter.OnlyUnreminded, filter.Limit)
+	if err != nil {
+		log.Printf("ERROR: Failed to query stale conversations in state '%s': %v", filter.State, err)
+		return nil, fmt.Errorf("database error finding stale conversations: %w", err)
+	}
+	defer rows.Close()
+
+	var conversations []*entity.Conversation
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			log.Printf("ERROR: Failed to scan stale conversation row: %v", err)
+			return nil, fmt.Errorf("database error scanning conversation: %w", err)
+		}
+		conversations = append(conversations, conversation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error iterating conversations: %w", err)
+	}
+
+	return conversations, nil
+}
+
+func (r *conversationRepository) RecordTransition(ctx context.Context, transition *entity.ConversationTransition) error {
+	if transition.CreatedAt.IsZero() {
+		transition.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO conversation_transitions (chat_id, from_state, to_state, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id;`
+
+	err := executor(ctx, r.db).QueryRowContext(ctx, query, transition.ChatID, transition.FromState, transition.ToState, transition.Reason, transition.CreatedAt).
+		Scan(&transition.ID)
+	if err != nil {
+		log.Printf("ERROR: Failed to record transition for chat %d: %v", transition.ChatID, err)
+		return fmt.Errorf("database error recording conversation transition: %w", err)
+	}
+
+	log.Printf("GATEWAY (Postgres): Recorded transition %s -> %s (%s) for chat %d", transition.FromState, transition.ToState, transition.Reason, transition.ChatID)
+	return nil
+}
+
+func (r *conversationRepository) FindTransitions(ctx context.Context, chatID int64, limit int) ([]entity.ConversationTransition, error) {
+	query := `
+		SELECT id, chat_id, from_state, to_state, reason, created_at
+		FROM conversation_transitions
+		WHERE chat_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2;`
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query, chatID, limit)
+	if err != nil {
+		log.Printf("ERROR: Failed to query transitions for chat %d: %v", chatID, err)
+		return nil, fmt.Errorf("database error finding conversation transitions: %w", err)
+	}
+	defer rows.Close()
+
+	var transitions []entity.ConversationTransition
+	for rows.Next() {
+		var t entity.ConversationTransition
+		if err := rows.Scan(&t.ID, &t.ChatID, &t.FromState, &t.ToState, &t.Reason, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("database error scanning conversation transition: %w", err)
+		}
+		transitions = append(transitions, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error iterating conversation transitions: %w", err)
+	}
+	return transitions, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanConversation(row rowScanner) (*entity.Conversation, error) {
+	var conversation entity.Conversation
+	var reminderSentAt, lastInboundAt sql.NullTime
+	err := row.Scan(&conversation.ChatID, &conversation.UserID, &conversation.State, &conversation.LastInteractionAt, &reminderSentAt, &conversation.Channel, &lastInboundAt)
+	if err != nil {
+		return nil, err
+	}
+	conversation.ReminderSentAt = reminderSentAt.Time
+	conversation.LastInboundAt = lastInboundAt.Time
 	return &conversation, nil
 }
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+type emailThreadRepository struct {
+	db *sql.DB
+}
+
+func NewEmailThreadRepository(db *sql.DB) usecase.EmailThreadRepository {
+	return &emailThreadRepository{db: db}
+}
+
+const emailThreadColumns = `chat_id, address, name, subject, last_message_id, message_references, updated_at`
+
+func scanEmailThread(row rowScanner) (*entity.EmailThread, error) {
+	var t entity.EmailThread
+	if err := row.Scan(&t.ChatID, &t.Address, &t.Name, &t.Subject, &t.LastMessageID, &t.References, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *emailThreadRepository) Create(ctx context.Context, thread *entity.EmailThread) error {
+	query := `
+		INSERT INTO email_threads (chat_id, address, name, subject, last_message_id, message_references, updated_at)
+		VALUES (nextval('email_chat_id_seq'), $1, $2, $3, $4, $5, NOW())
+		RETURNING chat_id, updated_at;`
+
+	err := executor(ctx, r.db).QueryRowContext(ctx, query, thread.Address, thread.Name, thread.Subject, thread.LastMessageID, thread.References).
+		Scan(&thread.ChatID, &thread.UpdatedAt)
+	if err != nil {
+		log.Printf("ERROR: Failed to create email thread for %s: %v", thread.Address, err)
+		return fmt.Errorf("database error creating email thread: %w", err)
+	}
+	return nil
+}
+
+func (r *emailThreadRepository) Update(ctx context.Context, thread *entity.EmailThread) error {
+	query := `
+		UPDATE email_threads SET
+			name = $2,
+			subject = $3,
+			last_message_id = $4,
+			message_references = $5,
+			updated_at = $6
+		WHERE chat_id = $1;`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, thread.ChatID, thread.Name, thread.Subject, thread.LastMessageID, thread.References, thread.UpdatedAt)
+	if err != nil {
+		log.Printf("ERROR: Failed to update email thread for chat %d: %v", thread.ChatID, err)
+		return fmt.Errorf("database error updating email thread: %w", err)
+	}
+	return nil
+}
+
+func (r *emailThreadRepository) FindByChatID(ctx context.Context, chatID int64) (*entity.EmailThread, error) {
+	query := `SELECT ` + emailThreadColumns + ` FROM email_threads WHERE chat_id = $1;`
+	return r.findOne(ctx, fmt.Sprintf("chat %d", chatID), query, chatID)
+}
+
+func (r *emailThreadRepository) FindByAddress(ctx context.Context, address string) (*entity.EmailThread, error) {
+	query := `SELECT ` + emailThreadColumns + ` FROM email_threads WHERE address = $1;`
+	return r.findOne(ctx, address, query, address)
+}
+
+func (r *emailThreadRepository) FindByMessageIDs(ctx context.Context, messageIDs []string) (*entity.EmailThread, error) {
+	query := `
+		SELECT ` + emailThreadColumns + ` FROM email_threads
+		WHERE chat_id = (
+			SELECT chat_id FROM email_message_ids
+			WHERE message_id = ANY($1)
+			ORDER BY created_at DESC
+			LIMIT 1
+		);`
+	return r.findOne(ctx, "referenced messages", query, messageIDs)
+}
+
+func (r *emailThreadRepository) findOne(ctx context.Context, what, query string, arg any) (*entity.EmailThread, error) {
+	thread, err := scanEmailThread(executor(ctx, r.db).QueryRowContext(ctx, query, arg))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, usecase.ErrEmailThreadNotFound
+		}
+		log.Printf("ERROR: Failed to find email thread for %s: %v", what, err)
+		return nil, fmt.Errorf("database error finding email thread: %w", err)
+	}
+	return thread, nil
+}
+
+func (r *emailThreadRepository) SaveMessageID(ctx context.Context, messageID string, chatID int64) error {
+	query := `
+		INSERT INTO email_message_ids (message_id, chat_id, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (message_id) DO NOTHING;`
+
+	if _, err := executor(ctx, r.db).ExecContext(ctx, query, messageID, chatID); err != nil {
+		log.Printf("ERROR: Failed to save email message ID for chat %d: %v", chatID, err)
+		return fmt.Errorf("database error saving email message ID: %w", err)
+	}
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"slices"
+
 	"smb-chatbot/internal/entity"
 	"smb-chatbot/internal/usecase"
 )
@@ -18,9 +20,10 @@ func NewHistoryRepository(db *sql.DB) usecase.HistoryRepository {
 }
 
 func (h *historyRepository) SaveHistoryEntry(ctx context.Context, chatID int64, entry entity.HistoryEntry) error {
-	query := `INSERT INTO message_history (chat_id, is_user_message, text, "timestamp") VALUES ($1, $2, $3, $4);`
+	query := `INSERT INTO message_history (chat_id, is_user_message, text, "timestamp", message_id) VALUES ($1, $2, $3, $4, $5);`
 
-	_, err := h.db.ExecContext(ctx, query, chatID, entry.IsUserMessage, entry.Text, entry.Timestamp)
+	messageID := sql.NullString{String: entry.MessageID, Valid: entry.MessageID != ""}
+	_, err := executor(ctx, h.db).ExecContext(ctx, query, chatID, entry.IsUserMessage, entry.Text, entry.Timestamp, messageID)
 	if err != nil {
 		log.Printf("ERROR: Failed to save history entry for chat %d: %v", chatID, err)
 		return fmt.Errorf("database error saving history: %w", err)
@@ -29,29 +32,72 @@ func (h *historyRepository) SaveHistoryEntry(ctx context.Context, chatID int64,
 	return nil
 }
 
+// Bot messages report the delivery status of their outbox message.
+const historySelect = `
+		SELECT h.id, h.is_user_message, h.text, h."timestamp", h.message_id, o.status
+		FROM message_history h
+		LEFT JOIN outbox_messages o ON o.message_id = h.message_id`
+
 func (h *historyRepository) GetHistory(ctx context.Context, chatID int64, limit int) ([]entity.HistoryEntry, error) {
-	query := `
-		SELECT is_user_message, text, "timestamp"
-		FROM message_history
-		WHERE chat_id = $1
-		ORDER BY "timestamp" DESC
+	query := historySelect + `
+		WHERE h.chat_id = $1
+		ORDER BY h."timestamp" DESC
 		LIMIT $2;`
 
-	rows, err := h.db.QueryContext(ctx, query, chatID, limit)
+	history, err := h.query(ctx, chatID, query, chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+	// The OpenAI API expects messages in chronological order.
+	slices.Reverse(history)
+
+	log.Printf("GATEWAY (Postgres History Repo): Found %d history entries for chat %d", len(history), chatID)
+	return history, nil
+}
+
+func (h *historyRepository) GetHistoryRange(ctx context.Context, chatID int64, r entity.HistoryRange) ([]entity.HistoryEntry, error) {
+	// IDs are compared instead of timestamps, which can tie.
+	fromAfter := r.AfterID > 0
+	order := "DESC"
+	if fromAfter {
+		order = "ASC"
+	}
+	query := historySelect + `
+		WHERE h.chat_id = $1
+			AND ($2::BIGINT = 0 OR h.id > $2)
+			AND ($3::BIGINT = 0 OR h.id < $3)
+		ORDER BY h.id ` + order + `
+		LIMIT $4;`
+
+	history, err := h.query(ctx, chatID, query, chatID, r.AfterID, r.BeforeID, r.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if !fromAfter {
+		slices.Reverse(history)
+	}
+	return history, nil
+}
+
+func (h *historyRepository) query(ctx context.Context, chatID int64, query string, args ...any) ([]entity.HistoryEntry, error) {
+	rows, err := executor(ctx, h.db).QueryContext(ctx, query, args...)
 	if err != nil {
 		log.Printf("ERROR: Failed to query history for chat %d: %v", chatID, err)
 		return nil, fmt.Errorf("database error getting history: %w", err)
 	}
 	defer rows.Close()
 
-	history := make([]entity.HistoryEntry, 0, limit)
+	history := []entity.HistoryEntry{}
 	for rows.Next() {
 		var entry entity.HistoryEntry
-		err := rows.Scan(&entry.IsUserMessage, &entry.Text, &entry.Timestamp)
+		var messageID, status sql.NullString
+		err := rows.Scan(&entry.ID, &entry.IsUserMessage, &entry.Text, &entry.Timestamp, &messageID, &status)
 		if err != nil {
 			log.Printf("ERROR: Failed to scan history row for chat %d: %v", chatID, err)
 			return nil, fmt.Errorf("database error scanning history: %w", err)
 		}
+		entry.MessageID = messageID.String
+		entry.Status = status.String
 		history = append(history, entry)
 	}
 
@@ -59,12 +105,5 @@ func (h *historyRepository) GetHistory(ctx context.Context, chatID int64, limit
 		log.Printf("ERROR: Error iterating history rows for chat %d: %v", chatID, err)
 		return nil, fmt.Errorf("database error iterating history: %w", err)
 	}
-
-	// The OpenAI API expects messages in chronological order.
-	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
-		history[i], history[j] = history[j], history[i]
-	}
-
-	log.Printf("GATEWAY (Postgres History Repo): Found %d history entries for chat %d", len(history), chatID)
 	return history, nil
 }
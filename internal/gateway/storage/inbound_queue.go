@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/usecase"
+)
+
+const (
+	inboundStatusPending    = "pending"
+	inboundStatusProcessing = "processing"
+	inboundStatusDone       = "done"
+	inboundStatusFailed     = "failed"
+)
+
+type inboundQueue struct {
+	db *sql.DB
+}
+
+func NewInboundQueue(db *sql.DB) usecase.InboundQueue {
+	return &inboundQueue{db: db}
+}
+
+func (q *inboundQueue) Enqueue(ctx context.Context, input usecase.HandleMessageInput, now time.Time) (int64, error) {
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode inbound message: %w", err)
+	}
+
+	query := `
+		INSERT INTO inbound_messages (chat_id, payload, status, next_attempt_at, enqueued_at)
+		VALUES ($1, $2, $3, $4, $4)
+		RETURNING id;`
+
+	var id int64
+	if err := executor(ctx, q.db).QueryRowContext(ctx, query, input.ChatID, payload, inboundStatusPending, now).Scan(&id); err != nil {
+		log.Printf("ERROR: Failed to enqueue inbound message for chat %d: %v", input.ChatID, err)
+		return 0, fmt.Errorf("database error enqueueing inbound message: %w", err)
+	}
+	return id, nil
+}
+
+func (q *inboundQueue) Claim(ctx context.Context, now time.Time) (*usecase.QueuedMessage, error) {
+	// Earlier unfinished messages of the same chat block a message, which
+	// serializes each chat while different chats are processed in parallel.
+	query := `
+		UPDATE inbound_messages SET
+			status = $1,
+			started_at = $3,
+			attempts = attempts + 1
+		WHERE id = (
+			SELECT m.id FROM inbound_messages m
+			WHERE m.status = $2
+				AND m.next_attempt_at <= $3
+				AND NOT EXISTS (
+					SELECT 1 FROM inbound_messages p
+					WHERE p.chat_id = m.chat_id AND p.id < m.id AND p.status IN ($1, $2)
+				)
+			ORDER BY m.id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, payload, attempts, enqueued_at, started_at;`
+
+	var m usecase.QueuedMessage
+	var payload []byte
+	err := executor(ctx, q.db).QueryRowContext(ctx, query, inboundStatusProcessing, inboundStatusPending, now).
+		Scan(&m.ID, &payload, &m.Attempts, &m.EnqueuedAt, &m.StartedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, usecase.ErrInboundQueueEmpty
+		}
+		log.Printf("ERROR: Failed to claim inbound message: %v", err)
+		return nil, fmt.Errorf("database error claiming inbound message: %w", err)
+	}
+	if err := json.Unmarshal(payload, &m.Input); err != nil {
+		return nil, fmt.Errorf("failed to decode inbound message %d: %w", m.ID, err)
+	}
+	return &m, nil
+}
+
+func (q *inboundQueue) Complete(ctx context.Context, id int64, finishedAt time.Time) error {
+	return q.finish(ctx, id, inboundStatusDone, finishedAt, "")
+}
+
+func (q *inboundQueue) Fail(ctx context.Context, id int64, finishedAt time.Time, lastErr string) error {
+	return q.finish(ctx, id, inboundStatusFailed, finishedAt, lastErr)
+}
+
+func (q *inboundQueue) finish(ctx context.Context, id int64, status string, finishedAt time.Time, lastErr string) error {
+	query := `UPDATE inbound_messages SET status = $2, finished_at = $3, last_error = $4 WHERE id = $1;`
+
+	_, err := executor(ctx, q.db).ExecContext(ctx, query, id, status, finishedAt, sql.NullString{String: lastErr, Valid: lastErr != ""})
+	if err != nil {
+		log.Printf("ERROR: Failed to mark inbound message %d as %s: %v", id, status, err)
+		return fmt.Errorf("database error finishing inbound message: %w", err)
+	}
+	return nil
+}
+
+func (q *inboundQueue) Retry(ctx context.Context, id int64, nextAttemptAt time.Time, lastErr string) error {
+	query := `UPDATE inbound_messages SET status = $2, next_attempt_at = $3, last_error = $4 WHERE id = $1;`
+
+	_, err := executor(ctx, q.db).ExecContext(ctx, query, id, inboundStatusPending, nextAttemptAt, lastErr)
+	if err != nil {
+		log.Printf("ERROR: Failed to reschedule inbound message %d: %v", id, err)
+		return fmt.Errorf("database error rescheduling inbound message: %w", err)
+	}
+	return nil
+}
+
+func (q *inboundQueue) RequeueStale(ctx context.Context, startedBefore time.Time) (int, error) {
+	query := `UPDATE inbound_messages SET status = $1 WHERE status = $2 AND started_at < $3;`
+
+	res, err := executor(ctx, q.db).ExecContext(ctx, query, inboundStatusPending, inboundStatusProcessing, startedBefore)
+	if err != nil {
+		log.Printf("ERROR: Failed to requeue stale inbound messages: %v", err)
+		return 0, fmt.Errorf("database error requeueing inbound messages: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("database error requeueing inbound messages: %w", err)
+	}
+	return int(n), nil
+}
+
+func (q *inboundQueue) Stats(ctx context.Context, now time.Time, window time.Duration) (usecase.QueueStats, error) {
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE status = $1),
+			COUNT(*) FILTER (WHERE status = $2),
+			COUNT(*) FILTER (WHERE status = $3),
+			COALESCE(EXTRACT(EPOCH FROM $4::timestamptz - MIN(enqueued_at) FILTER (WHERE status = $1)), 0)
+		FROM inbound_messages
+		WHERE status IN ($1, $2, $3);`
+
+	stats := usecase.QueueStats{StatsWindowSeconds: window.Seconds()}
+	err := executor(ctx, q.db).QueryRowContext(ctx, query, inboundStatusPending, inboundStatusProcessing, inboundStatusFailed, now).
+		Scan(&stats.Pending, &stats.Processing, &stats.Failed, &stats.OldestPendingAgeSeconds)
+	if err != nil {
+		log.Printf("ERROR: Failed to query inbound queue depth: %v", err)
+		return stats, fmt.Errorf("database error getting queue depth: %w", err)
+	}
+
+	latencyQuery := `
+		SELECT
+			COUNT(*),
+			COALESCE(AVG(EXTRACT(EPOCH FROM started_at - enqueued_at)), 0),
+			COALESCE(PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM started_at - enqueued_at)), 0),
+			COALESCE(AVG(EXTRACT(EPOCH FROM finished_at - started_at)), 0),
+			COALESCE(PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM finished_at - started_at)), 0)
+		FROM inbound_messages
+		WHERE status = $1 AND finished_at >= $2;`
+
+	err = executor(ctx, q.db).QueryRowContext(ctx, latencyQuery, inboundStatusDone, now.Add(-window)).Scan(
+		&stats.ProcessedInWindow, &stats.AvgWaitSeconds, &stats.P95WaitSeconds, &stats.AvgProcessingSeconds, &stats.P95ProcessingSeconds,
+	)
+	if err != nil {
+		log.Printf("ERROR: Failed to query inbound queue latency: %v", err)
+		return stats, fmt.Errorf("database error getting queue latency: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"smb-chatbot/internal/usecase"
+)
+
+type optOutRepository struct {
+	db *sql.DB
+}
+
+func NewOptOutRepository(db *sql.DB) usecase.OptOutRepository {
+	return &optOutRepository{db: db}
+}
+
+func (r *optOutRepository) OptOut(ctx context.Context, channel string, chatID int64) error {
+	query := `
+		INSERT INTO channel_opt_outs (channel, chat_id, opted_out_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (channel, chat_id) DO NOTHING;`
+
+	if _, err := executor(ctx, r.db).ExecContext(ctx, query, channel, chatID); err != nil {
+		log.Printf("ERROR: Failed to save %s opt-out for chat %d: %v", channel, chatID, err)
+		return fmt.Errorf("database error saving opt-out: %w", err)
+	}
+	return nil
+}
+
+func (r *optOutRepository) OptIn(ctx context.Context, channel string, chatID int64) error {
+	query := `DELETE FROM channel_opt_outs WHERE channel = $1 AND chat_id = $2;`
+
+	if _, err := executor(ctx, r.db).ExecContext(ctx, query, channel, chatID); err != nil {
+		log.Printf("ERROR: Failed to remove %s opt-out for chat %d: %v", channel, chatID, err)
+		return fmt.Errorf("database error removing opt-out: %w", err)
+	}
+	return nil
+}
+
+func (r *optOutRepository) IsOptedOut(ctx context.Context, channel string, chatID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM channel_opt_outs WHERE channel = $1 AND chat_id = $2);`
+
+	var optedOut bool
+	if err := executor(ctx, r.db).QueryRowContext(ctx, query, channel, chatID).Scan(&optedOut); err != nil {
+		log.Printf("ERROR: Failed to check %s opt-out for chat %d: %v", channel, chatID, err)
+		return false, fmt.Errorf("database error checking opt-out: %w", err)
+	}
+	return optedOut, nil
+}
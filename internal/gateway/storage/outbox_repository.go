@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+type outboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) usecase.OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Enqueue(ctx context.Context, message *entity.OutboxMessage) error {
+	query := `
+		INSERT INTO outbox_messages (message_id, chat_id, text, quick_replies, buttons, review_request, status, attempts, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id;`
+
+	quickReplies, err := encodeOptionalJSON(message.QuickReplies)
+	if err != nil {
+		return fmt.Errorf("failed to encode quick replies: %w", err)
+	}
+	buttons, err := encodeOptionalJSON(message.Buttons)
+	if err != nil {
+		return fmt.Errorf("failed to encode buttons: %w", err)
+	}
+
+	err = executor(ctx, r.db).QueryRowContext(ctx, query,
+		message.MessageID, message.ChatID, message.Text, quickReplies, buttons, message.ReviewRequest, message.Status, message.Attempts, message.NextAttemptAt, message.CreatedAt,
+	).Scan(&message.ID)
+	if err != nil {
+		log.Printf("ERROR: Failed to enqueue outbox message for chat %d: %v", message.ChatID, err)
+		return fmt.Errorf("database error enqueueing outbox message: %w", err)
+	}
+
+	log.Printf("GATEWAY (Postgres): Queued outbox message %d for chat %d", message.ID, message.ChatID)
+	return nil
+}
+
+const outboxMessageColumns = `id, message_id, chat_id, text, quick_replies, buttons, review_request, status, attempts, next_attempt_at, last_error, created_at, sent_at`
+
+func (r *outboxRepository) ClaimDue(ctx context.Context, now time.Time) (*entity.OutboxMessage, error) {
+	query := `
+		SELECT ` + outboxMessageColumns + `
+		FROM outbox_messages
+		WHERE status = $1 AND next_attempt_at <= $2
+		ORDER BY next_attempt_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED;`
+
+	m, err := scanOutboxMessage(executor(ctx, r.db).QueryRowContext(ctx, query, entity.OutboxStatusQueued, now))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, usecase.ErrOutboxEmpty
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to claim outbox message: %v", err)
+		return nil, fmt.Errorf("database error claiming outbox message: %w", err)
+	}
+	return m, nil
+}
+
+func (r *outboxRepository) FindByMessageID(ctx context.Context, chatID int64, messageID string) (*entity.OutboxMessage, error) {
+	query := `SELECT ` + outboxMessageColumns + ` FROM outbox_messages WHERE chat_id = $1 AND message_id = $2;`
+
+	m, err := scanOutboxMessage(executor(ctx, r.db).QueryRowContext(ctx, query, chatID, messageID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, usecase.ErrOutboxMessageNotFound
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to find outbox message '%s' of chat %d: %v", messageID, chatID, err)
+		return nil, fmt.Errorf("database error finding outbox message: %w", err)
+	}
+	return m, nil
+}
+
+func scanOutboxMessage(row *sql.Row) (*entity.OutboxMessage, error) {
+	var m entity.OutboxMessage
+	var messageID, lastError sql.NullString
+	var sentAt sql.NullTime
+	var quickReplies, buttons []byte
+	err := row.Scan(
+		&m.ID, &messageID, &m.ChatID, &m.Text, &quickReplies, &buttons, &m.ReviewRequest, &m.Status, &m.Attempts, &m.NextAttemptAt, &lastError, &m.CreatedAt, &sentAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.MessageID = messageID.String
+	m.LastError = lastError.String
+	m.SentAt = sentAt.Time
+	if err := decodeOptionalJSON(quickReplies, &m.QuickReplies); err != nil {
+		return nil, fmt.Errorf("failed to decode quick replies of outbox message %d: %w", m.ID, err)
+	}
+	if err := decodeOptionalJSON(buttons, &m.Buttons); err != nil {
+		return nil, fmt.Errorf("failed to decode buttons of outbox message %d: %w", m.ID, err)
+	}
+	return &m, nil
+}
+
+func (r *outboxRepository) Update(ctx context.Context, message *entity.OutboxMessage) error {
+	query := `
+		UPDATE outbox_messages SET
+			status = $2,
+			attempts = $3,
+			next_attempt_at = $4,
+			last_error = $5,
+			sent_at = $6,
+			external_id = $7
+		WHERE id = $1;`
+
+	lastError := sql.NullString{String: message.LastError, Valid: message.LastError != ""}
+	sentAt := sql.NullTime{Time: message.SentAt, Valid: !message.SentAt.IsZero()}
+	externalID := sql.NullString{String: message.ExternalID, Valid: message.ExternalID != ""}
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query,
+		message.ID, message.Status, message.Attempts, message.NextAttemptAt, lastError, sentAt, externalID,
+	)
+	if err != nil {
+		log.Printf("ERROR: Failed to update outbox message %d: %v", message.ID, err)
+		return fmt.Errorf("database error updating outbox message: %w", err)
+	}
+
+	log.Printf("GATEWAY (Postgres): Outbox message %d for chat %d is now '%s' (attempt %d)", message.ID, message.ChatID, message.Status, message.Attempts)
+	return nil
+}
+
+func (r *outboxRepository) UpdateDeliveryStatus(ctx context.Context, receipt entity.DeliveryReceipt) (bool, error) {
+	// Only statuses earlier in the lifecycle may be replaced.
+	earlier := entity.DeliveryStatusesBefore(receipt.Status)
+	if len(earlier) == 0 {
+		return false, fmt.Errorf("cannot move a message to delivery status '%s'", receipt.Status)
+	}
+
+	query := `
+		UPDATE outbox_messages SET
+			status = $2,
+			delivered_at = CASE WHEN $2 IN ('delivered', 'read') THEN COALESCE(delivered_at, $3) ELSE delivered_at END,
+			read_at = CASE WHEN $2 = 'read' THEN $3 ELSE read_at END,
+			last_error = CASE WHEN $2 = 'failed' THEN $4 ELSE last_error END
+		WHERE external_id = $1 AND status = ANY($5) AND ($6::BIGINT = 0 OR chat_id = $6);`
+
+	lastError := sql.NullString{String: receipt.Error, Valid: receipt.Error != ""}
+	result, err := executor(ctx, r.db).ExecContext(ctx, query,
+		receipt.ExternalID, receipt.Status, receipt.At, lastError, earlier, receipt.ChatID,
+	)
+	if err != nil {
+		log.Printf("ERROR: Failed to update delivery status of message '%s': %v", receipt.ExternalID, err)
+		return false, fmt.Errorf("database error updating delivery status: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("database error updating delivery status: %w", err)
+	}
+	if affected > 0 {
+		log.Printf("GATEWAY (Postgres): Message '%s' is now '%s'", receipt.ExternalID, receipt.Status)
+	}
+	return affected > 0, nil
+}
+
+// earlyReceiptLockClass keeps the external ID locks apart from other advisory
+// locks, which use a single bigint key.
+const earlyReceiptLockClass = 1
+
+func (r *outboxRepository) LockExternalID(ctx context.Context, externalID string) error {
+	_, err := executor(ctx, r.db).ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2));`, earlyReceiptLockClass, externalID)
+	if err != nil {
+		log.Printf("ERROR: Failed to lock external ID '%s': %v", externalID, err)
+		return fmt.Errorf("database error locking external id: %w", err)
+	}
+	return nil
+}
+
+func (r *outboxRepository) SaveEarlyReceipt(ctx context.Context, receipt entity.DeliveryReceipt) (bool, error) {
+	query := `
+		INSERT INTO early_delivery_receipts (external_id, status, chat_id, error, at)
+		SELECT $1, $2, $3, $4, $5
+		WHERE NOT EXISTS (SELECT 1 FROM outbox_messages WHERE external_id = $1)
+		ON CONFLICT (external_id, status) DO NOTHING;`
+
+	errorText := sql.NullString{String: receipt.Error, Valid: receipt.Error != ""}
+	result, err := executor(ctx, r.db).ExecContext(ctx, query, receipt.ExternalID, receipt.Status, receipt.ChatID, errorText, receipt.At)
+	if err != nil {
+		log.Printf("ERROR: Failed to save early receipt for message '%s': %v", receipt.ExternalID, err)
+		return false, fmt.Errorf("database error saving early receipt: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("database error saving early receipt: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *outboxRepository) TakeEarlyReceipts(ctx context.Context, externalID string) ([]entity.DeliveryReceipt, error) {
+	query := `
+		DELETE FROM early_delivery_receipts
+		WHERE external_id = $1
+		RETURNING external_id, status, chat_id, error, at;`
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query, externalID)
+	if err != nil {
+		log.Printf("ERROR: Failed to take early receipts for message '%s': %v", externalID, err)
+		return nil, fmt.Errorf("database error taking early receipts: %w", err)
+	}
+	defer rows.Close()
+
+	var receipts []entity.DeliveryReceipt
+	for rows.Next() {
+		var receipt entity.DeliveryReceipt
+		var errorText sql.NullString
+		if err := rows.Scan(&receipt.ExternalID, &receipt.Status, &receipt.ChatID, &errorText, &receipt.At); err != nil {
+			return nil, fmt.Errorf("database error scanning early receipt: %w", err)
+		}
+		receipt.Error = errorText.String
+		receipts = append(receipts, receipt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error iterating early receipts: %w", err)
+	}
+	// DELETE ... RETURNING has no ORDER BY.
+	sort.Slice(receipts, func(i, j int) bool { return receipts[i].At.Before(receipts[j].At) })
+	return receipts, nil
+}
+
+func (r *outboxRepository) PruneEarlyReceipts(ctx context.Context, before time.Time) error {
+	result, err := executor(ctx, r.db).ExecContext(ctx, `DELETE FROM early_delivery_receipts WHERE received_at < $1;`, before)
+	if err != nil {
+		log.Printf("ERROR: Failed to prune early receipts: %v", err)
+		return fmt.Errorf("database error pruning early receipts: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		log.Printf("GATEWAY (Postgres): Pruned %d early receipt(s) for unknown messages", n)
+	}
+	return nil
+}
+
+// encodeOptionalJSON stores empty slices as NULL.
+func encodeOptionalJSON[T any](values []T) (any, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(values)
+}
+
+func decodeOptionalJSON[T any](raw []byte, dest *[]T) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, dest)
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+type processedMessageRepository struct {
+	db *sql.DB
+}
+
+func NewProcessedMessageRepository(db *sql.DB) usecase.ProcessedMessageRepository {
+	return &processedMessageRepository{db: db}
+}
+
+func (r *processedMessageRepository) Find(ctx context.Context, chatID int64, messageID string) (*entity.ProcessedMessage, error) {
+	query := `SELECT chat_id, message_id, reply, processed_at FROM processed_messages WHERE chat_id = $1 AND message_id = $2;`
+
+	var m entity.ProcessedMessage
+	err := executor(ctx, r.db).QueryRowContext(ctx, query, chatID, messageID).Scan(&m.ChatID, &m.MessageID, &m.Reply, &m.ProcessedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, usecase.ErrProcessedMessageNotFound
+		}
+		log.Printf("ERROR: Failed to find processed message %s for chat %d: %v", messageID, chatID, err)
+		return nil, fmt.Errorf("database error finding processed message: %w", err)
+	}
+	return &m, nil
+}
+
+func (r *processedMessageRepository) Save(ctx context.Context, message *entity.ProcessedMessage) error {
+	query := `
+		INSERT INTO processed_messages (chat_id, message_id, reply, processed_at)
+		VALUES ($1, $2, $3, $4);`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, message.ChatID, message.MessageID, message.Reply, message.ProcessedAt)
+	if err != nil {
+		log.Printf("ERROR: Failed to save processed message %s for chat %d: %v", message.MessageID, message.ChatID, err)
+		return fmt.Errorf("database error saving processed message: %w", err)
+	}
+	return nil
+}
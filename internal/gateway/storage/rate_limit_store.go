@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/usecase"
+)
+
+// rateLimitStore keeps token buckets in Postgres, so all app instances share
+// the limits. Buckets are refilled on access using the database clock.
+type rateLimitStore struct {
+	db *sql.DB
+	// now replaces the database clock when set; tests use it to move time.
+	now func() time.Time
+}
+
+func NewRateLimitStore(db *sql.DB) usecase.RateLimitStore {
+	return &rateLimitStore{db: db}
+}
+
+// refilledTokens is the bucket's content after refilling it since its last
+// update, with $2 the burst, $3 the rate per second and $4 the clock.
+const refilledTokens = `LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM ` + storeNow + ` - b.updated_at)::float8 * $3::float8)`
+
+// storeNow is the time of the store's clock passed as $4, or the database
+// clock if it is NULL.
+const storeNow = `COALESCE($4::timestamptz, now())`
+
+// clock returns the $4 argument of the queries.
+func (s *rateLimitStore) clock() sql.NullTime {
+	if s.now == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: s.now(), Valid: true}
+}
+
+func (s *rateLimitStore) Take(ctx context.Context, key string, limit usecase.RateLimit) (bool, time.Duration, error) {
+	// The update is skipped for an empty bucket, which then returns no row.
+	query := `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at)
+		VALUES ($1, $2::float8 - 1, ` + storeNow + `)
+		ON CONFLICT (key) DO UPDATE
+		SET tokens = ` + refilledTokens + ` - 1, updated_at = ` + storeNow + `
+		WHERE ` + refilledTokens + ` >= 1
+		RETURNING tokens;`
+
+	burst, perSecond, now := float64(limit.Burst), limit.PerSecond(), s.clock()
+	var tokens float64
+	err := executor(ctx, s.db).QueryRowContext(ctx, query, key, burst, perSecond, now).Scan(&tokens)
+	if err == nil {
+		return true, 0, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("ERROR: Failed to take rate limit token of '%s': %v", key, err)
+		return false, 0, fmt.Errorf("database error taking rate limit token: %w", err)
+	}
+
+	query = `SELECT ` + refilledTokens + ` FROM rate_limit_buckets b WHERE b.key = $1;`
+	if err := executor(ctx, s.db).QueryRowContext(ctx, query, key, burst, perSecond, now).Scan(&tokens); err != nil {
+		log.Printf("ERROR: Failed to read rate limit bucket '%s': %v", key, err)
+		return false, 0, fmt.Errorf("database error reading rate limit bucket: %w", err)
+	}
+	return false, time.Duration(max(1-tokens, 0) / perSecond * float64(time.Second)), nil
+}
+
+func (s *rateLimitStore) Mute(ctx context.Context, key string, until time.Time) error {
+	query := `
+		INSERT INTO rate_limit_mutes AS m (key, muted_until)
+		VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET muted_until = GREATEST(m.muted_until, EXCLUDED.muted_until);`
+
+	if _, err := executor(ctx, s.db).ExecContext(ctx, query, key, until); err != nil {
+		log.Printf("ERROR: Failed to mute '%s': %v", key, err)
+		return fmt.Errorf("database error saving mute: %w", err)
+	}
+	return nil
+}
+
+func (s *rateLimitStore) MutedUntil(ctx context.Context, key string) (time.Time, error) {
+	query := `SELECT muted_until FROM rate_limit_mutes WHERE key = $1 AND muted_until > COALESCE($2::timestamptz, now());`
+
+	var until time.Time
+	err := executor(ctx, s.db).QueryRowContext(ctx, query, key, s.clock()).Scan(&until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to read mute of '%s': %v", key, err)
+		return time.Time{}, fmt.Errorf("database error reading mute: %w", err)
+	}
+	return until, nil
+}
+
+func (s *rateLimitStore) Prune(ctx context.Context, before time.Time) error {
+	if _, err := executor(ctx, s.db).ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < $1;`, before); err != nil {
+		log.Printf("ERROR: Failed to prune rate limit buckets: %v", err)
+		return fmt.Errorf("database error pruning rate limit buckets: %w", err)
+	}
+	if _, err := executor(ctx, s.db).ExecContext(ctx, `DELETE FROM rate_limit_mutes WHERE muted_until <= COALESCE($1::timestamptz, now());`, s.clock()); err != nil {
+		log.Printf("ERROR: Failed to prune mutes: %v", err)
+		return fmt.Errorf("database error pruning mutes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/usecase"
+)
+
+// manualClock only moves when the test advances it.
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time { return c.now }
+
+func (c *manualClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// testStore connects to the migrated database in TEST_DATABASE_URL and
+// skips the test without one. The store runs on the returned clock instead
+// of the database clock.
+func testStore(t *testing.T) (*rateLimitStore, *manualClock) {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := ConnectDB(dbURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	clock := &manualClock{now: time.Now().Truncate(time.Microsecond)}
+	return &rateLimitStore{db: db, now: clock.Now}, clock
+}
+
+// testKey is unique per run, so runs do not share buckets.
+func testKey(t *testing.T, name string) string {
+	return fmt.Sprintf("test:%s:%s:%d", t.Name(), name, time.Now().UnixNano())
+}
+
+func TestRateLimitStoreTake(t *testing.T) {
+	store, clock := testStore(t)
+	// Three tokens, one every 200ms.
+	limit := usecase.RateLimit{Burst: 3, Interval: 600 * time.Millisecond}
+	tests := []struct {
+		name string
+		// waits advance the clock before each take.
+		waits          []time.Duration
+		want           []bool
+		wantRetryAfter []time.Duration
+	}{
+		{name: "burst", waits: []time.Duration{0, 0, 0}, want: []bool{true, true, true}},
+		{name: "empty after burst", waits: []time.Duration{0, 0, 0, 0}, want: []bool{true, true, true, false},
+			wantRetryAfter: []time.Duration{0, 0, 0, 200 * time.Millisecond}},
+		{name: "partly refilled", waits: []time.Duration{0, 0, 0, 50 * time.Millisecond}, want: []bool{true, true, true, false},
+			wantRetryAfter: []time.Duration{0, 0, 0, 150 * time.Millisecond}},
+		{name: "refills one token", waits: []time.Duration{0, 0, 0, 0, 200 * time.Millisecond, 0}, want: []bool{true, true, true, false, true, false},
+			wantRetryAfter: []time.Duration{0, 0, 0, 200 * time.Millisecond, 0, 200 * time.Millisecond}},
+		{name: "refills no more than burst", waits: []time.Duration{0, time.Hour, 0, 0, 0}, want: []bool{true, true, true, true, false},
+			wantRetryAfter: []time.Duration{0, 0, 0, 0, 200 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := testKey(t, "ip")
+			for i, wait := range tt.waits {
+				clock.Advance(wait)
+				ok, retryAfter, err := store.Take(context.Background(), key, limit)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want[i], ok, "take %d", i)
+				var want time.Duration
+				if tt.wantRetryAfter != nil {
+					want = tt.wantRetryAfter[i]
+				}
+				assert.InDelta(t, want, retryAfter, float64(time.Millisecond), "retry after of take %d", i)
+			}
+		})
+	}
+}
+
+func TestRateLimitStoreMute(t *testing.T) {
+	store, clock := testStore(t)
+	ctx := context.Background()
+	key := testKey(t, "chat")
+
+	until, err := store.MutedUntil(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, until.IsZero())
+
+	mutedUntil := clock.Now().Add(time.Minute)
+	require.NoError(t, store.Mute(ctx, key, mutedUntil))
+	// A shorter mute does not end a longer one.
+	require.NoError(t, store.Mute(ctx, key, clock.Now().Add(time.Second)))
+	until, err = store.MutedUntil(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, mutedUntil.Equal(until), "muted until %v, want %v", until, mutedUntil)
+
+	clock.Advance(time.Minute)
+	until, err = store.MutedUntil(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, until.IsZero(), "mute outlasted its end")
+}
+
+func TestRateLimitStorePrune(t *testing.T) {
+	store, clock := testStore(t)
+	ctx := context.Background()
+	limit := usecase.RateLimit{Burst: 1, Interval: time.Hour}
+	key := testKey(t, "ip")
+
+	ok, _, err := store.Take(ctx, key, limit)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, _, err = store.Take(ctx, key, limit)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	clock.Advance(2 * time.Minute)
+	require.NoError(t, store.Prune(ctx, clock.Now().Add(-time.Minute)))
+
+	// A pruned bucket starts full again.
+	ok, _, err = store.Take(ctx, key, limit)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+type reviewDestinationRepository struct {
+	db *sql.DB
+}
+
+func NewReviewDestinationRepository(db *sql.DB) usecase.ReviewDestinationRepository {
+	return &reviewDestinationRepository{db: db}
+}
+
+func (r *reviewDestinationRepository) FindByBusinessID(ctx context.Context, businessID string) ([]entity.ReviewDestination, error) {
+	query := `
+		SELECT id, business_id, platform, url_template, priority
+		FROM review_destinations
+		WHERE business_id = $1 AND enabled
+		ORDER BY priority, id;`
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query, businessID)
+	if err != nil {
+		log.Printf("ERROR: Failed to query review destinations for business '%s': %v", businessID, err)
+		return nil, fmt.Errorf("database error getting review destinations: %w", err)
+	}
+	defer rows.Close()
+
+	var destinations []entity.ReviewDestination
+	for rows.Next() {
+		var d entity.ReviewDestination
+		if err := rows.Scan(&d.ID, &d.BusinessID, &d.Platform, &d.URLTemplate, &d.Priority); err != nil {
+			log.Printf("ERROR: Failed to scan review destination row for business '%s': %v", businessID, err)
+			return nil, fmt.Errorf("database error scanning review destinations: %w", err)
+		}
+		destinations = append(destinations, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error iterating review destinations: %w", err)
+	}
+
+	log.Printf("GATEWAY (Postgres): Found %d review destinations for business '%s'", len(destinations), businessID)
+	return destinations, nil
+}
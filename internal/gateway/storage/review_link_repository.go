@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+
+	"github.com/google/uuid"
+)
+
+type reviewLinkRepository struct {
+	db *sql.DB
+}
+
+func NewReviewLinkRepository(db *sql.DB) usecase.ReviewLinkRepository {
+	return &reviewLinkRepository{db: db}
+}
+
+func (r *reviewLinkRepository) Save(ctx context.Context, link *entity.ReviewLink) error {
+	query := `
+		INSERT INTO review_links (id, review_id, chat_id, platform, target_url, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6);`
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query, link.ID, link.ReviewID, link.ChatID, link.Platform, link.TargetURL, link.CreatedAt)
+	if err != nil {
+		log.Printf("ERROR: Failed to save review link %s for chat %d: %v", link.ID, link.ChatID, err)
+		return fmt.Errorf("database error saving review link: %w", err)
+	}
+
+	log.Printf("GATEWAY (Postgres): Saved review link %s for review %s", link.ID, link.ReviewID)
+	return nil
+}
+
+func (r *reviewLinkRepository) RegisterClick(ctx context.Context, linkID string) (*entity.ReviewLink, error) {
+	if _, err := uuid.Parse(linkID); err != nil {
+		return nil, usecase.ErrReviewLinkNotFound
+	}
+
+	query := `
+		UPDATE review_links SET
+			click_count = click_count + 1,
+			first_clicked_at = COALESCE(first_clicked_at, NOW()),
+			last_clicked_at = NOW()
+		WHERE id = $1
+		RETURNING id, review_id, chat_id, platform, target_url, created_at, click_count;`
+
+	var link entity.ReviewLink
+	err := executor(ctx, r.db).QueryRowContext(ctx, query, linkID).Scan(
+		&link.ID, &link.ReviewID, &link.ChatID, &link.Platform, &link.TargetURL, &link.CreatedAt, &link.ClickCount,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, usecase.ErrReviewLinkNotFound
+		}
+		log.Printf("ERROR: Failed to register click for review link %s: %v", linkID, err)
+		return nil, fmt.Errorf("database error registering review link click: %w", err)
+	}
+
+	return &link, nil
+}
@@ -3,8 +3,10 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 
 	"smb-chatbot/internal/entity"
 	"smb-chatbot/internal/usecase"
@@ -28,15 +30,23 @@ func (r *reviewRepository) Save(ctx context.Context, review *entity.Review) erro
 	}
 
 	query := `
-		INSERT INTO reviews (id, customer_id, chat_id, text, received_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO reviews (id, customer_id, customer_name, chat_id, channel, text, rating, received_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		ON CONFLICT (id) DO UPDATE SET
 			customer_id = EXCLUDED.customer_id,
+			customer_name = EXCLUDED.customer_name,
 			chat_id = EXCLUDED.chat_id,
+			channel = EXCLUDED.channel,
 			text = EXCLUDED.text,
+			rating = EXCLUDED.rating,
 			received_at = EXCLUDED.received_at;`
 
-	_, err := r.db.ExecContext(ctx, query, review.ID, review.CustomerID, review.ChatID, review.Text, review.ReceivedAt)
+	// A zero rating means the review could not be rated and is stored as NULL.
+	rating := sql.NullInt32{Int32: int32(review.Rating), Valid: review.Rating > 0}
+
+	_, err := executor(ctx, r.db).ExecContext(ctx, query,
+		review.ID, review.CustomerID, review.CustomerName, review.ChatID, review.Channel, review.Text, rating, review.ReceivedAt,
+	)
 	if err != nil {
 		log.Printf("ERROR: Failed to save review %s for customer %d: %v", review.ID, review.CustomerID, err)
 		return fmt.Errorf("database error saving review: %w", err)
@@ -45,3 +55,143 @@ func (r *reviewRepository) Save(ctx context.Context, review *entity.Review) erro
 	log.Printf("GATEWAY (Postgres): Saved review %s for customer %d", review.ID, review.CustomerID)
 	return nil
 }
+
+const reviewColumns = `id, customer_id, customer_name, chat_id, channel, text, rating, received_at`
+
+func scanReview(row rowScanner) (*entity.Review, error) {
+	var review entity.Review
+	var rating sql.NullInt32
+	if err := row.Scan(&review.ID, &review.CustomerID, &review.CustomerName, &review.ChatID, &review.Channel, &review.Text, &rating, &review.ReceivedAt); err != nil {
+		return nil, err
+	}
+	review.Rating = int(rating.Int32)
+	return &review, nil
+}
+
+func (r *reviewRepository) FindByID(ctx context.Context, id string) (*entity.Review, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, usecase.ErrReviewNotFound
+	}
+	query := `SELECT ` + reviewColumns + ` FROM reviews WHERE id = $1;`
+
+	review, err := scanReview(executor(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, usecase.ErrReviewNotFound
+		}
+		log.Printf("ERROR: Failed to find review %s: %v", id, err)
+		return nil, fmt.Errorf("database error finding review: %w", err)
+	}
+	return review, nil
+}
+
+// reviewSortKeys are the columns each order sorts by. Unrated reviews sort
+// as rating 0, below all rated ones.
+var reviewSortKeys = map[string]string{
+	entity.ReviewSortNewest:     "received_at",
+	entity.ReviewSortOldest:     "received_at",
+	entity.ReviewSortRatingDesc: "COALESCE(rating, 0)",
+	entity.ReviewSortRatingAsc:  "COALESCE(rating, 0)",
+}
+
+func (r *reviewRepository) List(ctx context.Context, filter entity.ReviewFilter, sort string, after *entity.ReviewCursor, limit int) ([]entity.Review, error) {
+	var reviews []entity.Review
+	err := r.scan(ctx, filter, sort, after, limit, func(review entity.Review) error {
+		reviews = append(reviews, review)
+		return nil
+	})
+	return reviews, err
+}
+
+// Each reads the rows one by one, so exports of any size use constant memory.
+func (r *reviewRepository) Each(ctx context.Context, filter entity.ReviewFilter, sort string, fn func(entity.Review) error) error {
+	return r.scan(ctx, filter, sort, nil, 0, fn)
+}
+
+// scan runs the review query and calls fn for every row. A limit of 0 reads
+// all matching reviews.
+func (r *reviewRepository) scan(ctx context.Context, filter entity.ReviewFilter, sort string, after *entity.ReviewCursor, limit int, fn func(entity.Review) error) error {
+	key, ok := reviewSortKeys[sort]
+	if !ok {
+		return fmt.Errorf("unknown review sort '%s'", sort)
+	}
+	descending := strings.HasPrefix(sort, "-")
+
+	var conditions []string
+	var args []any
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", len(args))))
+	}
+	if !filter.From.IsZero() {
+		add("received_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("received_at < ?", filter.To)
+	}
+	if filter.ChatID != 0 {
+		add("chat_id = ?", filter.ChatID)
+	}
+	if filter.CustomerID != 0 {
+		add("customer_id = ?", filter.CustomerID)
+	}
+	if filter.MinRating > 0 {
+		add("rating >= ?", filter.MinRating)
+	}
+	if filter.MaxRating > 0 {
+		add("rating <= ?", filter.MaxRating)
+	}
+	if filter.Search != "" {
+		add(`text ILIKE '%' || ? || '%'`, likeEscaper.Replace(filter.Search))
+	}
+	if after != nil {
+		var position any = after.ReceivedAt
+		if key != "received_at" {
+			position = after.Rating
+		}
+		args = append(args, position, after.ID)
+		comparison := ">"
+		if descending {
+			comparison = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s ($%d, $%d::UUID)", key, comparison, len(args)-1, len(args)))
+	}
+
+	direction := "ASC"
+	if descending {
+		direction = "DESC"
+	}
+	query := `SELECT ` + reviewColumns + ` FROM reviews`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(` ORDER BY %s %s, id %s`, key, direction, direction)
+	if limit > 0 {
+		args = append(args, limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	rows, err := executor(ctx, r.db).QueryContext(ctx, query+";", args...)
+	if err != nil {
+		log.Printf("ERROR: Failed to list reviews: %v", err)
+		return fmt.Errorf("database error listing reviews: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return fmt.Errorf("database error scanning review: %w", err)
+		}
+		if err := fn(*review); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("database error iterating reviews: %w", err)
+	}
+	return nil
+}
+
+// likeEscaper makes user input match literally in LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/stdlib"
+
+	"smb-chatbot/internal/usecase"
+)
+
+const (
+	webChatNotifyChannel = "web_chat"
+	webChatRelayRetry    = time.Second
+)
+
+// webChatRelay uses Postgres LISTEN/NOTIFY. The listener pins one pooled
+// connection for as long as it runs.
+type webChatRelay struct {
+	db *sql.DB
+}
+
+func NewWebChatRelay(db *sql.DB) usecase.WebChatRelay {
+	return &webChatRelay{db: db}
+}
+
+// Publish only sends the IDs, as notifications are limited to 8000 bytes.
+func (r *webChatRelay) Publish(ctx context.Context, chatID int64, messageID string) error {
+	payload := strconv.FormatInt(chatID, 10) + ":" + messageID
+	if _, err := executor(ctx, r.db).ExecContext(ctx, `SELECT pg_notify($1, $2);`, webChatNotifyChannel, payload); err != nil {
+		log.Printf("ERROR: Failed to publish web chat message '%s' of chat %d: %v", messageID, chatID, err)
+		return fmt.Errorf("database error publishing web chat message: %w", err)
+	}
+	return nil
+}
+
+func (r *webChatRelay) Listen(ctx context.Context, ready func(), deliver func(chatID int64, messageID string)) {
+	for {
+		err := r.listen(ctx, ready, deliver)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("ERROR: Web chat relay stopped listening, retrying in %s: %v", webChatRelayRetry, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(webChatRelayRetry):
+		}
+	}
+}
+
+func (r *webChatRelay) listen(ctx context.Context, ready func(), deliver func(chatID int64, messageID string)) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection for web chat relay: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pgConn := driverConn.(*stdlib.Conn).Conn()
+		if _, err := pgConn.Exec(ctx, "LISTEN "+webChatNotifyChannel); err != nil {
+			return fmt.Errorf("failed to listen for web chat messages: %w", err)
+		}
+		// A connection returned to the pool must not keep receiving.
+		defer pgConn.Exec(context.Background(), "UNLISTEN "+webChatNotifyChannel)
+		log.Printf("GATEWAY (Postgres): Listening for web chat messages")
+		ready()
+
+		for {
+			notification, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			chatIDText, messageID, ok := strings.Cut(notification.Payload, ":")
+			chatID, err := strconv.ParseInt(chatIDText, 10, 64)
+			if !ok || err != nil || messageID == "" {
+				log.Printf("WARN: Ignoring malformed web chat notification '%s'", notification.Payload)
+				continue
+			}
+			deliver(chatID, messageID)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/usecase"
+)
+
+type webSessionRepository struct {
+	db *sql.DB
+}
+
+func NewWebSessionRepository(db *sql.DB) usecase.WebSessionRepository {
+	return &webSessionRepository{db: db}
+}
+
+func (r *webSessionRepository) Create(ctx context.Context, session *entity.WebSession) error {
+	query := `
+		INSERT INTO web_sessions (chat_id, created_at)
+		VALUES (nextval('web_chat_id_seq'), $1)
+		RETURNING chat_id;`
+
+	err := executor(ctx, r.db).QueryRowContext(ctx, query, session.CreatedAt).Scan(&session.ChatID)
+	if err != nil {
+		log.Printf("ERROR: Failed to create web session: %v", err)
+		return fmt.Errorf("database error creating web session: %w", err)
+	}
+	return nil
+}
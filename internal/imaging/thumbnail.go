@@ -0,0 +1,54 @@
+// Package imaging scales images with the standard library only.
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// Thumbnail scales src down to fit into a maxSide x maxSide box, keeping the
+// aspect ratio. Each target pixel averages the source pixels it covers, which
+// avoids the aliasing of nearest-neighbour sampling. Smaller images keep
+// their size. Transparent areas are flattened onto white, so the result can
+// be encoded as JPEG.
+func Thumbnail(src image.Image, maxSide int) *image.RGBA {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w, h
+	if w > maxSide || h > maxSide {
+		if w >= h {
+			tw, th = maxSide, max(1, h*maxSide/w)
+		} else {
+			tw, th = max(1, w*maxSide/h), maxSide
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0 := bounds.Min.Y + y*h/th
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*h/th)
+		for x := 0; x < tw; x++ {
+			x0 := bounds.Min.X + x*w/tw
+			x1 := max(x0+1, bounds.Min.X+(x+1)*w/tw)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			// Colors are alpha-premultiplied, so adding the missing coverage
+			// in white composites the pixel over a white background.
+			white := 0xffff*n - a
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8((r + white) / n >> 8),
+				G: uint8((g + white) / n >> 8),
+				B: uint8((b + white) / n >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
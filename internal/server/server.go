@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
+	"smb-chatbot/internal/auth"
 	httpController "smb-chatbot/internal/controller/http"
 	gwMessenger "smb-chatbot/internal/gateway/messenger"
 	"smb-chatbot/internal/usecase"
@@ -13,27 +15,124 @@ import (
 )
 
 type Server struct {
-	reviewUseCase   usecase.ReviewUseCase
-	historyRepo     usecase.HistoryRepository
-	messengerClient *gwMessenger.MockMessengerClient
+	inbound        usecase.InboundService
+	historyRepo    usecase.HistoryRepository
+	reviewPromoter usecase.ReviewPromoter
+	campaigns      usecase.CampaignScheduler
+	deliveries     usecase.DeliveryTracker
+	conversations  usecase.ConversationAdmin
+	apiKeys        usecase.APIKeyService
+	authSigner     *auth.TokenSigner
+	authenticator  *httpController.Authenticator
+	rateLimiter    usecase.RateLimiter
+	// forwardedFor takes client addresses from X-Forwarded-For.
+	forwardedFor bool
 
 	Router *http.ServeMux
 }
 
-func NewServer(uc usecase.ReviewUseCase, hr usecase.HistoryRepository, mc *gwMessenger.MockMessengerClient) *Server {
+// NewServer creates the server with the core API. Callers authenticate with
+// API keys from ks or dashboard tokens from signer; if ks is nil the API is
+// public. Messages from the API and the web chat are limited by rl, if set.
+func NewServer(is usecase.InboundService, hr usecase.HistoryRepository, rp usecase.ReviewPromoter, cs usecase.CampaignScheduler, dt usecase.DeliveryTracker, ca usecase.ConversationAdmin, ks usecase.APIKeyService, signer *auth.TokenSigner, rl usecase.RateLimiter) *Server {
 	s := &Server{
-		reviewUseCase:   uc,
-		historyRepo:     hr,
-		messengerClient: mc,
-		Router:          http.NewServeMux(),
+		inbound:        is,
+		historyRepo:    hr,
+		reviewPromoter: rp,
+		campaigns:      cs,
+		deliveries:     dt,
+		conversations:  ca,
+		apiKeys:        ks,
+		authSigner:     signer,
+		authenticator:  httpController.NewAuthenticator(ks, signer),
+		rateLimiter:    rl,
+		Router:         http.NewServeMux(),
 	}
 	s.registerRoutes()
 	return s
 }
 
 func (s *Server) registerRoutes() {
-	reviewHandler := httpController.NewReviewController(s.reviewUseCase, s.historyRepo, s.messengerClient)
-	httpController.RegisterRoutes(s.Router, reviewHandler)
+	reviewHandler := httpController.NewReviewController(s.inbound, s.historyRepo, s.rateLimiter)
+	reviewLinkHandler := httpController.NewReviewLinkController(s.reviewPromoter)
+	campaignHandler := httpController.NewCampaignController(s.campaigns)
+	queueHandler := httpController.NewQueueController(s.inbound)
+	httpController.RegisterRoutes(s.Router, s.authenticator, reviewHandler, reviewLinkHandler, campaignHandler, queueHandler)
+
+	conversationHandler := httpController.NewConversationController(s.conversations)
+	httpController.RegisterConversationRoutes(s.Router, s.authenticator, conversationHandler)
+
+	if s.apiKeys != nil {
+		authHandler := httpController.NewAuthController(s.apiKeys, s.authSigner)
+		httpController.RegisterAuthRoutes(s.Router, s.authenticator, authHandler)
+	}
+}
+
+// EnableReviewAPI registers the endpoints listing collected reviews. Photos
+// of reviews get signed URLs valid for attachmentURLTTL if signer is set.
+func (s *Server) EnableReviewAPI(rr usecase.ReviewReader, signer *auth.TokenSigner, attachmentURLTTL time.Duration) {
+	reviewListHandler := httpController.NewReviewListController(rr, signer, attachmentURLTTL)
+	httpController.RegisterReviewListRoutes(s.Router, s.authenticator, reviewListHandler)
+}
+
+// EnableTelegramWebhook registers the Telegram webhook endpoint. Requests
+// must carry secretToken in the X-Telegram-Bot-Api-Secret-Token header.
+func (s *Server) EnableTelegramWebhook(secretToken string) {
+	telegramHandler := httpController.NewTelegramController(s.inbound, secretToken)
+	httpController.RegisterTelegramRoutes(s.Router, telegramHandler)
+}
+
+// EnableWhatsAppWebhook registers the WhatsApp Cloud API webhook, which
+// receives messages and delivery statuses. verifyToken answers Meta's
+// subscription handshake and appSecret validates the X-Hub-Signature-256
+// header of deliveries.
+func (s *Server) EnableWhatsAppWebhook(verifyToken, appSecret string) {
+	whatsAppHandler := httpController.NewWhatsAppController(s.inbound, s.deliveries, verifyToken, appSecret)
+	httpController.RegisterWhatsAppRoutes(s.Router, whatsAppHandler)
+}
+
+// EnableSMSWebhook registers the Twilio SMS webhook and status callback.
+// authToken validates the X-Twilio-Signature header, computed over
+// webhookURL and statusCallbackURL when they are set.
+func (s *Server) EnableSMSWebhook(ss usecase.SubscriptionService, authToken, webhookURL, statusCallbackURL string) {
+	smsHandler := httpController.NewSMSController(s.inbound, ss, s.deliveries, authToken, webhookURL, statusCallbackURL)
+	httpController.RegisterSMSRoutes(s.Router, smsHandler)
+}
+
+// EnableEmailWebhook registers the inbound email webhook. Requests must carry
+// secret as a bearer token. Image parts are stored through as, if set.
+func (s *Server) EnableEmailWebhook(et usecase.EmailThreader, as usecase.AttachmentService, secret string) {
+	emailHandler := httpController.NewEmailController(s.inbound, et, as, secret)
+	httpController.RegisterEmailRoutes(s.Router, emailHandler)
+}
+
+// EnableWebSocket registers the web chat endpoints: token issuance and the
+// WebSocket connection through which hub pushes messages.
+func (s *Server) EnableWebSocket(hub *gwMessenger.WebSocketHub, signer *auth.TokenSigner, tokenTTL time.Duration) {
+	webSocketHandler := httpController.NewWebSocketController(s.inbound, s.deliveries, hub, signer, tokenTTL, s.rateLimiter)
+	httpController.RegisterWebSocketRoutes(s.Router, s.authenticator, webSocketHandler)
+}
+
+// EnableWebSessions registers the endpoint giving anonymous web chat
+// visitors a chat of their own, with a token from signer valid for tokenTTL.
+func (s *Server) EnableWebSessions(ws usecase.WebSessionService, signer *auth.TokenSigner, tokenTTL time.Duration) {
+	sessionHandler := httpController.NewSessionController(ws, signer, tokenTTL, s.rateLimiter)
+	httpController.RegisterSessionRoutes(s.Router, sessionHandler)
+}
+
+// EnableAttachments registers photo upload and download. Uploads need a web
+// chat token from signer; downloads also accept the signed URLs returned on
+// upload, valid for urlTTL.
+func (s *Server) EnableAttachments(as usecase.AttachmentService, signer *auth.TokenSigner, urlTTL time.Duration, maxBytes int64) {
+	attachmentHandler := httpController.NewAttachmentController(as, signer, urlTTL, maxBytes, s.rateLimiter)
+	httpController.RegisterAttachmentRoutes(s.Router, attachmentHandler)
+}
+
+// EnableForwardedFor takes the client address of requests, which rate
+// limits apply to, from the X-Forwarded-For header. Enable it only behind a
+// reverse proxy that sets the header.
+func (s *Server) EnableForwardedFor() {
+	s.forwardedFor = true
 }
 
 func (s *Server) Start(port string) error {
@@ -42,12 +141,15 @@ func (s *Server) Start(port string) error {
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:5173"},
 		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodOptions},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-API-Key"},
 		AllowCredentials: true,
 		Debug:            true,
 	})
 
 	handler := c.Handler(s.Router)
+	if s.forwardedFor {
+		handler = httpController.ForwardedFor(handler)
+	}
 
 	httpServer := &http.Server{
 		Addr:    ":" + port,
@@ -0,0 +1,24 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"smb-chatbot/internal/entity"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+type APIKeyRepository interface {
+	Save(ctx context.Context, key *entity.APIKey) error
+	// List returns all keys, revoked ones included, newest first.
+	List(ctx context.Context) ([]entity.APIKey, error)
+	// FindActiveByHash returns ErrAPIKeyNotFound for unknown and revoked keys.
+	FindActiveByHash(ctx context.Context, hash string) (*entity.APIKey, error)
+	// Revoke returns ErrAPIKeyNotFound if no active key has id.
+	Revoke(ctx context.Context, id string, at time.Time) error
+	// MarkUsed records a use at at, unless the last recorded use is less
+	// than a minute older.
+	MarkUsed(ctx context.Context, id string, at time.Time) error
+}
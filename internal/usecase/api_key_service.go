@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/auth"
+	"smb-chatbot/internal/entity"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// maxAPIKeyName is the most characters of a key's name.
+const maxAPIKeyName = 100
+
+// APIKeyService manages the API keys of integrations.
+type APIKeyService interface {
+	// Create returns the new key and its secret, which is not stored and
+	// cannot be shown again.
+	Create(ctx context.Context, name, role string) (*entity.APIKey, string, error)
+	List(ctx context.Context) ([]entity.APIKey, error)
+	Revoke(ctx context.Context, id string) error
+	// Authenticate returns the active key with secret, or ErrAPIKeyNotFound.
+	Authenticate(ctx context.Context, secret string) (*entity.APIKey, error)
+}
+
+type apiKeyService struct {
+	keyRepo APIKeyRepository
+}
+
+func NewAPIKeyService(akr APIKeyRepository) APIKeyService {
+	return &apiKeyService{keyRepo: akr}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, name, role string) (*entity.APIKey, string, error) {
+	switch {
+	case name == "" || len([]rune(name)) > maxAPIKeyName:
+		return nil, "", fmt.Errorf("%w: name must have 1 to %d characters", ErrInvalidAPIKey, maxAPIKeyName)
+	case !entity.IsValidRole(role):
+		return nil, "", fmt.Errorf("%w: role must be '%s', '%s' or '%s'", ErrInvalidAPIKey, entity.RoleOwner, entity.RoleAgent, entity.RoleIntegration)
+	}
+
+	secret, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key := &entity.APIKey{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Prefix:    prefix,
+		Role:      role,
+		Hash:      auth.HashAPIKey(secret),
+		CreatedAt: time.Now(),
+	}
+	if err := s.keyRepo.Save(ctx, key); err != nil {
+		return nil, "", err
+	}
+	log.Printf("Created %s API key %s (%s)", role, key.ID, name)
+	return key, secret, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context) ([]entity.APIKey, error) {
+	keys, err := s.keyRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []entity.APIKey{}
+	}
+	return keys, nil
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id string) error {
+	if err := s.keyRepo.Revoke(ctx, id, time.Now()); err != nil {
+		return err
+	}
+	log.Printf("Revoked API key %s", id)
+	return nil
+}
+
+func (s *apiKeyService) Authenticate(ctx context.Context, secret string) (*entity.APIKey, error) {
+	if !auth.IsAPIKey(secret) {
+		return nil, ErrAPIKeyNotFound
+	}
+	key, err := s.keyRepo.FindActiveByHash(ctx, auth.HashAPIKey(secret))
+	if err != nil {
+		return nil, err
+	}
+	// Failing to record the use must not fail the request.
+	if err := s.keyRepo.MarkUsed(ctx, key.ID, time.Now()); err != nil {
+		log.Printf("ERROR: Failed to record use of API key %s: %v", key.ID, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,33 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"smb-chatbot/internal/entity"
+)
+
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+type AttachmentRepository interface {
+	Save(ctx context.Context, attachment *entity.Attachment) error
+	FindByID(ctx context.Context, id string) (*entity.Attachment, error)
+	// FindBySource returns ErrAttachmentNotFound if no media with source was
+	// imported for the chat yet.
+	FindBySource(ctx context.Context, chatID int64, source string) (*entity.Attachment, error)
+	// CountPending counts the attachments among ids that belong to the chat
+	// and to no review yet. ids must be UUIDs.
+	CountPending(ctx context.Context, chatID int64, ids []string) (int, error)
+	// CountPendingByChat counts all attachments of the chat that belong to no
+	// review yet.
+	CountPendingByChat(ctx context.Context, chatID int64) (int, error)
+	// DeletePendingBefore deletes the attachments that belong to no review
+	// and were created before before, and returns them.
+	DeletePendingBefore(ctx context.Context, before time.Time) ([]entity.Attachment, error)
+	// ClaimPending assigns the chat's attachments that belong to no review yet
+	// and were created after since or are among ids to reviewID and returns
+	// them.
+	ClaimPending(ctx context.Context, chatID int64, since time.Time, ids []string, reviewID string) ([]entity.Attachment, error)
+	FindByReviewIDs(ctx context.Context, reviewIDs []string) ([]entity.Attachment, error)
+}
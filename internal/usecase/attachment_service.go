@@ -0,0 +1,268 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/imaging"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAttachmentTooLarge      = errors.New("attachment exceeds the size limit")
+	ErrUnsupportedAttachment   = errors.New("attachment is not a JPEG, PNG or GIF image")
+	ErrMediaFetcherUnavailable = errors.New("channel cannot download media")
+	// ErrInvalidAttachment rejects attachment IDs that name no photo of the
+	// chat, or one already attached to a review.
+	ErrInvalidAttachment = errors.New("unknown attachment")
+	// ErrTooManyPendingAttachments rejects uploads to a chat holding
+	// AttachmentConfig.MaxPending photos that belong to no review yet.
+	ErrTooManyPendingAttachments = errors.New("too many attachments waiting for a review")
+)
+
+// attachmentContentTypes maps the formats registered with the image package
+// to the content type the attachment is served with.
+var attachmentContentTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+}
+
+// MediaFetcher is implemented by channel clients that can download the files
+// customers send, identified by entity.InboundMedia.Ref.
+type MediaFetcher interface {
+	FetchMedia(ctx context.Context, ref string) (io.ReadCloser, error)
+}
+
+type AttachmentConfig struct {
+	MaxBytes int64
+	// MaxPixels bounds width*height, as decoding allocates 4 bytes per pixel.
+	MaxPixels     int
+	ThumbnailSize int
+	// PendingWindow is how long a photo sent without a review waits for one.
+	PendingWindow time.Duration
+	// MaxPending caps the uploaded photos of a chat that belong to no review
+	// yet; zero leaves them unlimited.
+	MaxPending int
+	// PendingRetention is how long photos without a review are kept, as
+	// clients may still name them in attachment_ids after PendingWindow.
+	PendingRetention time.Duration
+}
+
+type AttachmentService interface {
+	// Upload validates and stores a photo for the chat. A non-empty source
+	// identifies where the photo came from; uploading the same source again
+	// returns the stored attachment. Uploads without source come from
+	// clients and are refused with ErrTooManyPendingAttachments once the chat
+	// holds MaxPending photos without review.
+	Upload(ctx context.Context, chatID int64, fileName, source string, r io.Reader) (*entity.Attachment, error)
+	// Import downloads media the customer sent on channel and stores it.
+	// Media imported before for the same chat is returned as is.
+	Import(ctx context.Context, chatID int64, channel string, media entity.InboundMedia) (*entity.Attachment, error)
+	// Open returns the original file, or its JPEG thumbnail. A non-zero
+	// chatID restricts it to the chat's attachments: those of other chats are
+	// reported as ErrAttachmentNotFound without opening them.
+	Open(ctx context.Context, chatID int64, id string, thumbnail bool) (*entity.Attachment, io.ReadCloser, error)
+	// CheckPending returns ErrInvalidAttachment unless every one of ids is a
+	// photo of the chat that belongs to no review yet.
+	CheckPending(ctx context.Context, chatID int64, ids []string) error
+	// ClaimPending attaches the photos among ids and the chat's other pending
+	// photos to a review.
+	ClaimPending(ctx context.Context, chatID int64, ids []string, reviewID string) ([]entity.Attachment, error)
+	// PrunePending deletes photos that got no review within PendingRetention.
+	PrunePending(ctx context.Context) error
+}
+
+type attachmentService struct {
+	attachmentRepo AttachmentRepository
+	blobs          BlobStore
+	fetchers       map[string]MediaFetcher
+	cfg            AttachmentConfig
+}
+
+// NewAttachmentService stores photos in bs. fetchers download media by
+// channel name; channels without one cannot receive photos.
+func NewAttachmentService(ar AttachmentRepository, bs BlobStore, fetchers map[string]MediaFetcher, cfg AttachmentConfig) AttachmentService {
+	return &attachmentService{
+		attachmentRepo: ar,
+		blobs:          bs,
+		fetchers:       fetchers,
+		cfg:            cfg,
+	}
+}
+
+func (s *attachmentService) Upload(ctx context.Context, chatID int64, fileName, source string, r io.Reader) (*entity.Attachment, error) {
+	if source != "" {
+		if existing, err := s.findBySource(ctx, chatID, source); existing != nil || err != nil {
+			return existing, err
+		}
+	} else if s.cfg.MaxPending > 0 {
+		pending, err := s.attachmentRepo.CountPendingByChat(ctx, chatID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count pending attachments: %w", err)
+		}
+		if pending >= s.cfg.MaxPending {
+			return nil, fmt.Errorf("%w: chat %d has %d", ErrTooManyPendingAttachments, chatID, pending)
+		}
+	}
+	return s.store(ctx, chatID, fileName, source, r)
+}
+
+func (s *attachmentService) Import(ctx context.Context, chatID int64, channel string, media entity.InboundMedia) (*entity.Attachment, error) {
+	source := channel + ":" + media.Ref
+	if existing, err := s.findBySource(ctx, chatID, source); existing != nil || err != nil {
+		return existing, err
+	}
+
+	fetcher, ok := s.fetchers[channel]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrMediaFetcherUnavailable, channel)
+	}
+	body, err := fetcher.FetchMedia(ctx, media.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s media: %w", channel, err)
+	}
+	defer body.Close()
+	return s.store(ctx, chatID, "", source, body)
+}
+
+// findBySource returns nil without error if the source was not stored yet.
+func (s *attachmentService) findBySource(ctx context.Context, chatID int64, source string) (*entity.Attachment, error) {
+	existing, err := s.attachmentRepo.FindBySource(ctx, chatID, source)
+	if errors.Is(err, ErrAttachmentNotFound) {
+		return nil, nil
+	}
+	return existing, err
+}
+
+func (s *attachmentService) store(ctx context.Context, chatID int64, fileName, source string, r io.Reader) (*entity.Attachment, error) {
+	data, err := io.ReadAll(io.LimitReader(r, s.cfg.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if int64(len(data)) > s.cfg.MaxBytes {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	// The header is checked before decoding, so oversized images are
+	// rejected without allocating their pixels.
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	contentType, supported := attachmentContentTypes[format]
+	if err != nil || !supported {
+		return nil, ErrUnsupportedAttachment
+	}
+	if config.Width*config.Height > s.cfg.MaxPixels {
+		return nil, ErrAttachmentTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedAttachment, err)
+	}
+	var thumbnail bytes.Buffer
+	if err := jpeg.Encode(&thumbnail, imaging.Thumbnail(img, s.cfg.ThumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	attachment := &entity.Attachment{
+		ID:          uuid.NewString(),
+		ChatID:      chatID,
+		FileName:    fileName,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       config.Width,
+		Height:      config.Height,
+		Source:      source,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.blobs.Put(ctx, attachment.BlobKey(), bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+	if err := s.blobs.Put(ctx, attachment.ThumbnailKey(), &thumbnail); err != nil {
+		s.deleteBlobs(ctx, attachment)
+		return nil, fmt.Errorf("failed to store thumbnail: %w", err)
+	}
+	if err := s.attachmentRepo.Save(ctx, attachment); err != nil {
+		s.deleteBlobs(ctx, attachment)
+		return nil, err
+	}
+
+	log.Printf("Stored %s attachment %s (%dx%d, %d bytes) for chat %d", format, attachment.ID, attachment.Width, attachment.Height, attachment.Size, chatID)
+	return attachment, nil
+}
+
+func (s *attachmentService) deleteBlobs(ctx context.Context, attachment *entity.Attachment) {
+	for _, key := range []string{attachment.BlobKey(), attachment.ThumbnailKey()} {
+		if err := s.blobs.Delete(ctx, key); err != nil {
+			log.Printf("WARN: Failed to delete blob '%s' of discarded attachment: %v", key, err)
+		}
+	}
+}
+
+func (s *attachmentService) Open(ctx context.Context, chatID int64, id string, thumbnail bool) (*entity.Attachment, io.ReadCloser, error) {
+	attachment, err := s.attachmentRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if chatID != 0 && attachment.ChatID != chatID {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	key := attachment.BlobKey()
+	if thumbnail {
+		key = attachment.ThumbnailKey()
+	}
+	body, err := s.blobs.Get(ctx, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open attachment %s: %w", id, err)
+	}
+	return attachment, body, nil
+}
+
+func (s *attachmentService) CheckPending(ctx context.Context, chatID int64, ids []string) error {
+	unique := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidAttachment, id)
+		}
+		unique[id] = struct{}{}
+	}
+	if len(unique) == 0 {
+		return nil
+	}
+	count, err := s.attachmentRepo.CountPending(ctx, chatID, ids)
+	if err != nil {
+		return fmt.Errorf("failed to check attachments: %w", err)
+	}
+	if count != len(unique) {
+		return fmt.Errorf("%w: %d of %d attachment(s) are no pending photos of chat %d", ErrInvalidAttachment, len(unique)-count, len(unique), chatID)
+	}
+	return nil
+}
+
+func (s *attachmentService) ClaimPending(ctx context.Context, chatID int64, ids []string, reviewID string) ([]entity.Attachment, error) {
+	return s.attachmentRepo.ClaimPending(ctx, chatID, time.Now().Add(-s.cfg.PendingWindow), ids, reviewID)
+}
+
+func (s *attachmentService) PrunePending(ctx context.Context) error {
+	deleted, err := s.attachmentRepo.DeletePendingBefore(ctx, time.Now().Add(-s.cfg.PendingRetention))
+	if err != nil {
+		return fmt.Errorf("failed to prune pending attachments: %w", err)
+	}
+	for i := range deleted {
+		s.deleteBlobs(ctx, &deleted[i])
+	}
+	if len(deleted) > 0 {
+		log.Printf("Deleted %d attachment(s) that never got a review", len(deleted))
+	}
+	return nil
+}
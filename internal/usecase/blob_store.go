@@ -0,0 +1,17 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps files such as attachments outside the database.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get returns ErrBlobNotFound for unknown keys.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
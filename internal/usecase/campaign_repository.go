@@ -0,0 +1,16 @@
+package usecase
+
+import (
+	"context"
+	"smb-chatbot/internal/entity"
+	"time"
+)
+
+type CampaignRepository interface {
+	FindEnabled(ctx context.Context) ([]entity.Campaign, error)
+	// FindCandidates returns Idle conversations without a review that are idle
+	// for at least campaign.MinIdle and not within campaign.Cooldown of a previous campaign message.
+	FindCandidates(ctx context.Context, campaign entity.Campaign, now time.Time) ([]*entity.Conversation, error)
+	RecordSend(ctx context.Context, campaignID, chatID int64, sentAt time.Time) error
+	Stats(ctx context.Context) ([]entity.CampaignStats, error)
+}
@@ -0,0 +1,144 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/entity"
+)
+
+type CampaignScheduler interface {
+	RunCampaigns(ctx context.Context) error
+	Stats(ctx context.Context) ([]entity.CampaignStats, error)
+}
+
+type campaignScheduler struct {
+	campaignRepo CampaignRepository
+	convoRepo    ConversationRepository
+	historyRepo  HistoryRepository
+	messenger    MessengerClient
+	locker       ChatLocker
+	txManager    TxManager
+}
+
+func NewCampaignScheduler(
+	cpr CampaignRepository,
+	cr ConversationRepository,
+	hr HistoryRepository,
+	mc MessengerClient,
+	locker ChatLocker,
+	tm TxManager,
+) CampaignScheduler {
+	return &campaignScheduler{
+		campaignRepo: cpr,
+		convoRepo:    cr,
+		historyRepo:  hr,
+		messenger:    mc,
+		locker:       locker,
+		txManager:    tm,
+	}
+}
+
+func (s *campaignScheduler) RunCampaigns(ctx context.Context) error {
+	campaigns, err := s.campaignRepo.FindEnabled(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load campaigns: %w", err)
+	}
+
+	for _, campaign := range campaigns {
+		if err := s.runCampaign(ctx, campaign); err != nil {
+			log.Printf("ERROR running campaign '%s': %v", campaign.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *campaignScheduler) runCampaign(ctx context.Context, campaign entity.Campaign) error {
+	now := time.Now()
+	candidates, err := s.campaignRepo.FindCandidates(ctx, campaign, now)
+	if err != nil {
+		return fmt.Errorf("failed to find candidates: %w", err)
+	}
+
+	sent := 0
+	for _, candidate := range candidates {
+		if s.sendToCandidate(ctx, campaign, candidate, now) {
+			sent++
+		}
+	}
+
+	if sent > 0 {
+		log.Printf("Campaign '%s' sent %d review requests", campaign.Name, sent)
+	}
+	return nil
+}
+
+func (s *campaignScheduler) sendToCandidate(ctx context.Context, campaign entity.Campaign, candidate *entity.Conversation, now time.Time) bool {
+	unlock, err := s.locker.Lock(ctx, candidate.ChatID)
+	if err != nil {
+		if !errors.Is(err, ErrChatBusy) {
+			log.Printf("ERROR locking chat %d for campaign '%s': %v", candidate.ChatID, campaign.Name, err)
+		}
+		return false
+	}
+	defer unlock()
+
+	// The customer may have written in since the candidates were selected.
+	conversation, err := s.convoRepo.FindByChatID(ctx, candidate.ChatID)
+	if err != nil {
+		log.Printf("ERROR reloading conversation for chat %d: %v", candidate.ChatID, err)
+		return false
+	}
+	if conversation.State != entity.StateIdle || !conversation.LastInteractionAt.Equal(candidate.LastInteractionAt) {
+		return false
+	}
+
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.campaignRepo.RecordSend(ctx, campaign.ID, conversation.ChatID, now); err != nil {
+			return err
+		}
+		// The campaign asks for a review, so it offers the same quick replies as the bot.
+		message := entity.OutboundMessage{Text: campaign.Message, QuickReplies: reviewRequestOptions, ReviewRequest: true}
+		messageID, err := s.messenger.SendRichMessage(ctx, conversation.ChatID, message)
+		if err != nil {
+			return err
+		}
+		entry := entity.HistoryEntry{
+			IsUserMessage: false,
+			Text:          campaign.Message,
+			Timestamp:     time.Now(),
+			MessageID:     messageID,
+		}
+		if err := s.historyRepo.SaveHistoryEntry(ctx, conversation.ChatID, entry); err != nil {
+			return err
+		}
+
+		// The outreach restarts the clock so the sweeper times out the request
+		// relative to when it was sent, not to the customer's last message.
+		conversation.State = entity.StateAwaitingReview
+		conversation.LastInteractionAt = now
+		conversation.ReminderSentAt = time.Time{}
+		if err := s.convoRepo.Save(ctx, conversation); err != nil {
+			return err
+		}
+		return s.convoRepo.RecordTransition(ctx, &entity.ConversationTransition{
+			ChatID:    conversation.ChatID,
+			FromState: entity.StateIdle,
+			ToState:   entity.StateAwaitingReview,
+			Reason:    entity.TransitionReasonCampaign,
+			CreatedAt: now,
+		})
+	})
+	if err != nil {
+		log.Printf("ERROR sending campaign '%s' message to chat %d: %v", campaign.Name, conversation.ChatID, err)
+		return false
+	}
+	return true
+}
+
+func (s *campaignScheduler) Stats(ctx context.Context) ([]entity.CampaignStats, error) {
+	return s.campaignRepo.Stats(ctx)
+}
@@ -0,0 +1,94 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"smb-chatbot/internal/entity"
+)
+
+// ChannelRegistry holds the enabled messenger clients by channel name.
+type ChannelRegistry struct {
+	clients        map[string]MessengerClient
+	names          []string
+	defaultChannel string
+}
+
+func NewChannelRegistry() *ChannelRegistry {
+	return &ChannelRegistry{clients: make(map[string]MessengerClient)}
+}
+
+// Register enables a channel. The first registered channel is the default
+// for conversations that never arrived through a specific channel.
+func (r *ChannelRegistry) Register(name string, client MessengerClient) {
+	if _, ok := r.clients[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.clients[name] = client
+	if r.defaultChannel == "" {
+		r.defaultChannel = name
+	}
+}
+
+func (r *ChannelRegistry) Get(name string) (MessengerClient, bool) {
+	client, ok := r.clients[name]
+	return client, ok
+}
+
+// Names lists the enabled channels in registration order.
+func (r *ChannelRegistry) Names() []string {
+	return r.names
+}
+
+func (r *ChannelRegistry) Default() string {
+	return r.defaultChannel
+}
+
+// channelRouter is the MessengerClient used for delivery: it sends each
+// message through the channel stored on the chat's conversation.
+type channelRouter struct {
+	registry  *ChannelRegistry
+	convoRepo ConversationRepository
+}
+
+func NewChannelRouter(registry *ChannelRegistry, cr ConversationRepository) MessengerClient {
+	return &channelRouter{
+		registry:  registry,
+		convoRepo: cr,
+	}
+}
+
+func (r *channelRouter) SendMessage(ctx context.Context, chatID int64, text string) error {
+	client, err := r.client(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	return client.SendMessage(ctx, chatID, text)
+}
+
+func (r *channelRouter) SendRichMessage(ctx context.Context, chatID int64, message entity.OutboundMessage) (string, error) {
+	client, err := r.client(ctx, chatID)
+	if err != nil {
+		return "", err
+	}
+	return client.SendRichMessage(ctx, chatID, message)
+}
+
+func (r *channelRouter) client(ctx context.Context, chatID int64) (MessengerClient, error) {
+	conversation, err := r.convoRepo.FindByChatID(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find channel of chat %d: %w", chatID, err)
+	}
+
+	channel := conversation.Channel
+	if channel == "" {
+		channel = r.registry.Default()
+	}
+	// Chat IDs only mean something on their own channel, so there is no
+	// fallback when it was disabled.
+	client, ok := r.registry.Get(channel)
+	if !ok {
+		return nil, fmt.Errorf("channel '%s' of chat %d is not enabled", channel, chatID)
+	}
+	return client, nil
+}
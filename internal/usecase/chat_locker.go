@@ -0,0 +1,15 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrChatBusy = errors.New("chat is busy processing another message")
+
+// ChatLocker serializes work on a single chat across all app instances.
+type ChatLocker interface {
+	// Lock waits a bounded time for the chat lock and returns ErrChatBusy if it
+	// could not be acquired. The returned unlock func must always be called.
+	Lock(ctx context.Context, chatID int64) (unlock func(), err error)
+}
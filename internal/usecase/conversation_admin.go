@@ -0,0 +1,199 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/entity"
+)
+
+var ErrInvalidConversationQuery = errors.New("invalid conversation query")
+
+const (
+	defaultConversationPageSize = 50
+	maxConversationPageSize     = 200
+	// conversationTransitionLimit is how many transitions GetConversation returns.
+	conversationTransitionLimit = 20
+)
+
+type ListConversationsInput struct {
+	State string
+	// IdleFor selects conversations without interaction for at least this long.
+	IdleFor time.Duration
+	UserID  int64
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+	Limit  int
+}
+
+type ConversationPage struct {
+	Conversations []*entity.Conversation `json:"conversations"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type ConversationDetails struct {
+	*entity.Conversation
+	// Transitions are the latest state changes not caused by the customer,
+	// newest first.
+	Transitions []entity.ConversationTransition `json:"transitions"`
+}
+
+// ConversationAdmin lets operators inspect conversations and reset stuck
+// ones. Changes take the chat lock, like incoming messages do.
+type ConversationAdmin interface {
+	ListConversations(ctx context.Context, input ListConversationsInput) (*ConversationPage, error)
+	GetConversation(ctx context.Context, chatID int64) (*ConversationDetails, error)
+	// SetState forces the conversation into state and records the transition.
+	SetState(ctx context.Context, chatID int64, state string) (*entity.Conversation, error)
+	// Close ends a pending review request by returning the conversation to Idle.
+	Close(ctx context.Context, chatID int64) (*entity.Conversation, error)
+	// Delete removes the conversation and its history. Reviews are kept.
+	Delete(ctx context.Context, chatID int64) error
+}
+
+type conversationAdmin struct {
+	convoRepo ConversationRepository
+	locker    ChatLocker
+	txManager TxManager
+}
+
+func NewConversationAdmin(cr ConversationRepository, locker ChatLocker, tm TxManager) ConversationAdmin {
+	return &conversationAdmin{
+		convoRepo: cr,
+		locker:    locker,
+		txManager: tm,
+	}
+}
+
+func (a *conversationAdmin) ListConversations(ctx context.Context, input ListConversationsInput) (*ConversationPage, error) {
+	if input.State != "" && !entity.IsValidState(input.State) {
+		return nil, fmt.Errorf("%w: unknown state '%s'", ErrInvalidConversationQuery, input.State)
+	}
+	switch {
+	case input.Limit < 0 || input.IdleFor < 0:
+		return nil, fmt.Errorf("%w: limit and idle time must be positive", ErrInvalidConversationQuery)
+	case input.Limit == 0:
+		input.Limit = defaultConversationPageSize
+	case input.Limit > maxConversationPageSize:
+		input.Limit = maxConversationPageSize
+	}
+
+	filter := entity.ConversationFilter{State: input.State, UserID: input.UserID}
+	if input.IdleFor > 0 {
+		filter.InactiveSince = time.Now().Add(-input.IdleFor)
+	}
+	var after *entity.ConversationCursor
+	if input.Cursor != "" {
+		var cursor entity.ConversationCursor
+		if err := decodeCursor(input.Cursor, &cursor); err != nil || cursor.ChatID == 0 {
+			return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidConversationQuery)
+		}
+		after = &cursor
+	}
+
+	// One extra conversation tells whether another page follows.
+	conversations, err := a.convoRepo.List(ctx, filter, after, input.Limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
+	}
+	page := &ConversationPage{Conversations: conversations}
+	if len(conversations) > input.Limit {
+		page.Conversations = conversations[:input.Limit]
+		last := page.Conversations[input.Limit-1]
+		page.NextCursor = encodeCursor(entity.ConversationCursor{LastInteractionAt: last.LastInteractionAt, ChatID: last.ChatID})
+	}
+	if page.Conversations == nil {
+		page.Conversations = []*entity.Conversation{}
+	}
+	return page, nil
+}
+
+func (a *conversationAdmin) GetConversation(ctx context.Context, chatID int64) (*ConversationDetails, error) {
+	conversation, err := a.convoRepo.FindExisting(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := a.convoRepo.FindTransitions(ctx, chatID, conversationTransitionLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation transitions: %w", err)
+	}
+	if transitions == nil {
+		transitions = []entity.ConversationTransition{}
+	}
+	return &ConversationDetails{Conversation: conversation, Transitions: transitions}, nil
+}
+
+func (a *conversationAdmin) SetState(ctx context.Context, chatID int64, state string) (*entity.Conversation, error) {
+	if !entity.IsValidState(state) {
+		return nil, fmt.Errorf("%w: unknown state '%s'", ErrInvalidConversationQuery, state)
+	}
+	return a.transition(ctx, chatID, state, entity.TransitionReasonAdmin)
+}
+
+func (a *conversationAdmin) Close(ctx context.Context, chatID int64) (*entity.Conversation, error) {
+	return a.transition(ctx, chatID, entity.StateIdle, entity.TransitionReasonClosed)
+}
+
+// transition moves the conversation to state. Entering AwaitingReview starts
+// the review timeout and reminder from now, so the sweeper does not expire a
+// long idle conversation right away.
+func (a *conversationAdmin) transition(ctx context.Context, chatID int64, state, reason string) (*entity.Conversation, error) {
+	unlock, err := a.locker.Lock(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var conversation *entity.Conversation
+	err = a.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		conversation, err = a.convoRepo.FindExisting(ctx, chatID)
+		if err != nil {
+			return err
+		}
+		if conversation.State == state {
+			return nil
+		}
+
+		now := time.Now()
+		fromState := conversation.State
+		conversation.State = state
+		conversation.ReminderSentAt = time.Time{}
+		if state == entity.StateAwaitingReview {
+			conversation.LastInteractionAt = now
+		}
+		if err := a.convoRepo.Save(ctx, conversation); err != nil {
+			return err
+		}
+		return a.convoRepo.RecordTransition(ctx, &entity.ConversationTransition{
+			ChatID:    chatID,
+			FromState: fromState,
+			ToState:   state,
+			Reason:    reason,
+			CreatedAt: now,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Set conversation for chat %d to '%s' (%s)", chatID, state, reason)
+	return conversation, nil
+}
+
+func (a *conversationAdmin) Delete(ctx context.Context, chatID int64) error {
+	unlock, err := a.locker.Lock(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := a.convoRepo.Delete(ctx, chatID); err != nil {
+		return err
+	}
+	log.Printf("Deleted conversation for chat %d", chatID)
+	return nil
+}
@@ -0,0 +1,206 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/entity"
+)
+
+// memoryConversations keeps conversations and records transitions. Saving
+// requires the chat lock, as the admin must hold it for every change.
+type memoryConversations struct {
+	ConversationRepository
+	locker        *testLocker
+	conversations map[int64]entity.Conversation
+	transitions   []entity.ConversationTransition
+}
+
+func (r *memoryConversations) FindExisting(_ context.Context, chatID int64) (*entity.Conversation, error) {
+	conversation, ok := r.conversations[chatID]
+	if !ok {
+		return nil, ErrConversationNotFound
+	}
+	return &conversation, nil
+}
+
+func (r *memoryConversations) Save(_ context.Context, conversation *entity.Conversation) error {
+	if !r.locker.locked[conversation.ChatID] {
+		return ErrChatBusy
+	}
+	r.conversations[conversation.ChatID] = *conversation
+	return nil
+}
+
+func (r *memoryConversations) RecordTransition(_ context.Context, transition *entity.ConversationTransition) error {
+	r.transitions = append(r.transitions, *transition)
+	return nil
+}
+
+func (r *memoryConversations) Delete(_ context.Context, chatID int64) error {
+	if !r.locker.locked[chatID] {
+		return ErrChatBusy
+	}
+	if _, ok := r.conversations[chatID]; !ok {
+		return ErrConversationNotFound
+	}
+	delete(r.conversations, chatID)
+	return nil
+}
+
+// testLocker tracks which chats are locked and fails for busy ones.
+type testLocker struct {
+	locked map[int64]bool
+	busy   map[int64]bool
+}
+
+func (l *testLocker) Lock(_ context.Context, chatID int64) (func(), error) {
+	if l.busy[chatID] {
+		return func() {}, ErrChatBusy
+	}
+	l.locked[chatID] = true
+	return func() { delete(l.locked, chatID) }, nil
+}
+
+func newTestAdmin(conversations ...entity.Conversation) (ConversationAdmin, *memoryConversations, *testLocker) {
+	locker := &testLocker{locked: map[int64]bool{}, busy: map[int64]bool{}}
+	repo := &memoryConversations{locker: locker, conversations: map[int64]entity.Conversation{}}
+	for _, conversation := range conversations {
+		repo.conversations[conversation.ChatID] = conversation
+	}
+	return NewConversationAdmin(repo, locker, directTx{}), repo, locker
+}
+
+func TestConversationAdminStateChanges(t *testing.T) {
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+	reminded := time.Now().Add(-time.Hour)
+	idle := entity.Conversation{ChatID: 1, State: entity.StateIdle, LastInteractionAt: lastWeek}
+	awaiting := entity.Conversation{ChatID: 1, State: entity.StateAwaitingReview, LastInteractionAt: lastWeek, ReminderSentAt: reminded}
+
+	tests := []struct {
+		name    string
+		stored  entity.Conversation
+		change  func(ConversationAdmin) (*entity.Conversation, error)
+		wantErr error
+		// wantTransition is the recorded transition, nil for none.
+		wantTransition *entity.ConversationTransition
+		// wantRestarted expects the review timeout to start from now.
+		wantRestarted bool
+	}{
+		{
+			name:   "request a review",
+			stored: idle,
+			change: func(a ConversationAdmin) (*entity.Conversation, error) {
+				return a.SetState(context.Background(), 1, entity.StateAwaitingReview)
+			},
+			wantTransition: &entity.ConversationTransition{ChatID: 1, FromState: entity.StateIdle, ToState: entity.StateAwaitingReview, Reason: entity.TransitionReasonAdmin},
+			wantRestarted:  true,
+		},
+		{
+			name:   "reset to idle",
+			stored: awaiting,
+			change: func(a ConversationAdmin) (*entity.Conversation, error) {
+				return a.SetState(context.Background(), 1, entity.StateIdle)
+			},
+			wantTransition: &entity.ConversationTransition{ChatID: 1, FromState: entity.StateAwaitingReview, ToState: entity.StateIdle, Reason: entity.TransitionReasonAdmin},
+		},
+		{
+			name:   "close a review request",
+			stored: awaiting,
+			change: func(a ConversationAdmin) (*entity.Conversation, error) {
+				return a.Close(context.Background(), 1)
+			},
+			wantTransition: &entity.ConversationTransition{ChatID: 1, FromState: entity.StateAwaitingReview, ToState: entity.StateIdle, Reason: entity.TransitionReasonClosed},
+		},
+		{
+			name:   "state already set",
+			stored: awaiting,
+			change: func(a ConversationAdmin) (*entity.Conversation, error) {
+				return a.SetState(context.Background(), 1, entity.StateAwaitingReview)
+			},
+		},
+		{
+			name:   "close an idle conversation",
+			stored: idle,
+			change: func(a ConversationAdmin) (*entity.Conversation, error) {
+				return a.Close(context.Background(), 1)
+			},
+		},
+		{
+			name:   "unknown state",
+			stored: idle,
+			change: func(a ConversationAdmin) (*entity.Conversation, error) {
+				return a.SetState(context.Background(), 1, "Done")
+			},
+			wantErr: ErrInvalidConversationQuery,
+		},
+		{
+			name:   "unknown chat",
+			stored: idle,
+			change: func(a ConversationAdmin) (*entity.Conversation, error) {
+				return a.SetState(context.Background(), 2, entity.StateAwaitingReview)
+			},
+			wantErr: ErrConversationNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin, repo, locker := newTestAdmin(tt.stored)
+			before := time.Now()
+
+			conversation, err := tt.change(admin)
+			assert.Empty(t, locker.locked, "chat lock is held after the change")
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, tt.stored, repo.conversations[tt.stored.ChatID])
+				assert.Empty(t, repo.transitions)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, repo.conversations[1], *conversation, "returned conversation differs from the stored one")
+
+			if tt.wantTransition == nil {
+				assert.Equal(t, tt.stored, *conversation)
+				assert.Empty(t, repo.transitions)
+				return
+			}
+			assert.Equal(t, tt.wantTransition.ToState, conversation.State)
+			assert.True(t, conversation.ReminderSentAt.IsZero(), "reminder of the previous state is kept")
+			if tt.wantRestarted {
+				assert.False(t, conversation.LastInteractionAt.Before(before), "review timeout does not restart")
+			} else {
+				assert.Equal(t, tt.stored.LastInteractionAt, conversation.LastInteractionAt)
+			}
+
+			require.Len(t, repo.transitions, 1)
+			transition := repo.transitions[0]
+			assert.False(t, transition.CreatedAt.Before(before))
+			transition.CreatedAt = time.Time{}
+			assert.Equal(t, *tt.wantTransition, transition)
+		})
+	}
+}
+
+func TestConversationAdminBusyChat(t *testing.T) {
+	admin, repo, locker := newTestAdmin(entity.Conversation{ChatID: 1, State: entity.StateIdle})
+	locker.busy[1] = true
+
+	_, err := admin.SetState(context.Background(), 1, entity.StateAwaitingReview)
+	assert.ErrorIs(t, err, ErrChatBusy)
+	assert.ErrorIs(t, admin.Delete(context.Background(), 1), ErrChatBusy)
+	assert.Equal(t, entity.StateIdle, repo.conversations[1].State)
+	assert.Empty(t, repo.transitions)
+}
+
+func TestConversationAdminDelete(t *testing.T) {
+	admin, repo, locker := newTestAdmin(entity.Conversation{ChatID: 1, State: entity.StateAwaitingReview})
+
+	require.NoError(t, admin.Delete(context.Background(), 1))
+	assert.NotContains(t, repo.conversations, int64(1))
+	assert.Empty(t, locker.locked)
+	assert.ErrorIs(t, admin.Delete(context.Background(), 1), ErrConversationNotFound)
+}
@@ -4,11 +4,33 @@ import (
 	"context"
 	"errors"
 	"smb-chatbot/internal/entity"
+	"time"
 )
 
 var ErrConversationNotFound = errors.New("conversation not found")
 
+type StaleConversationFilter struct {
+	State          string
+	InactiveSince  time.Time
+	OnlyUnreminded bool
+	Limit          int
+}
+
 type ConversationRepository interface {
 	Save(ctx context.Context, conversation *entity.Conversation) error
+	// FindByChatID creates a conversation in state Idle if the chat has none.
 	FindByChatID(ctx context.Context, chatID int64) (*entity.Conversation, error)
+	// FindExisting returns ErrConversationNotFound instead of creating the
+	// conversation.
+	FindExisting(ctx context.Context, chatID int64) (*entity.Conversation, error)
+	// List returns up to limit conversations matching filter, the longest
+	// inactive first, starting after the cursor position if after is set.
+	List(ctx context.Context, filter entity.ConversationFilter, after *entity.ConversationCursor, limit int) ([]*entity.Conversation, error)
+	FindStale(ctx context.Context, filter StaleConversationFilter) ([]*entity.Conversation, error)
+	RecordTransition(ctx context.Context, transition *entity.ConversationTransition) error
+	// FindTransitions returns the chat's latest transitions, newest first.
+	FindTransitions(ctx context.Context, chatID int64, limit int) ([]entity.ConversationTransition, error)
+	// Delete removes the conversation with its history and transitions. It
+	// returns ErrConversationNotFound if the chat has no conversation.
+	Delete(ctx context.Context, chatID int64) error
 }
@@ -0,0 +1,173 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/entity"
+)
+
+const reviewReminderText = "Just a friendly reminder: we'd love to hear about your experience. If you have a moment, please reply with a quick review!"
+
+type SweeperConfig struct {
+	// AwaitingReviewTimeout is how long a conversation may wait for a review before it is reset to Idle.
+	AwaitingReviewTimeout time.Duration
+	// ReminderAfter sends a single reminder once a conversation has waited this long. Zero disables reminders.
+	ReminderAfter time.Duration
+	BatchSize     int
+}
+
+type ConversationSweeper interface {
+	Sweep(ctx context.Context) error
+}
+
+type conversationSweeper struct {
+	convoRepo   ConversationRepository
+	historyRepo HistoryRepository
+	messenger   MessengerClient
+	locker      ChatLocker
+	txManager   TxManager
+	cfg         SweeperConfig
+}
+
+func NewConversationSweeper(
+	cr ConversationRepository,
+	hr HistoryRepository,
+	mc MessengerClient,
+	locker ChatLocker,
+	tm TxManager,
+	cfg SweeperConfig,
+) ConversationSweeper {
+	return &conversationSweeper{
+		convoRepo:   cr,
+		historyRepo: hr,
+		messenger:   mc,
+		locker:      locker,
+		txManager:   tm,
+		cfg:         cfg,
+	}
+}
+
+func (s *conversationSweeper) Sweep(ctx context.Context) error {
+	now := time.Now()
+
+	if s.cfg.ReminderAfter > 0 && s.cfg.ReminderAfter < s.cfg.AwaitingReviewTimeout {
+		if err := s.sendReminders(ctx, now); err != nil {
+			return err
+		}
+	}
+	return s.expire(ctx, now)
+}
+
+func (s *conversationSweeper) sendReminders(ctx context.Context, now time.Time) error {
+	conversations, err := s.convoRepo.FindStale(ctx, StaleConversationFilter{
+		State:          entity.StateAwaitingReview,
+		InactiveSince:  now.Add(-s.cfg.ReminderAfter),
+		OnlyUnreminded: true,
+		Limit:          s.cfg.BatchSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find conversations due for a reminder: %w", err)
+	}
+
+	for _, candidate := range conversations {
+		// Conversations past the timeout are expired instead of reminded.
+		if candidate.LastInteractionAt.Before(now.Add(-s.cfg.AwaitingReviewTimeout)) {
+			continue
+		}
+		s.withCurrentState(ctx, candidate, func(conversation *entity.Conversation) {
+			if !conversation.ReminderSentAt.IsZero() {
+				return
+			}
+			err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+				reminder := entity.OutboundMessage{Text: reviewReminderText, QuickReplies: reviewRequestOptions, ReviewRequest: true}
+				messageID, err := s.messenger.SendRichMessage(ctx, conversation.ChatID, reminder)
+				if err != nil {
+					return err
+				}
+				entry := entity.HistoryEntry{
+					IsUserMessage: false,
+					Text:          reviewReminderText,
+					Timestamp:     time.Now(),
+					MessageID:     messageID,
+				}
+				if err := s.historyRepo.SaveHistoryEntry(ctx, conversation.ChatID, entry); err != nil {
+					return fmt.Errorf("failed to save reminder history: %w", err)
+				}
+				conversation.ReminderSentAt = now
+				if err := s.convoRepo.Save(ctx, conversation); err != nil {
+					return fmt.Errorf("failed to save reminder timestamp: %w", err)
+				}
+				return nil
+			})
+			if err != nil {
+				log.Printf("ERROR sending review reminder to chat %d: %v", conversation.ChatID, err)
+				return
+			}
+			log.Printf("Sent review reminder to chat %d", conversation.ChatID)
+		})
+	}
+	return nil
+}
+
+func (s *conversationSweeper) expire(ctx context.Context, now time.Time) error {
+	conversations, err := s.convoRepo.FindStale(ctx, StaleConversationFilter{
+		State:         entity.StateAwaitingReview,
+		InactiveSince: now.Add(-s.cfg.AwaitingReviewTimeout),
+		Limit:         s.cfg.BatchSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find expired conversations: %w", err)
+	}
+
+	for _, candidate := range conversations {
+		s.withCurrentState(ctx, candidate, func(conversation *entity.Conversation) {
+			err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+				conversation.State = entity.StateIdle
+				conversation.ReminderSentAt = time.Time{}
+				if err := s.convoRepo.Save(ctx, conversation); err != nil {
+					return err
+				}
+				return s.convoRepo.RecordTransition(ctx, &entity.ConversationTransition{
+					ChatID:    conversation.ChatID,
+					FromState: entity.StateAwaitingReview,
+					ToState:   entity.StateIdle,
+					Reason:    entity.TransitionReasonIdleTimeout,
+					CreatedAt: now,
+				})
+			})
+			if err != nil {
+				log.Printf("ERROR expiring conversation for chat %d: %v", conversation.ChatID, err)
+				return
+			}
+			log.Printf("Expired AwaitingReview state for chat %d (idle since %s)", conversation.ChatID, conversation.LastInteractionAt.Format(time.RFC3339))
+		})
+	}
+	return nil
+}
+
+// withCurrentState locks the chat and re-reads its conversation, calling fn
+// only if the customer has not interacted since the candidate was selected.
+func (s *conversationSweeper) withCurrentState(ctx context.Context, candidate *entity.Conversation, fn func(conversation *entity.Conversation)) {
+	unlock, err := s.locker.Lock(ctx, candidate.ChatID)
+	if err != nil {
+		if !errors.Is(err, ErrChatBusy) {
+			log.Printf("ERROR locking chat %d for sweep: %v", candidate.ChatID, err)
+		}
+		return
+	}
+	defer unlock()
+
+	conversation, err := s.convoRepo.FindByChatID(ctx, candidate.ChatID)
+	if err != nil {
+		log.Printf("ERROR reloading conversation for chat %d: %v", candidate.ChatID, err)
+		return
+	}
+	if conversation.State != candidate.State || !conversation.LastInteractionAt.Equal(candidate.LastInteractionAt) {
+		return
+	}
+	fn(conversation)
+}
@@ -0,0 +1,21 @@
+package usecase
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Page cursors are opaque to clients, which only pass them back. They encode
+// the sort key of the last item returned.
+func encodeCursor(position any) string {
+	data, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string, position any) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, position)
+}
@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/entity"
+)
+
+// earlyReceiptRetention is how long a receipt waits for its message to be
+// stored before it is taken to refer to a message this app never sent.
+const earlyReceiptRetention = 24 * time.Hour
+
+// DeliveryTracker records the delivery and read receipts channels report for
+// sent messages.
+type DeliveryTracker interface {
+	RecordReceipt(ctx context.Context, receipt entity.DeliveryReceipt) error
+	// RecordClientReceipt applies a receipt the customer's client sent for a
+	// message of receipt.ChatID, whose ExternalID is the message's public ID.
+	// Such IDs are known before the client can see the message, so receipts
+	// for other IDs are ErrOutboxMessageNotFound and never kept for later.
+	RecordClientReceipt(ctx context.Context, receipt entity.DeliveryReceipt) error
+	// PruneEarlyReceipts forgets receipts that never matched a message.
+	PruneEarlyReceipts(ctx context.Context) error
+}
+
+type deliveryTracker struct {
+	outboxRepo OutboxRepository
+	txManager  TxManager
+}
+
+func NewDeliveryTracker(or OutboxRepository, tm TxManager) DeliveryTracker {
+	return &deliveryTracker{outboxRepo: or, txManager: tm}
+}
+
+func (t *deliveryTracker) RecordReceipt(ctx context.Context, receipt entity.DeliveryReceipt) error {
+	if receipt.ExternalID == "" {
+		return fmt.Errorf("delivery receipt without message id")
+	}
+	if len(entity.DeliveryStatusesBefore(receipt.Status)) == 0 {
+		return fmt.Errorf("invalid delivery status '%s'", receipt.Status)
+	}
+	if receipt.At.IsZero() {
+		receipt.At = time.Now()
+	}
+
+	return t.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		// The lock keeps the dispatcher from storing the external ID between
+		// the update and saving the receipt for later.
+		if err := t.outboxRepo.LockExternalID(ctx, receipt.ExternalID); err != nil {
+			return fmt.Errorf("failed to record delivery receipt: %w", err)
+		}
+		updated, err := t.outboxRepo.UpdateDeliveryStatus(ctx, receipt)
+		if err != nil {
+			return fmt.Errorf("failed to record delivery receipt: %w", err)
+		}
+		if updated {
+			return nil
+		}
+		// Receipts may arrive before the dispatcher stored the message's
+		// external ID; the dispatcher applies those once it did.
+		kept, err := t.outboxRepo.SaveEarlyReceipt(ctx, receipt)
+		if err != nil {
+			return fmt.Errorf("failed to record delivery receipt: %w", err)
+		}
+		if kept {
+			log.Printf("Kept '%s' receipt for message '%s' until the message is stored", receipt.Status, receipt.ExternalID)
+			return nil
+		}
+		// Receipts are redelivered and may arrive out of order; those are dropped.
+		log.Printf("Ignored '%s' receipt for message '%s': status already reached", receipt.Status, receipt.ExternalID)
+		return nil
+	})
+}
+
+func (t *deliveryTracker) RecordClientReceipt(ctx context.Context, receipt entity.DeliveryReceipt) error {
+	if receipt.ChatID == 0 {
+		return fmt.Errorf("client receipt without chat id")
+	}
+	if len(entity.DeliveryStatusesBefore(receipt.Status)) == 0 {
+		return fmt.Errorf("invalid delivery status '%s'", receipt.Status)
+	}
+	if receipt.At.IsZero() {
+		receipt.At = time.Now()
+	}
+
+	if _, err := t.outboxRepo.FindByMessageID(ctx, receipt.ChatID, receipt.ExternalID); err != nil {
+		return err
+	}
+	updated, err := t.outboxRepo.UpdateDeliveryStatus(ctx, receipt)
+	if err != nil {
+		return fmt.Errorf("failed to record delivery receipt: %w", err)
+	}
+	if !updated {
+		log.Printf("Ignored '%s' receipt for message '%s': status already reached", receipt.Status, receipt.ExternalID)
+	}
+	return nil
+}
+
+func (t *deliveryTracker) PruneEarlyReceipts(ctx context.Context) error {
+	return t.outboxRepo.PruneEarlyReceipts(ctx, time.Now().Add(-earlyReceiptRetention))
+}
+
+// applyEarlyReceipts applies the receipts that arrived for externalID before
+// it was stored. The caller must hold the lock of externalID.
+func applyEarlyReceipts(ctx context.Context, or OutboxRepository, externalID string) error {
+	receipts, err := or.TakeEarlyReceipts(ctx, externalID)
+	if err != nil {
+		return err
+	}
+	for _, receipt := range receipts {
+		if _, err := or.UpdateDeliveryStatus(ctx, receipt); err != nil {
+			return err
+		}
+		log.Printf("Applied early '%s' receipt for message '%s'", receipt.Status, receipt.ExternalID)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/entity"
+)
+
+// receiptOutbox holds the outbox messages of chat 7 and records the receipts
+// applied or kept.
+type receiptOutbox struct {
+	OutboxRepository
+	messages map[string]string
+	applied  []entity.DeliveryReceipt
+	early    []entity.DeliveryReceipt
+}
+
+func (o *receiptOutbox) FindByMessageID(_ context.Context, chatID int64, messageID string) (*entity.OutboxMessage, error) {
+	if _, ok := o.messages[messageID]; !ok || chatID != 7 {
+		return nil, ErrOutboxMessageNotFound
+	}
+	return &entity.OutboxMessage{MessageID: messageID, ChatID: chatID, Status: o.messages[messageID]}, nil
+}
+
+func (o *receiptOutbox) UpdateDeliveryStatus(_ context.Context, receipt entity.DeliveryReceipt) (bool, error) {
+	status, ok := o.messages[receipt.ExternalID]
+	for _, earlier := range entity.DeliveryStatusesBefore(receipt.Status) {
+		if ok && status == earlier {
+			o.messages[receipt.ExternalID] = receipt.Status
+			o.applied = append(o.applied, receipt)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (o *receiptOutbox) SaveEarlyReceipt(_ context.Context, receipt entity.DeliveryReceipt) (bool, error) {
+	o.early = append(o.early, receipt)
+	return true, nil
+}
+
+func TestRecordClientReceipt(t *testing.T) {
+	tests := []struct {
+		name        string
+		receipt     entity.DeliveryReceipt
+		wantErr     error
+		wantApplied bool
+	}{
+		{name: "read", receipt: entity.DeliveryReceipt{ExternalID: "m1", ChatID: 7, Status: entity.OutboxStatusRead}, wantApplied: true},
+		{name: "status already reached", receipt: entity.DeliveryReceipt{ExternalID: "m2", ChatID: 7, Status: entity.OutboxStatusDelivered}},
+		{name: "made-up message ID", receipt: entity.DeliveryReceipt{ExternalID: "nope", ChatID: 7, Status: entity.OutboxStatusRead}, wantErr: ErrOutboxMessageNotFound},
+		{name: "message of another chat", receipt: entity.DeliveryReceipt{ExternalID: "m1", ChatID: 8, Status: entity.OutboxStatusRead}, wantErr: ErrOutboxMessageNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbox := &receiptOutbox{messages: map[string]string{"m1": entity.OutboxStatusSent, "m2": entity.OutboxStatusRead}}
+			err := NewDeliveryTracker(outbox, nil).RecordClientReceipt(context.Background(), tt.receipt)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantApplied, len(outbox.applied) == 1)
+			assert.Empty(t, outbox.early, "client receipt was kept for later")
+		})
+	}
+}
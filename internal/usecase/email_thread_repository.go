@@ -0,0 +1,20 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"smb-chatbot/internal/entity"
+)
+
+var ErrEmailThreadNotFound = errors.New("email thread not found")
+
+type EmailThreadRepository interface {
+	// Create stores a new thread and assigns its ChatID.
+	Create(ctx context.Context, thread *entity.EmailThread) error
+	Update(ctx context.Context, thread *entity.EmailThread) error
+	FindByChatID(ctx context.Context, chatID int64) (*entity.EmailThread, error)
+	FindByAddress(ctx context.Context, address string) (*entity.EmailThread, error)
+	// FindByMessageIDs returns the thread any of messageIDs belongs to.
+	FindByMessageIDs(ctx context.Context, messageIDs []string) (*entity.EmailThread, error)
+	SaveMessageID(ctx context.Context, messageID string, chatID int64) error
+}
@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"smb-chatbot/internal/entity"
+)
+
+// EmailThreader maps inbound emails to chats so a customer's replies land in
+// the same conversation.
+type EmailThreader interface {
+	ToInput(ctx context.Context, email entity.InboundEmail) (HandleMessageInput, error)
+}
+
+type emailThreader struct {
+	threadRepo EmailThreadRepository
+	txManager  TxManager
+}
+
+func NewEmailThreader(etr EmailThreadRepository, tm TxManager) EmailThreader {
+	return &emailThreader{
+		threadRepo: etr,
+		txManager:  tm,
+	}
+}
+
+// ToInput resolves the chat of an email: first by the Message-IDs it replies
+// to, then by the sender's address, otherwise a new thread is started.
+func (t *emailThreader) ToInput(ctx context.Context, email entity.InboundEmail) (HandleMessageInput, error) {
+	address := strings.ToLower(strings.TrimSpace(email.From))
+	if address == "" {
+		return HandleMessageInput{}, errors.New("email has no sender address")
+	}
+
+	var thread *entity.EmailThread
+	err := t.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		thread, err = t.findThread(ctx, email, address)
+		if errors.Is(err, ErrEmailThreadNotFound) {
+			thread = &entity.EmailThread{Address: address}
+			if err := t.threadRepo.Create(ctx, thread); err != nil {
+				return err
+			}
+			log.Printf("Started email thread for chat %d", thread.ChatID)
+		} else if err != nil {
+			return err
+		}
+
+		if email.FromName != "" {
+			thread.Name = email.FromName
+		}
+		if email.Subject != "" {
+			thread.Subject = email.Subject
+		}
+		if email.MessageID != "" {
+			thread.LastMessageID = email.MessageID
+			thread.References = strings.Join(appendReference(email.References, email.InReplyTo, email.MessageID), " ")
+			if err := t.threadRepo.SaveMessageID(ctx, email.MessageID, thread.ChatID); err != nil {
+				return err
+			}
+		}
+		thread.UpdatedAt = time.Now()
+		return t.threadRepo.Update(ctx, thread)
+	})
+	if err != nil {
+		return HandleMessageInput{}, fmt.Errorf("failed to thread email from %s: %w", address, err)
+	}
+
+	name := thread.Name
+	if name == "" {
+		name = thread.Address
+	}
+	input := HandleMessageInput{
+		ChatID:   thread.ChatID,
+		UserID:   thread.ChatID,
+		UserName: name,
+		Text:     email.Text,
+		Channel:  entity.ChannelEmail,
+	}
+	if email.MessageID != "" {
+		input.MessageID = emailMessageKey(email.MessageID)
+	}
+	return input, nil
+}
+
+func (t *emailThreader) findThread(ctx context.Context, email entity.InboundEmail, address string) (*entity.EmailThread, error) {
+	ids := appendReference(email.References, email.InReplyTo)
+	if len(ids) > 0 {
+		thread, err := t.threadRepo.FindByMessageIDs(ctx, ids)
+		if err == nil {
+			return thread, nil
+		}
+		if !errors.Is(err, ErrEmailThreadNotFound) {
+			return nil, err
+		}
+	}
+	return t.threadRepo.FindByAddress(ctx, address)
+}
+
+// appendReference adds ids to refs, skipping empty and duplicate entries.
+func appendReference(refs []string, ids ...string) []string {
+	out := make([]string, 0, len(refs)+len(ids))
+	seen := make(map[string]bool, len(refs)+len(ids))
+	for _, id := range append(refs, ids...) {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
+
+// emailMessageKey turns a Message-ID into a processed message ID. Message-IDs
+// have no length limit, so overly long ones are hashed.
+func emailMessageKey(messageID string) string {
+	key := "email-" + messageID
+	if len(key) <= 255 {
+		return key
+	}
+	sum := sha256.Sum256([]byte(messageID))
+	return "email-" + hex.EncodeToString(sum[:])
+}
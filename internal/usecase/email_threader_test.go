@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/entity"
+)
+
+// directTx runs fn without a transaction.
+type directTx struct{}
+
+func (directTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// memoryThreads keeps email threads and the Message-IDs that belong to them.
+type memoryThreads struct {
+	EmailThreadRepository
+	threads    map[int64]*entity.EmailThread
+	messageIDs map[string]int64
+}
+
+func newMemoryThreads(threads ...entity.EmailThread) *memoryThreads {
+	r := &memoryThreads{threads: map[int64]*entity.EmailThread{}, messageIDs: map[string]int64{}}
+	for _, thread := range threads {
+		r.threads[thread.ChatID] = &thread
+	}
+	return r
+}
+
+func (r *memoryThreads) Create(_ context.Context, thread *entity.EmailThread) error {
+	thread.ChatID = entity.EmailChatIDBase + int64(len(r.threads)) + 1
+	r.threads[thread.ChatID] = thread
+	return nil
+}
+
+func (r *memoryThreads) Update(_ context.Context, thread *entity.EmailThread) error {
+	stored := *thread
+	r.threads[thread.ChatID] = &stored
+	return nil
+}
+
+func (r *memoryThreads) FindByAddress(_ context.Context, address string) (*entity.EmailThread, error) {
+	for _, thread := range r.threads {
+		if thread.Address == address {
+			found := *thread
+			return &found, nil
+		}
+	}
+	return nil, ErrEmailThreadNotFound
+}
+
+func (r *memoryThreads) FindByMessageIDs(_ context.Context, messageIDs []string) (*entity.EmailThread, error) {
+	for _, id := range messageIDs {
+		if chatID, ok := r.messageIDs[id]; ok {
+			found := *r.threads[chatID]
+			return &found, nil
+		}
+	}
+	return nil, ErrEmailThreadNotFound
+}
+
+func (r *memoryThreads) SaveMessageID(_ context.Context, messageID string, chatID int64) error {
+	r.messageIDs[messageID] = chatID
+	return nil
+}
+
+func TestEmailThreaderToInput(t *testing.T) {
+	const annChat = entity.EmailChatIDBase + 100
+	ann := entity.EmailThread{ChatID: annChat, Address: "ann@example.com", Name: "Ann", Subject: "Your visit", LastMessageID: "bot-1@shop", References: "first@mail bot-1@shop"}
+	tests := []struct {
+		name           string
+		email          entity.InboundEmail
+		wantChatID     int64
+		wantNewThread  bool
+		wantName       string
+		wantReferences string
+	}{
+		{
+			name:           "reply by In-Reply-To from another address",
+			email:          entity.InboundEmail{From: "ann@work.example", MessageID: "reply-2@mail", InReplyTo: "bot-1@shop"},
+			wantChatID:     annChat,
+			wantName:       "Ann",
+			wantReferences: "bot-1@shop reply-2@mail",
+		},
+		{
+			name:           "reply by References only",
+			email:          entity.InboundEmail{From: "ann@work.example", MessageID: "reply-2@mail", References: []string{"unknown@elsewhere", "first@mail"}},
+			wantChatID:     annChat,
+			wantName:       "Ann",
+			wantReferences: "unknown@elsewhere first@mail reply-2@mail",
+		},
+		{
+			name:           "new email from a known address",
+			email:          entity.InboundEmail{From: " ANN@example.com ", FromName: "Ann Smith", MessageID: "new@mail"},
+			wantChatID:     annChat,
+			wantName:       "Ann Smith",
+			wantReferences: "new@mail",
+		},
+		{
+			name:           "unknown sender",
+			email:          entity.InboundEmail{From: "bob@example.com", MessageID: "hello@mail", InReplyTo: "unknown@elsewhere"},
+			wantNewThread:  true,
+			wantName:       "bob@example.com",
+			wantReferences: "unknown@elsewhere hello@mail",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			threads := newMemoryThreads(ann)
+			threads.messageIDs["first@mail"] = annChat
+			threads.messageIDs["bot-1@shop"] = annChat
+
+			input, err := NewEmailThreader(threads, directTx{}).ToInput(context.Background(), tt.email)
+			require.NoError(t, err)
+
+			if tt.wantNewThread {
+				assert.NotEqual(t, int64(annChat), input.ChatID)
+				assert.Len(t, threads.threads, 2)
+			} else {
+				assert.Equal(t, tt.wantChatID, input.ChatID)
+			}
+			assert.Equal(t, input.ChatID, input.UserID)
+			assert.Equal(t, tt.wantName, input.UserName)
+			assert.Equal(t, entity.ChannelEmail, input.Channel)
+			assert.Equal(t, "email-"+tt.email.MessageID, input.MessageID)
+
+			thread := threads.threads[input.ChatID]
+			assert.Equal(t, tt.email.MessageID, thread.LastMessageID, "the next reply must answer this email")
+			assert.Equal(t, tt.wantReferences, thread.References)
+			// Replies to this email land in the same chat.
+			assert.Equal(t, input.ChatID, threads.messageIDs[tt.email.MessageID])
+		})
+	}
+}
+
+func TestEmailThreaderRejectsMissingSender(t *testing.T) {
+	_, err := NewEmailThreader(newMemoryThreads(), directTx{}).ToInput(context.Background(), entity.InboundEmail{From: " "})
+	assert.Error(t, err)
+}
+
+func TestEmailMessageKey(t *testing.T) {
+	assert.Equal(t, "email-abc@mail", emailMessageKey("abc@mail"))
+
+	long := emailMessageKey(strings.Repeat("a", 300) + "@mail")
+	assert.Len(t, long, len("email-")+64)
+	assert.Equal(t, long, emailMessageKey(strings.Repeat("a", 300)+"@mail"), "hashed keys must be stable")
+}
@@ -8,4 +8,6 @@ import (
 type HistoryRepository interface {
 	SaveHistoryEntry(ctx context.Context, chatID int64, entry entity.HistoryEntry) error
 	GetHistory(ctx context.Context, chatID int64, limit int) ([]entity.HistoryEntry, error)
+	// GetHistoryRange returns the chat's messages in the range, oldest first.
+	GetHistoryRange(ctx context.Context, chatID int64, r entity.HistoryRange) ([]entity.HistoryEntry, error)
 }
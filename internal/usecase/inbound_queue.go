@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrInboundQueueEmpty = errors.New("no inbound message ready")
+
+// QueuedMessage is an inbound message waiting for asynchronous processing.
+type QueuedMessage struct {
+	ID         int64
+	Input      HandleMessageInput
+	Attempts   int
+	EnqueuedAt time.Time
+	StartedAt  time.Time
+}
+
+type QueueStats struct {
+	Pending    int `json:"pending"`
+	Processing int `json:"processing"`
+	Failed     int `json:"failed"`
+	// OldestPendingAgeSeconds is how long the oldest pending message has been waiting.
+	OldestPendingAgeSeconds float64 `json:"oldest_pending_age_seconds"`
+	// The latency figures cover messages finished within the stats window.
+	ProcessedInWindow    int     `json:"processed_in_window"`
+	AvgWaitSeconds       float64 `json:"avg_wait_seconds"`
+	P95WaitSeconds       float64 `json:"p95_wait_seconds"`
+	AvgProcessingSeconds float64 `json:"avg_processing_seconds"`
+	P95ProcessingSeconds float64 `json:"p95_processing_seconds"`
+	StatsWindowSeconds   float64 `json:"stats_window_seconds"`
+}
+
+type InboundQueue interface {
+	Enqueue(ctx context.Context, input HandleMessageInput, now time.Time) (int64, error)
+	// Claim marks the next ready message as processing. A message is only
+	// ready when no earlier message of the same chat is still unfinished,
+	// which keeps per-chat ordering across all workers.
+	Claim(ctx context.Context, now time.Time) (*QueuedMessage, error)
+	Complete(ctx context.Context, id int64, finishedAt time.Time) error
+	Retry(ctx context.Context, id int64, nextAttemptAt time.Time, lastErr string) error
+	Fail(ctx context.Context, id int64, finishedAt time.Time, lastErr string) error
+	// RequeueStale returns messages stuck in processing since before startedBefore to pending.
+	RequeueStale(ctx context.Context, startedBefore time.Time) (int, error)
+	Stats(ctx context.Context, now time.Time, window time.Duration) (QueueStats, error)
+}
@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+type InboundConfig struct {
+	// Async enqueues inbound messages instead of processing them within the request.
+	Async       bool
+	MaxAttempts int
+	RetryDelay  time.Duration
+	// VisibilityTimeout is how long a message may stay in processing before
+	// it is assumed its worker died and it is handed out again.
+	VisibilityTimeout time.Duration
+	StatsWindow       time.Duration
+}
+
+type SubmitResult struct {
+	Reply   string
+	Queued  bool
+	QueueID int64
+}
+
+// InboundService is the entry point for every inbound message, whichever
+// channel it came from.
+type InboundService interface {
+	Submit(ctx context.Context, input HandleMessageInput) (SubmitResult, error)
+	// ProcessNext handles one queued message and reports whether there was one.
+	ProcessNext(ctx context.Context) (bool, error)
+	RequeueStale(ctx context.Context) error
+	QueueStats(ctx context.Context) (QueueStats, error)
+}
+
+type inboundService struct {
+	uc    ReviewUseCase
+	queue InboundQueue
+	cfg   InboundConfig
+}
+
+func NewInboundService(uc ReviewUseCase, q InboundQueue, cfg InboundConfig) InboundService {
+	return &inboundService{
+		uc:    uc,
+		queue: q,
+		cfg:   cfg,
+	}
+}
+
+func (s *inboundService) Submit(ctx context.Context, input HandleMessageInput) (SubmitResult, error) {
+	if !s.cfg.Async {
+		reply, err := s.uc.HandleMessage(ctx, input)
+		return SubmitResult{Reply: reply}, err
+	}
+	// Rejected here so the client learns about it; HandleMessage checks again.
+	if err := s.uc.CheckAttachments(ctx, input.ChatID, input.AttachmentIDs); err != nil {
+		return SubmitResult{}, err
+	}
+
+	id, err := s.queue.Enqueue(ctx, input, time.Now())
+	if err != nil {
+		return SubmitResult{}, fmt.Errorf("failed to enqueue inbound message: %w", err)
+	}
+	log.Printf("Queued inbound message %d for chat %d", id, input.ChatID)
+	return SubmitResult{Queued: true, QueueID: id}, nil
+}
+
+func (s *inboundService) ProcessNext(ctx context.Context) (bool, error) {
+	message, err := s.queue.Claim(ctx, time.Now())
+	if errors.Is(err, ErrInboundQueueEmpty) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to claim inbound message: %w", err)
+	}
+
+	input := message.Input
+	// Queue entries get a stable message ID, so a retry after a crash
+	// between commit and Complete replays the stored reply instead of
+	// processing the message twice.
+	if input.MessageID == "" {
+		input.MessageID = fmt.Sprintf("inbound-%d", message.ID)
+	}
+
+	reply, handleErr := s.uc.HandleMessage(ctx, input)
+	now := time.Now()
+	switch {
+	case handleErr == nil || reply != "":
+		// A reply means the turn was committed, even if part of it fell back to a canned answer.
+		if handleErr != nil {
+			log.Printf("WARN: Inbound message %d for chat %d completed with error: %v", message.ID, input.ChatID, handleErr)
+		}
+		err = s.queue.Complete(ctx, message.ID, now)
+		log.Printf("Processed inbound message %d for chat %d (waited %s, took %s)",
+			message.ID, input.ChatID, message.StartedAt.Sub(message.EnqueuedAt).Round(time.Millisecond), now.Sub(message.StartedAt).Round(time.Millisecond))
+	case message.Attempts >= s.cfg.MaxAttempts || errors.Is(handleErr, ErrChannelMismatch) || errors.Is(handleErr, ErrInvalidAttachment):
+		log.Printf("ERROR: Giving up on inbound message %d for chat %d after %d attempts: %v", message.ID, input.ChatID, message.Attempts, handleErr)
+		err = s.queue.Fail(ctx, message.ID, now, handleErr.Error())
+	default:
+		log.Printf("WARN: Inbound message %d for chat %d failed (attempt %d), retrying: %v", message.ID, input.ChatID, message.Attempts, handleErr)
+		err = s.queue.Retry(ctx, message.ID, now.Add(s.cfg.RetryDelay), handleErr.Error())
+	}
+	if err != nil {
+		return true, fmt.Errorf("failed to update inbound message %d: %w", message.ID, err)
+	}
+	return true, nil
+}
+
+func (s *inboundService) RequeueStale(ctx context.Context) error {
+	n, err := s.queue.RequeueStale(ctx, time.Now().Add(-s.cfg.VisibilityTimeout))
+	if err != nil {
+		return fmt.Errorf("failed to requeue stale inbound messages: %w", err)
+	}
+	if n > 0 {
+		log.Printf("WARN: Requeued %d inbound messages stuck in processing", n)
+	}
+	return nil
+}
+
+func (s *inboundService) QueueStats(ctx context.Context) (QueueStats, error) {
+	return s.queue.Stats(ctx, time.Now(), s.cfg.StatsWindow)
+}
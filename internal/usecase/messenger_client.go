@@ -1,7 +1,27 @@
 package usecase
 
-import "context"
+import (
+	"context"
+	"errors"
+
+	"smb-chatbot/internal/entity"
+)
+
+// ErrRecipientOptedOut is returned by a MessengerClient when the recipient
+// unsubscribed from the channel. Retrying such a delivery is pointless.
+var ErrRecipientOptedOut = errors.New("recipient opted out of messages")
+
+// ErrOutsideServiceWindow is returned by a MessengerClient for a channel that
+// delivers free-form messages only for a while after the customer's last
+// message, once that window closed. Retrying is pointless until the customer
+// writes again.
+var ErrOutsideServiceWindow = errors.New("customer service window closed")
 
 type MessengerClient interface {
 	SendMessage(ctx context.Context, chatID int64, text string) error
+	// SendRichMessage sends a message with quick replies and buttons.
+	// Text-only channels send message.FallbackText() instead. It returns the
+	// ID the message got, which delivery receipts refer to, or "" if the
+	// channel assigns none.
+	SendRichMessage(ctx context.Context, chatID int64, message entity.OutboundMessage) (string, error)
 }
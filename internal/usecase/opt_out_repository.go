@@ -0,0 +1,9 @@
+package usecase
+
+import "context"
+
+type OptOutRepository interface {
+	OptOut(ctx context.Context, channel string, chatID int64) error
+	OptIn(ctx context.Context, channel string, chatID int64) error
+	IsOptedOut(ctx context.Context, channel string, chatID int64) (bool, error)
+}
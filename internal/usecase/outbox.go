@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/entity"
+
+	"github.com/google/uuid"
+)
+
+// outboxMessenger is the MessengerClient handed to the use cases. Instead of
+// delivering, it queues the message in the caller's transaction.
+type outboxMessenger struct {
+	outboxRepo OutboxRepository
+}
+
+func NewOutboxMessenger(or OutboxRepository) MessengerClient {
+	return &outboxMessenger{outboxRepo: or}
+}
+
+func (m *outboxMessenger) SendMessage(ctx context.Context, chatID int64, text string) error {
+	_, err := m.SendRichMessage(ctx, chatID, entity.TextMessage(text))
+	return err
+}
+
+// SendRichMessage returns the ID of the queued message, under which its
+// delivery status can be looked up.
+func (m *outboxMessenger) SendRichMessage(ctx context.Context, chatID int64, outbound entity.OutboundMessage) (string, error) {
+	now := time.Now()
+	message := &entity.OutboxMessage{
+		MessageID:     uuid.NewString(),
+		ChatID:        chatID,
+		Text:          outbound.Text,
+		QuickReplies:  outbound.QuickReplies,
+		Buttons:       outbound.Buttons,
+		ReviewRequest: outbound.ReviewRequest,
+		Status:        entity.OutboxStatusQueued,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if err := m.outboxRepo.Enqueue(ctx, message); err != nil {
+		return "", fmt.Errorf("failed to queue outgoing message: %w", err)
+	}
+	return message.MessageID, nil
+}
+
+type OutboxConfig struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// BatchSize limits how many messages one Dispatch call delivers.
+	BatchSize int
+}
+
+type OutboxDispatcher interface {
+	Dispatch(ctx context.Context) error
+}
+
+type outboxDispatcher struct {
+	outboxRepo OutboxRepository
+	txManager  TxManager
+	messenger  MessengerClient
+	cfg        OutboxConfig
+}
+
+// NewOutboxDispatcher delivers queued messages through mc, the real channel client.
+func NewOutboxDispatcher(or OutboxRepository, tm TxManager, mc MessengerClient, cfg OutboxConfig) OutboxDispatcher {
+	return &outboxDispatcher{
+		outboxRepo: or,
+		txManager:  tm,
+		messenger:  mc,
+		cfg:        cfg,
+	}
+}
+
+func (d *outboxDispatcher) Dispatch(ctx context.Context) error {
+	for i := 0; i < d.cfg.BatchSize; i++ {
+		delivered, err := d.dispatchOne(ctx)
+		if err != nil {
+			return err
+		}
+		if !delivered {
+			return nil
+		}
+	}
+	return nil
+}
+
+// dispatchOne keeps the claimed row locked while sending, so no other
+// dispatcher instance can deliver the same message concurrently.
+func (d *outboxDispatcher) dispatchOne(ctx context.Context) (bool, error) {
+	found := false
+	err := d.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		now := time.Now()
+		message, err := d.outboxRepo.ClaimDue(ctx, now)
+		if errors.Is(err, ErrOutboxEmpty) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to claim outbox message: %w", err)
+		}
+		found = true
+
+		message.Attempts++
+		externalID, sendErr := d.messenger.SendRichMessage(ctx, message.ChatID, message.Outbound())
+		if sendErr != nil {
+			message.LastError = sendErr.Error()
+			if message.Attempts >= d.cfg.MaxAttempts || errors.Is(sendErr, ErrRecipientOptedOut) || errors.Is(sendErr, ErrOutsideServiceWindow) {
+				message.Status = entity.OutboxStatusFailed
+				log.Printf("ERROR: Giving up on outbox message %d for chat %d after %d attempts: %v", message.ID, message.ChatID, message.Attempts, sendErr)
+			} else {
+				message.NextAttemptAt = now.Add(d.backoff(message.Attempts))
+				log.Printf("WARN: Delivery of outbox message %d for chat %d failed (attempt %d), retrying at %s: %v",
+					message.ID, message.ChatID, message.Attempts, message.NextAttemptAt.Format(time.RFC3339), sendErr)
+			}
+		} else {
+			message.Status = entity.OutboxStatusSent
+			message.SentAt = time.Now()
+			message.LastError = ""
+			message.ExternalID = externalID
+		}
+		if message.ExternalID == "" {
+			return d.outboxRepo.Update(ctx, message)
+		}
+		// Receipts for externalID may already be waiting, or arrive until
+		// the transaction commits; the lock makes them wait for the commit.
+		if err := d.outboxRepo.LockExternalID(ctx, message.ExternalID); err != nil {
+			return err
+		}
+		if err := d.outboxRepo.Update(ctx, message); err != nil {
+			return err
+		}
+		return applyEarlyReceipts(ctx, d.outboxRepo, message.ExternalID)
+	})
+	return found, err
+}
+
+func (d *outboxDispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.BaseBackoff
+	for i := 1; i < attempts && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxBackoff)
+}
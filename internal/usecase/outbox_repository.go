@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"smb-chatbot/internal/entity"
+	"time"
+)
+
+var (
+	ErrOutboxEmpty           = errors.New("no outbox message due")
+	ErrOutboxMessageNotFound = errors.New("outbox message not found")
+)
+
+type OutboxRepository interface {
+	Enqueue(ctx context.Context, message *entity.OutboxMessage) error
+	// ClaimDue locks the oldest queued message due at now for the surrounding
+	// transaction, skipping messages already claimed by other dispatchers.
+	ClaimDue(ctx context.Context, now time.Time) (*entity.OutboxMessage, error)
+	// FindByMessageID returns the message of the chat with the public ID
+	// messageID, or ErrOutboxMessageNotFound.
+	FindByMessageID(ctx context.Context, chatID int64, messageID string) (*entity.OutboxMessage, error)
+	Update(ctx context.Context, message *entity.OutboxMessage) error
+	// UpdateDeliveryStatus applies receipt to the message with its external ID,
+	// unless the message already reached the same or a later status. It
+	// reports whether a message was updated.
+	UpdateDeliveryStatus(ctx context.Context, receipt entity.DeliveryReceipt) (bool, error)
+	// LockExternalID serializes storing an external ID with receipts for it
+	// until the surrounding transaction ends.
+	LockExternalID(ctx context.Context, externalID string) error
+	// SaveEarlyReceipt keeps receipt for later if no message has its external
+	// ID yet. It reports whether the receipt was kept.
+	SaveEarlyReceipt(ctx context.Context, receipt entity.DeliveryReceipt) (bool, error)
+	// TakeEarlyReceipts removes and returns the kept receipts for externalID,
+	// oldest first.
+	TakeEarlyReceipts(ctx context.Context, externalID string) ([]entity.DeliveryReceipt, error)
+	// PruneEarlyReceipts forgets receipts received before before, whose
+	// message was never sent by this app.
+	PruneEarlyReceipts(ctx context.Context, before time.Time) error
+}
@@ -0,0 +1,14 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"smb-chatbot/internal/entity"
+)
+
+var ErrProcessedMessageNotFound = errors.New("processed message not found")
+
+type ProcessedMessageRepository interface {
+	Find(ctx context.Context, chatID int64, messageID string) (*entity.ProcessedMessage, error)
+	Save(ctx context.Context, message *entity.ProcessedMessage) error
+}
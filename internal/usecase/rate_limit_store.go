@@ -0,0 +1,38 @@
+package usecase
+
+import (
+	"context"
+	"time"
+)
+
+// RateLimit is a token bucket holding up to Burst tokens that refills from
+// empty within Interval. A zero RateLimit admits everything.
+type RateLimit struct {
+	Burst    int
+	Interval time.Duration
+}
+
+func (l RateLimit) Enabled() bool {
+	return l.Burst > 0 && l.Interval > 0
+}
+
+// PerSecond is the refill rate in tokens per second.
+func (l RateLimit) PerSecond() float64 {
+	return float64(l.Burst) / l.Interval.Seconds()
+}
+
+// RateLimitStore keeps token buckets and mutes by key. A store backed by the
+// database shares them between app instances.
+type RateLimitStore interface {
+	// Take removes a token from the bucket of key, which starts full. If the
+	// bucket is empty it returns false and the time until the next token.
+	Take(ctx context.Context, key string, limit RateLimit) (ok bool, retryAfter time.Duration, err error)
+	// Mute rejects key until until.
+	Mute(ctx context.Context, key string, until time.Time) error
+	// MutedUntil returns when the mute of key ends, or the zero time if key
+	// is not muted.
+	MutedUntil(ctx context.Context, key string) (time.Time, error)
+	// Prune forgets buckets untouched since before, which are full again,
+	// and expired mutes.
+	Prune(ctx context.Context, before time.Time) error
+}
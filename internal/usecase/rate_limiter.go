@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrRateLimited = errors.New("rate limit exceeded")
+	ErrChatMuted   = errors.New("chat is muted for flooding")
+)
+
+type RateLimitConfig struct {
+	PerChat RateLimit
+	PerUser RateLimit
+	PerIP   RateLimit
+	// Flood counts every message to a chat, including rejected ones. A chat
+	// exceeding it is muted for MuteFor.
+	Flood   RateLimit
+	MuteFor time.Duration
+}
+
+// RateLimiter keeps scripts from flooding the bot, as every message costs
+// calls to the language model.
+type RateLimiter interface {
+	// Allow admits a message to chatID from userID, sent from ip. A zero
+	// userID or empty ip skips that limit. It returns ErrRateLimited or
+	// ErrChatMuted with the time after which the sender may retry.
+	Allow(ctx context.Context, chatID, userID int64, ip string) (retryAfter time.Duration, err error)
+	// AllowIP admits a request from ip that is not tied to a chat, such as
+	// starting a web chat session, against the per-IP limit only.
+	AllowIP(ctx context.Context, ip string) (retryAfter time.Duration, err error)
+	// Prune lets the store forget buckets that are full again.
+	Prune(ctx context.Context) error
+}
+
+type rateLimiter struct {
+	store RateLimitStore
+	cfg   RateLimitConfig
+}
+
+func NewRateLimiter(rls RateLimitStore, cfg RateLimitConfig) RateLimiter {
+	return &rateLimiter{store: rls, cfg: cfg}
+}
+
+func (l *rateLimiter) Allow(ctx context.Context, chatID, userID int64, ip string) (time.Duration, error) {
+	chatKey := fmt.Sprintf("chat:%d", chatID)
+	mutedUntil, err := l.store.MutedUntil(ctx, chatKey)
+	if err != nil {
+		return 0, err
+	}
+	if !mutedUntil.IsZero() {
+		return time.Until(mutedUntil), ErrChatMuted
+	}
+
+	if l.cfg.Flood.Enabled() && l.cfg.MuteFor > 0 {
+		ok, _, err := l.store.Take(ctx, "flood:"+chatKey, l.cfg.Flood)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			until := time.Now().Add(l.cfg.MuteFor)
+			if err := l.store.Mute(ctx, chatKey, until); err != nil {
+				return 0, err
+			}
+			log.Printf("USECASE: Muted chat %d for flooding until %s", chatID, until.Format(time.RFC3339))
+			return l.cfg.MuteFor, ErrChatMuted
+		}
+	}
+
+	// A message rejected by a later bucket still spends the tokens of the
+	// earlier ones, so retrying does not get around the limits.
+	buckets := []struct {
+		key   string
+		limit RateLimit
+		skip  bool
+	}{
+		{key: "ip:" + ip, limit: l.cfg.PerIP, skip: ip == ""},
+		{key: fmt.Sprintf("user:%d", userID), limit: l.cfg.PerUser, skip: userID == 0},
+		{key: chatKey, limit: l.cfg.PerChat},
+	}
+	for _, bucket := range buckets {
+		if bucket.skip || !bucket.limit.Enabled() {
+			continue
+		}
+		ok, retryAfter, err := l.store.Take(ctx, bucket.key, bucket.limit)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return retryAfter, ErrRateLimited
+		}
+	}
+	return 0, nil
+}
+
+func (l *rateLimiter) AllowIP(ctx context.Context, ip string) (time.Duration, error) {
+	if ip == "" || !l.cfg.PerIP.Enabled() {
+		return 0, nil
+	}
+	ok, retryAfter, err := l.store.Take(ctx, "ip:"+ip, l.cfg.PerIP)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return retryAfter, ErrRateLimited
+	}
+	return 0, nil
+}
+
+func (l *rateLimiter) Prune(ctx context.Context) error {
+	longest := l.cfg.MuteFor
+	for _, limit := range []RateLimit{l.cfg.PerChat, l.cfg.PerUser, l.cfg.PerIP, l.cfg.Flood} {
+		longest = max(longest, limit.Interval)
+	}
+	return l.store.Prune(ctx, time.Now().Add(-longest))
+}
@@ -0,0 +1,18 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"smb-chatbot/internal/entity"
+)
+
+var ErrReviewLinkNotFound = errors.New("review link not found")
+
+type ReviewDestinationRepository interface {
+	FindByBusinessID(ctx context.Context, businessID string) ([]entity.ReviewDestination, error)
+}
+
+type ReviewLinkRepository interface {
+	Save(ctx context.Context, link *entity.ReviewLink) error
+	RegisterClick(ctx context.Context, linkID string) (*entity.ReviewLink, error)
+}
@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"smb-chatbot/internal/entity"
+
+	"github.com/google/uuid"
+)
+
+type PublicReviewConfig struct {
+	BusinessID string
+	// MinRating is the lowest rating (1-5) that gets redirected to a public platform.
+	MinRating int
+	// LinkBaseURL is the public base URL of this service, used to build tracked redirect links.
+	LinkBaseURL string
+}
+
+type ReviewPromoter interface {
+	PromoteReview(ctx context.Context, review *entity.Review) error
+	ResolveLink(ctx context.Context, linkID string) (string, error)
+}
+
+type reviewPromoter struct {
+	destinationRepo ReviewDestinationRepository
+	linkRepo        ReviewLinkRepository
+	historyRepo     HistoryRepository
+	messenger       MessengerClient
+	txManager       TxManager
+	cfg             PublicReviewConfig
+}
+
+func NewReviewPromoter(
+	dr ReviewDestinationRepository,
+	lr ReviewLinkRepository,
+	hr HistoryRepository,
+	mc MessengerClient,
+	tm TxManager,
+	cfg PublicReviewConfig,
+) ReviewPromoter {
+	return &reviewPromoter{
+		destinationRepo: dr,
+		linkRepo:        lr,
+		historyRepo:     hr,
+		messenger:       mc,
+		txManager:       tm,
+		cfg:             cfg,
+	}
+}
+
+func (p *reviewPromoter) PromoteReview(ctx context.Context, review *entity.Review) error {
+	if review.Rating < p.cfg.MinRating {
+		return nil
+	}
+
+	destinations, err := p.destinationRepo.FindByBusinessID(ctx, p.cfg.BusinessID)
+	if err != nil {
+		return fmt.Errorf("failed to load review destinations: %w", err)
+	}
+	if len(destinations) == 0 {
+		log.Printf("No public review destinations configured for business '%s', skipping follow-up", p.cfg.BusinessID)
+		return nil
+	}
+	// Destinations come ordered by priority, the first one is the preferred platform.
+	destination := destinations[0]
+
+	linkID, err := uuid.NewRandom()
+	if err != nil {
+		return fmt.Errorf("failed to generate review link id: %w", err)
+	}
+	link := &entity.ReviewLink{
+		ID:        linkID.String(),
+		ReviewID:  review.ID,
+		ChatID:    review.ChatID,
+		Platform:  destination.Platform,
+		TargetURL: destination.BuildURL(review),
+		CreatedAt: time.Now(),
+	}
+	trackedURL := strings.TrimSuffix(p.cfg.LinkBaseURL, "/") + "/r/" + link.ID
+	platform := entity.PlatformDisplayName(destination.Platform)
+	message := entity.OutboundMessage{
+		Text:    fmt.Sprintf("We're so glad you had a great experience! Would you mind sharing your review on %s too? It really helps us.", platform),
+		Buttons: []entity.URLButton{{Title: "Review us on " + platform, URL: trackedURL}},
+	}
+
+	err = p.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := p.linkRepo.Save(ctx, link); err != nil {
+			return fmt.Errorf("failed to save review link: %w", err)
+		}
+		messageID, err := p.messenger.SendRichMessage(ctx, review.ChatID, message)
+		if err != nil {
+			return fmt.Errorf("failed to send public review follow-up: %w", err)
+		}
+		entry := entity.HistoryEntry{
+			IsUserMessage: false,
+			Text:          message.FallbackText(),
+			Timestamp:     time.Now(),
+			MessageID:     messageID,
+		}
+		if err := p.historyRepo.SaveHistoryEntry(ctx, review.ChatID, entry); err != nil {
+			return fmt.Errorf("failed to save review follow-up history: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Sent %s review link %s to chat %d", destination.Platform, link.ID, review.ChatID)
+	return nil
+}
+
+func (p *reviewPromoter) ResolveLink(ctx context.Context, linkID string) (string, error) {
+	link, err := p.linkRepo.RegisterClick(ctx, linkID)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Review link %s clicked (%d clicks), redirecting chat %d to %s", link.ID, link.ClickCount, link.ChatID, link.Platform)
+	return link.TargetURL, nil
+}
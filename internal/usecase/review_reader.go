@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"smb-chatbot/internal/entity"
+)
+
+var ErrInvalidReviewQuery = errors.New("invalid review query")
+
+const (
+	defaultReviewPageSize = 20
+	maxReviewPageSize     = 100
+)
+
+type ListReviewsInput struct {
+	Filter entity.ReviewFilter
+	// Sentiment narrows the rating range of Filter to the sentiment's ratings.
+	Sentiment string
+	// Sort is one of the entity.ReviewSort* orders, newest first by default.
+	Sort string
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+	Limit  int
+}
+
+type ReviewPage struct {
+	Reviews []entity.Review `json:"reviews"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ReviewWriter encodes reviews in an export format.
+type ReviewWriter interface {
+	Write(review entity.Review) error
+	// Close completes the export after the last review.
+	Close() error
+}
+
+// ReviewReader lists collected reviews with their photos.
+type ReviewReader interface {
+	ListReviews(ctx context.Context, input ListReviewsInput) (*ReviewPage, error)
+	GetReview(ctx context.Context, id string) (*entity.Review, error)
+	// ExportReviews writes all reviews matching the filters of input to w,
+	// ignoring Cursor and Limit, and closes w. Photos are not included.
+	// It returns the number of reviews written.
+	ExportReviews(ctx context.Context, input ListReviewsInput, w ReviewWriter) (int, error)
+}
+
+type reviewReader struct {
+	reviewRepo     ReviewRepository
+	attachmentRepo AttachmentRepository
+}
+
+// NewReviewReader creates a ReviewReader. ar may be nil when attachments are
+// disabled.
+func NewReviewReader(rr ReviewRepository, ar AttachmentRepository) ReviewReader {
+	return &reviewReader{
+		reviewRepo:     rr,
+		attachmentRepo: ar,
+	}
+}
+
+func (r *reviewReader) ListReviews(ctx context.Context, input ListReviewsInput) (*ReviewPage, error) {
+	filter, sort, matchesNone, err := reviewQuery(input)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case input.Limit < 0:
+		return nil, fmt.Errorf("%w: limit must be positive", ErrInvalidReviewQuery)
+	case input.Limit == 0:
+		input.Limit = defaultReviewPageSize
+	case input.Limit > maxReviewPageSize:
+		input.Limit = maxReviewPageSize
+	}
+	if matchesNone {
+		return &ReviewPage{Reviews: []entity.Review{}}, nil
+	}
+
+	var after *entity.ReviewCursor
+	if input.Cursor != "" {
+		var cursor entity.ReviewCursor
+		if err := decodeCursor(input.Cursor, &cursor); err != nil || cursor.Sort != sort || cursor.ID == "" {
+			return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidReviewQuery)
+		}
+		after = &cursor
+	}
+
+	// One extra review tells whether another page follows.
+	reviews, err := r.reviewRepo.List(ctx, filter, sort, after, input.Limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reviews: %w", err)
+	}
+	page := &ReviewPage{Reviews: reviews}
+	if len(reviews) > input.Limit {
+		page.Reviews = reviews[:input.Limit]
+		page.NextCursor = encodeCursor(page.Reviews[input.Limit-1].CursorAfter(sort))
+	}
+	if page.Reviews == nil {
+		page.Reviews = []entity.Review{}
+	}
+
+	if err := r.loadAttachments(ctx, page.Reviews); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (r *reviewReader) ExportReviews(ctx context.Context, input ListReviewsInput, w ReviewWriter) (int, error) {
+	filter, sort, matchesNone, err := reviewQuery(input)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	if !matchesNone {
+		err = r.reviewRepo.Each(ctx, filter, sort, func(review entity.Review) error {
+			count++
+			return w.Write(review)
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to export reviews: %w", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return count, fmt.Errorf("failed to finish review export: %w", err)
+	}
+	return count, nil
+}
+
+// reviewQuery validates the filters and order of input. matchesNone reports
+// filters that exclude every review, like a sentiment outside the rating range.
+func reviewQuery(input ListReviewsInput) (filter entity.ReviewFilter, sort string, matchesNone bool, err error) {
+	sort = input.Sort
+	if sort == "" {
+		sort = entity.ReviewSortNewest
+	}
+	if !entity.IsValidReviewSort(sort) {
+		return filter, "", false, fmt.Errorf("%w: unknown sort '%s'", ErrInvalidReviewQuery, sort)
+	}
+
+	filter = input.Filter
+	if filter.MinRating < 0 || filter.MinRating > 5 || filter.MaxRating < 0 || filter.MaxRating > 5 {
+		return filter, "", false, fmt.Errorf("%w: ratings are between 1 and 5", ErrInvalidReviewQuery)
+	}
+	if input.Sentiment != "" {
+		minRating, maxRating, ok := entity.SentimentRatings(input.Sentiment)
+		if !ok {
+			return filter, "", false, fmt.Errorf("%w: unknown sentiment '%s'", ErrInvalidReviewQuery, input.Sentiment)
+		}
+		filter.MinRating = max(filter.MinRating, minRating)
+		if filter.MaxRating == 0 || filter.MaxRating > maxRating {
+			filter.MaxRating = maxRating
+		}
+	}
+	matchesNone = filter.MaxRating > 0 && filter.MinRating > filter.MaxRating
+	return filter, sort, matchesNone, nil
+}
+
+func (r *reviewReader) GetReview(ctx context.Context, id string) (*entity.Review, error) {
+	review, err := r.reviewRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	reviews := []entity.Review{*review}
+	if err := r.loadAttachments(ctx, reviews); err != nil {
+		return nil, err
+	}
+	return &reviews[0], nil
+}
+
+func (r *reviewReader) loadAttachments(ctx context.Context, reviews []entity.Review) error {
+	if r.attachmentRepo == nil || len(reviews) == 0 {
+		return nil
+	}
+	ids := make([]string, len(reviews))
+	index := make(map[string]int, len(reviews))
+	for i, review := range reviews {
+		ids[i] = review.ID
+		index[review.ID] = i
+	}
+
+	attachments, err := r.attachmentRepo.FindByReviewIDs(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to load review photos: %w", err)
+	}
+	for _, attachment := range attachments {
+		if i, ok := index[attachment.ReviewID]; ok {
+			reviews[i].Attachments = append(reviews[i].Attachments, attachment)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"smb-chatbot/internal/entity"
+)
+
+// memoryReviews lists reviews like the Postgres repository: by the sort key,
+// ties broken by ID, continuing after the cursor position.
+type memoryReviews struct {
+	ReviewRepository
+	reviews []entity.Review
+}
+
+func (r *memoryReviews) List(_ context.Context, filter entity.ReviewFilter, sort string, after *entity.ReviewCursor, limit int) ([]entity.Review, error) {
+	byRating := strings.HasSuffix(sort, "rating")
+	compare := func(a, b entity.Review) int {
+		key := a.ReceivedAt.Compare(b.ReceivedAt)
+		if byRating {
+			key = a.Rating - b.Rating
+		}
+		if key == 0 {
+			key = strings.Compare(a.ID, b.ID)
+		}
+		if strings.HasPrefix(sort, "-") {
+			return -key
+		}
+		return key
+	}
+
+	sorted := slices.Clone(r.reviews)
+	slices.SortFunc(sorted, compare)
+	var page []entity.Review
+	for _, review := range sorted {
+		if filter.MinRating > 0 && review.Rating < filter.MinRating || filter.MaxRating > 0 && review.Rating > filter.MaxRating {
+			continue
+		}
+		if after != nil && compare(review, entity.Review{ID: after.ID, ReceivedAt: after.ReceivedAt, Rating: after.Rating}) <= 0 {
+			continue
+		}
+		if len(page) == limit {
+			break
+		}
+		page = append(page, review)
+	}
+	return page, nil
+}
+
+func TestListReviewsPagesThroughEveryOrder(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var reviews []entity.Review
+	for i := range 7 {
+		reviews = append(reviews, entity.Review{
+			ID: fmt.Sprintf("00000000-0000-0000-0000-00000000000%d", i),
+			// Ratings and times repeat, so ties are broken by ID.
+			Rating:     i % 3,
+			ReceivedAt: start.Add(time.Duration(i/2) * time.Hour),
+		})
+	}
+	repo := &memoryReviews{reviews: reviews}
+	reader := NewReviewReader(repo, nil)
+
+	for _, sort := range []string{entity.ReviewSortNewest, entity.ReviewSortOldest, entity.ReviewSortRatingDesc, entity.ReviewSortRatingAsc} {
+		t.Run(sort, func(t *testing.T) {
+			want, err := repo.List(context.Background(), entity.ReviewFilter{}, sort, nil, len(reviews))
+			require.NoError(t, err)
+
+			var got []entity.Review
+			input := ListReviewsInput{Sort: sort, Limit: 3}
+			for pages := 0; ; pages++ {
+				require.Less(t, pages, len(reviews), "pagination does not end")
+				page, err := reader.ListReviews(context.Background(), input)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(page.Reviews), input.Limit)
+				got = append(got, page.Reviews...)
+				if page.NextCursor == "" {
+					break
+				}
+				input.Cursor = page.NextCursor
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestListReviewsRejectsInvalidCursors(t *testing.T) {
+	reader := NewReviewReader(&memoryReviews{}, nil)
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "%%%"},
+		{name: "not JSON", cursor: encodeCursor("text")},
+		{name: "without ID", cursor: encodeCursor(entity.ReviewCursor{Sort: entity.ReviewSortNewest})},
+		{name: "from another order", sort: entity.ReviewSortRatingAsc, cursor: encodeCursor(entity.ReviewCursor{Sort: entity.ReviewSortNewest, ID: "x"})},
+		{name: "from the default order", sort: entity.ReviewSortOldest, cursor: encodeCursor(entity.ReviewCursor{Sort: entity.ReviewSortNewest, ID: "x"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := reader.ListReviews(context.Background(), ListReviewsInput{Sort: tt.sort, Cursor: tt.cursor})
+			assert.ErrorIs(t, err, ErrInvalidReviewQuery)
+		})
+	}
+}
+
+func TestListReviewsQuery(t *testing.T) {
+	reviews := make([]entity.Review, 0, 150)
+	for i := range 150 {
+		reviews = append(reviews, entity.Review{ID: fmt.Sprintf("%03d", i), Rating: i%5 + 1})
+	}
+	reader := NewReviewReader(&memoryReviews{reviews: reviews}, nil)
+	tests := []struct {
+		name    string
+		input   ListReviewsInput
+		want    int
+		wantErr bool
+	}{
+		{name: "default page size", input: ListReviewsInput{}, want: defaultReviewPageSize},
+		{name: "page size capped", input: ListReviewsInput{Limit: 1000}, want: maxReviewPageSize},
+		{name: "negative page size", input: ListReviewsInput{Limit: -1}, wantErr: true},
+		{name: "unknown sort", input: ListReviewsInput{Sort: "name"}, wantErr: true},
+		{name: "rating out of range", input: ListReviewsInput{Filter: entity.ReviewFilter{MinRating: 6}}, wantErr: true},
+		{name: "unknown sentiment", input: ListReviewsInput{Sentiment: "angry"}, wantErr: true},
+		{name: "sentiment", input: ListReviewsInput{Sentiment: entity.SentimentNeutral, Limit: 100}, want: 30},
+		{name: "sentiment outside the rating range", input: ListReviewsInput{Sentiment: entity.SentimentPositive, Filter: entity.ReviewFilter{MaxRating: 2}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := reader.ListReviews(context.Background(), tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidReviewQuery)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, page.Reviews, tt.want)
+		})
+	}
+}
@@ -2,9 +2,20 @@ package usecase
 
 import (
 	"context"
+	"errors"
+
 	"smb-chatbot/internal/entity"
 )
 
+var ErrReviewNotFound = errors.New("review not found")
+
 type ReviewRepository interface {
 	Save(ctx context.Context, review *entity.Review) error
+	FindByID(ctx context.Context, id string) (*entity.Review, error)
+	// List returns up to limit reviews matching filter in the given order,
+	// starting after the cursor position if after is set.
+	List(ctx context.Context, filter entity.ReviewFilter, sort string, after *entity.ReviewCursor, limit int) ([]entity.Review, error)
+	// Each calls fn for every review matching filter in the given order and
+	// stops at the first error fn returns.
+	Each(ctx context.Context, filter entity.ReviewFilter, sort string, fn func(entity.Review) error) error
 }
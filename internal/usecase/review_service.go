@@ -2,8 +2,10 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,12 +17,23 @@ import (
 
 const chatHistoryLimit = 10
 
+// photoOnlyText stands in for the text of a message that only carries photos.
+const photoOnlyText = "[Photo]"
+
+// reviewRequestOptions are offered whenever the bot asks for a review.
+var reviewRequestOptions = entity.ReviewRequestQuickReplies()
+
 type reviewUseCase struct {
 	reviewRepo   ReviewRepository
 	convoRepo    ConversationRepository
 	historyRepo  HistoryRepository
 	messenger    MessengerClient
 	openaiClient *openai.Client
+	promoter     ReviewPromoter
+	locker       ChatLocker
+	txManager    TxManager
+	processed    ProcessedMessageRepository
+	attachments  AttachmentService
 }
 
 func NewReviewUseCase(
@@ -29,6 +42,11 @@ func NewReviewUseCase(
 	hr HistoryRepository,
 	mc MessengerClient,
 	oaiClient *openai.Client,
+	promoter ReviewPromoter,
+	locker ChatLocker,
+	tm TxManager,
+	pmr ProcessedMessageRepository,
+	as AttachmentService,
 ) ReviewUseCase {
 	uc := &reviewUseCase{
 		reviewRepo:   rr,
@@ -36,6 +54,11 @@ func NewReviewUseCase(
 		historyRepo:  hr,
 		messenger:    mc,
 		openaiClient: oaiClient,
+		promoter:     promoter,
+		locker:       locker,
+		txManager:    tm,
+		processed:    pmr,
+		attachments:  as,
 	}
 	return uc
 }
@@ -88,21 +111,65 @@ func (uc *reviewUseCase) getChatGPTAnalysis(ctx context.Context, chatID int64, p
 }
 
 func (uc *reviewUseCase) HandleMessage(ctx context.Context, input HandleMessageInput) (string, error) {
+	unlock, err := uc.locker.Lock(ctx, input.ChatID)
+	if err != nil {
+		log.Printf("Could not lock chat %d for processing: %v", input.ChatID, err)
+		return "", fmt.Errorf("failed to lock chat: %w", err)
+	}
+	defer unlock()
+
+	if input.MessageID != "" {
+		processed, err := uc.processed.Find(ctx, input.ChatID, input.MessageID)
+		if err == nil {
+			log.Printf("Message %s for chat %d was already processed, replaying stored reply", input.MessageID, input.ChatID)
+			return processed.Reply, nil
+		}
+		if !errors.Is(err, ErrProcessedMessageNotFound) {
+			return "", fmt.Errorf("failed to check for duplicate message: %w", err)
+		}
+	}
+
 	conversation, err := uc.convoRepo.FindByChatID(ctx, input.ChatID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get conversation state: %w", err)
 	}
+	// A chat ID from another channel names a different customer; replying
+	// would hand them this conversation.
+	if input.Channel != "" && conversation.Channel != "" && input.Channel != conversation.Channel {
+		log.Printf("WARN: Rejected '%s' message for chat %d, which is on '%s'", input.Channel, input.ChatID, conversation.Channel)
+		return "", fmt.Errorf("%w: chat %d is on '%s'", ErrChannelMismatch, input.ChatID, conversation.Channel)
+	}
+	if input.Channel == entity.ChannelAPI {
+		input.Channel = ""
+	}
+
+	// Only client-supplied IDs are checked; importMedia adds trusted ones.
+	if err := uc.CheckAttachments(ctx, input.ChatID, input.AttachmentIDs); err != nil {
+		return "", err
+	}
+	if err := uc.importMedia(ctx, &input); err != nil {
+		return "", err
+	}
+	photoOnly := strings.TrimSpace(input.Text) == "" && len(input.AttachmentIDs) > 0
+	if photoOnly {
+		input.Text = photoOnlyText
+	}
 	if conversation.UserID == 0 && input.UserID != 0 {
 		conversation.UserID = input.UserID
 	}
+	if input.Channel != "" {
+		conversation.Channel = input.Channel
+	}
 	conversation.LastInteractionAt = time.Now()
+	conversation.LastInboundAt = conversation.LastInteractionAt
 
 	currentState := conversation.State
 
 	var actionError error
 	newState := currentState
 	var assistantResponse string
-	saveUserMessage := true
+	var quickReplies []entity.QuickReply
+	var review *entity.Review
 
 	switch currentState {
 	case entity.StateIdle:
@@ -112,7 +179,6 @@ func (uc *reviewUseCase) HandleMessage(ctx context.Context, input HandleMessageI
 				"Respond with only 'YES' or 'NO'. Message: '%s'", input.Text,
 		)
 		triggerAnalysis, analysisErr := uc.getChatGPTAnalysis(ctx, input.ChatID, analysisPrompt)
-		saveUserMessage = false
 
 		if analysisErr != nil {
 			assistantResponse, err = uc.getChatGPTResponse(ctx, input.ChatID, fmt.Sprintf("The user said: '%s'. Respond conversationally.", input.Text))
@@ -120,16 +186,15 @@ func (uc *reviewUseCase) HandleMessage(ctx context.Context, input HandleMessageI
 				actionError = err
 				assistantResponse = "Sorry, I couldn't process that."
 			}
-			saveUserMessage = true
 		} else if triggerAnalysis == "YES" {
 			newState = entity.StateAwaitingReview
+			quickReplies = reviewRequestOptions
 			reviewRequestPrompt := "The user's last message indicated satisfaction. Ask them politely if they would be willing to leave a quick review about their experience."
 			assistantResponse, err = uc.getChatGPTResponse(ctx, input.ChatID, reviewRequestPrompt)
 			if err != nil {
 				actionError = err
 				assistantResponse = "We appreciate that! Would you mind leaving a review?"
 			}
-			saveUserMessage = true
 		} else {
 			normalReplyPrompt := fmt.Sprintf("The user said: '%s'. Respond conversationally.", input.Text)
 			assistantResponse, err = uc.getChatGPTResponse(ctx, input.ChatID, normalReplyPrompt)
@@ -137,51 +202,85 @@ func (uc *reviewUseCase) HandleMessage(ctx context.Context, input HandleMessageI
 				actionError = err
 				assistantResponse = "Sorry, I couldn't process that."
 			}
-			saveUserMessage = true
 		}
 
 	case entity.StateAwaitingReview:
-		analysisPrompt := fmt.Sprintf(
-			"Analyze the following user message. Does it appear to be a genuine attempt at providing review feedback "+
-				"(positive, negative, or neutral), rather than asking a question, changing the subject, or refusing? "+
-				"Respond with only 'YES' or 'NO'. Message: '%s'", input.Text,
-		)
-		reviewAnalysis, analysisErr := uc.getChatGPTAnalysis(ctx, input.ChatID, analysisPrompt)
-		saveUserMessage = true
+		if input.Payload == "" {
+			// Text-only channels answer the quick replies by number or title.
+			if qr, ok := entity.MatchQuickReply(reviewRequestOptions, input.Text); ok {
+				input.Payload = qr.Payload
+			}
+		}
 
-		if analysisErr != nil {
-			repromptPrompt := "There was an issue processing your previous message. Could you please provide your feedback on the experience?"
-			assistantResponse, err = uc.getChatGPTResponse(ctx, input.ChatID, repromptPrompt)
+		stars, rated := entity.ParseRatingPayload(input.Payload)
+		switch {
+		case rated:
+			log.Printf("Customer rated chat %d with %d stars", input.ChatID, stars)
+			newState = entity.StateIdle
+			review, actionError = uc.newReview(ctx, input, stars)
+			thankPrompt := fmt.Sprintf("The user rated their experience %d out of 5 stars. Thank them for the rating. "+
+				"If the rating is 3 or lower, also apologize and invite them to tell us what we could do better.", stars)
+			assistantResponse, err = uc.getChatGPTResponse(ctx, input.ChatID, thankPrompt)
 			if err != nil {
 				actionError = err
-				assistantResponse = "Could you please provide your review?"
+				assistantResponse = "Thanks for your rating!"
 			}
-		} else if reviewAnalysis == "YES" {
-			log.Printf("ChatGPT analysis suggests input is a review for chat %d", input.ChatID)
-			actionError = uc.saveReview(ctx, input, conversation)
-			if actionError == nil {
-				newState = entity.StateIdle
-				thankPrompt := "The user provided a review. Thank them for their feedback."
-				assistantResponse, err = uc.getChatGPTResponse(ctx, input.ChatID, thankPrompt)
+
+		case photoOnly:
+			// The photo waits for the review that follows; rating it is left to the customer.
+			log.Printf("Customer sent %d photo(s) without a review for chat %d", len(input.AttachmentIDs), input.ChatID)
+			quickReplies = reviewRequestOptions
+			assistantResponse = "Thanks for the photo! How would you rate your experience?"
+
+		case input.Payload == entity.PayloadNotNow:
+			log.Printf("Customer declined to review for chat %d", input.ChatID)
+			newState = entity.StateIdle
+			assistantResponse = "No problem! Thanks for chatting with us, and let us know if there's anything else we can help with."
+
+		default:
+			analysisPrompt := fmt.Sprintf(
+				"Analyze the following user message. Does it appear to be a genuine attempt at providing review feedback "+
+					"(positive, negative, or neutral), rather than asking a question, changing the subject, or refusing? "+
+					"Respond with only 'YES' or 'NO'. Message: '%s'", input.Text,
+			)
+			reviewAnalysis, analysisErr := uc.getChatGPTAnalysis(ctx, input.ChatID, analysisPrompt)
+
+			if analysisErr != nil {
+				quickReplies = reviewRequestOptions
+				repromptPrompt := "There was an issue processing your previous message. Could you please provide your feedback on the experience?"
+				assistantResponse, err = uc.getChatGPTResponse(ctx, input.ChatID, repromptPrompt)
 				if err != nil {
 					actionError = err
-					assistantResponse = "Thanks for your feedback!"
+					assistantResponse = "Could you please provide your review?"
+				}
+			} else if reviewAnalysis == "YES" {
+				log.Printf("ChatGPT analysis suggests input is a review for chat %d", input.ChatID)
+				review, actionError = uc.newReview(ctx, input, 0)
+				if actionError == nil {
+					newState = entity.StateIdle
+					thankPrompt := "The user provided a review. Thank them for their feedback."
+					assistantResponse, err = uc.getChatGPTResponse(ctx, input.ChatID, thankPrompt)
+					if err != nil {
+						actionError = err
+						assistantResponse = "Thanks for your feedback!"
+					}
+				} else {
+					errorPrompt := "There was an error saving the user's review. Apologize and say we'll look into it."
+					assistantResponse, err = uc.getChatGPTResponse(ctx, input.ChatID, errorPrompt)
+					if err != nil {
+						actionError = err
+						assistantResponse = "Sorry, there was an error saving your review."
+					}
+					newState = entity.StateIdle
 				}
 			} else {
-				errorPrompt := "There was an error saving the user's review. Apologize and say we'll look into it."
-				assistantResponse, err = uc.getChatGPTResponse(ctx, input.ChatID, errorPrompt)
+				quickReplies = reviewRequestOptions
+				repromptPrompt := "That doesn't seem like review feedback. Could you please share your thoughts on your experience with us? If you don't want to leave feedback right now, just let me know."
+				assistantResponse, err = uc.getChatGPTResponse(ctx, input.ChatID, repromptPrompt)
 				if err != nil {
 					actionError = err
-					assistantResponse = "Sorry, there was an error saving your review."
+					assistantResponse = "Could you please provide your review?"
 				}
-				newState = entity.StateIdle
-			}
-		} else {
-			repromptPrompt := "That doesn't seem like review feedback. Could you please share your thoughts on your experience with us? If you don't want to leave feedback right now, just let me know."
-			assistantResponse, err = uc.getChatGPTResponse(ctx, input.ChatID, repromptPrompt)
-			if err != nil {
-				actionError = err
-				assistantResponse = "Could you please provide your review?"
 			}
 		}
 
@@ -192,60 +291,127 @@ func (uc *reviewUseCase) HandleMessage(ctx context.Context, input HandleMessageI
 		if err != nil {
 			assistantResponse = "Let's start over."
 		}
-		saveUserMessage = true
 	}
 
-	if saveUserMessage {
+	// Everything the turn writes is committed together, so a failure never
+	// leaves a review saved while the conversation still awaits one.
+	txErr := uc.txManager.WithinTx(ctx, func(ctx context.Context) error {
 		userEntry := entity.HistoryEntry{
 			IsUserMessage: true,
 			Text:          input.Text,
 			Timestamp:     time.Now(),
 		}
-		if histErr := uc.historyRepo.SaveHistoryEntry(ctx, input.ChatID, userEntry); histErr != nil {
-			log.Printf("ERROR: Failed to save user message history for chat %d: %v", input.ChatID, histErr)
-			if actionError == nil {
-				actionError = fmt.Errorf("failed to save user message: %w", histErr)
-			}
+		if err := uc.historyRepo.SaveHistoryEntry(ctx, input.ChatID, userEntry); err != nil {
+			return fmt.Errorf("failed to save user message: %w", err)
 		}
-	}
 
-	if assistantResponse != "" {
-		sendErr := uc.messenger.SendMessage(ctx, input.ChatID, assistantResponse)
-		if sendErr != nil {
-			log.Printf("ERROR sending assistant message to chat %d: %v", input.ChatID, sendErr)
-			if actionError == nil {
-				actionError = fmt.Errorf("failed to send response message: %w", sendErr)
+		if review != nil {
+			if err := uc.reviewRepo.Save(ctx, review); err != nil {
+				return fmt.Errorf("failed to save review: %w", err)
 			}
-		} else {
-			assistantEntry := entity.HistoryEntry{
-				IsUserMessage: false,
-				Text:          assistantResponse,
-				Timestamp:     time.Now(),
+			if uc.attachments != nil {
+				attachments, err := uc.attachments.ClaimPending(ctx, input.ChatID, input.AttachmentIDs, review.ID)
+				if err != nil {
+					return fmt.Errorf("failed to attach photos to review: %w", err)
+				}
+				review.Attachments = attachments
 			}
-			if histErr := uc.historyRepo.SaveHistoryEntry(ctx, input.ChatID, assistantEntry); histErr != nil {
-				log.Printf("ERROR: Failed to save assistant message history for chat %d: %v", input.ChatID, histErr)
+		}
+
+		if newState != currentState {
+			conversation.State = newState
+			conversation.ReminderSentAt = time.Time{}
+		}
+		if err := uc.convoRepo.Save(ctx, conversation); err != nil {
+			return fmt.Errorf("failed to save conversation state: %w", err)
+		}
+
+		if input.MessageID != "" {
+			processed := &entity.ProcessedMessage{
+				ChatID:      input.ChatID,
+				MessageID:   input.MessageID,
+				Reply:       assistantResponse,
+				ProcessedAt: time.Now(),
+			}
+			if err := uc.processed.Save(ctx, processed); err != nil {
+				return fmt.Errorf("failed to record processed message: %w", err)
 			}
 		}
+
+		if assistantResponse == "" {
+			return nil
+		}
+		// The messenger queues the reply in this transaction; it is only
+		// delivered once the whole turn has committed.
+		reply := entity.OutboundMessage{Text: assistantResponse, QuickReplies: quickReplies}
+		messageID, err := uc.messenger.SendRichMessage(ctx, input.ChatID, reply)
+		if err != nil {
+			return fmt.Errorf("failed to send response message: %w", err)
+		}
+		assistantEntry := entity.HistoryEntry{
+			IsUserMessage: false,
+			Text:          assistantResponse,
+			Timestamp:     time.Now(),
+			MessageID:     messageID,
+		}
+		if err := uc.historyRepo.SaveHistoryEntry(ctx, input.ChatID, assistantEntry); err != nil {
+			return fmt.Errorf("failed to save assistant message: %w", err)
+		}
+		return nil
+	})
+	if txErr != nil {
+		log.Printf("ERROR: Rolled back turn for chat %d: %v", input.ChatID, txErr)
+		return "", txErr
 	}
 
-	if newState != currentState {
-		conversation.State = newState
-		saveErr := uc.convoRepo.Save(ctx, conversation)
-		if saveErr != nil {
-			if actionError == nil {
-				actionError = fmt.Errorf("failed to save conversation state: %w", saveErr)
+	if review != nil {
+		log.Printf("Saved review %s from customer %d (rating %d)", review.ID, input.UserID, review.Rating)
+		if uc.promoter != nil {
+			if promoteErr := uc.promoter.PromoteReview(ctx, review); promoteErr != nil {
+				log.Printf("ERROR promoting review %s to public platforms for chat %d: %v", review.ID, input.ChatID, promoteErr)
 			}
 		}
-	} else if actionError == nil {
-		saveErr := uc.convoRepo.Save(ctx, conversation)
-		if saveErr != nil {
-			log.Printf("ERROR saving conversation timestamp update for chat %d: %v", input.ChatID, saveErr)
-		}
 	}
 
 	return assistantResponse, actionError
 }
 
+func (uc *reviewUseCase) CheckAttachments(ctx context.Context, chatID int64, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if uc.attachments == nil {
+		return fmt.Errorf("%w: attachments are disabled", ErrInvalidAttachment)
+	}
+	return uc.attachments.CheckPending(ctx, chatID, ids)
+}
+
+// importMedia downloads the photos of the message from its channel and adds
+// them to input.AttachmentIDs. Photos that are too large or not images are
+// skipped; download failures fail the message so it is retried.
+func (uc *reviewUseCase) importMedia(ctx context.Context, input *HandleMessageInput) error {
+	if len(input.Media) == 0 {
+		return nil
+	}
+	if uc.attachments == nil {
+		log.Printf("WARN: Ignoring %d photo(s) for chat %d, attachments are disabled", len(input.Media), input.ChatID)
+		return nil
+	}
+	for _, media := range input.Media {
+		attachment, err := uc.attachments.Import(ctx, input.ChatID, input.Channel, media)
+		if errors.Is(err, ErrAttachmentTooLarge) || errors.Is(err, ErrUnsupportedAttachment) {
+			log.Printf("WARN: Skipping photo '%s' for chat %d: %v", media.Ref, input.ChatID, err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to import photo: %w", err)
+		}
+		input.AttachmentIDs = append(input.AttachmentIDs, attachment.ID)
+	}
+	input.Media = nil
+	return nil
+}
+
 func (uc *reviewUseCase) getChatGPTResponse(ctx context.Context, chatID int64, prompt string) (string, error) {
 	history, err := uc.historyRepo.GetHistory(ctx, chatID, chatHistoryLimit)
 	if err != nil {
@@ -296,27 +462,44 @@ func (uc *reviewUseCase) getChatGPTResponse(ctx context.Context, chatID int64, p
 	return aiResponse, nil
 }
 
-func (uc *reviewUseCase) saveReview(ctx context.Context, input HandleMessageInput, conversation *entity.Conversation) error {
-	reviewID, err := uuid.NewRandom()
+func (uc *reviewUseCase) rateReview(ctx context.Context, chatID int64, text string) int {
+	ratingPrompt := fmt.Sprintf(
+		"Rate the following customer review on a scale from 1 (very negative) to 5 (very positive). "+
+			"Respond with only the number. Review: '%s'", text,
+	)
+	result, err := uc.getChatGPTAnalysis(ctx, chatID, ratingPrompt)
 	if err != nil {
-		log.Printf("ERROR generating UUID for review: %v", err)
-		return fmt.Errorf("failed to generate review id: %w", err)
+		log.Printf("WARN: Failed to rate review for chat %d: %v. Saving it unrated.", chatID, err)
+		return 0
 	}
-
-	review := &entity.Review{
-		ID:         reviewID.String(),
-		CustomerID: input.UserID,
-		ChatID:     input.ChatID,
-		Text:       input.Text,
-		ReceivedAt: time.Now(),
+	rating, err := strconv.Atoi(strings.Trim(result, ". "))
+	if err != nil || rating < 1 || rating > 5 {
+		log.Printf("WARN: Unexpected rating '%s' for chat %d. Saving review unrated.", result, chatID)
+		return 0
 	}
+	return rating
+}
 
-	err = uc.reviewRepo.Save(ctx, review)
+// newReview builds a review from the customer's message. A rating of 0 lets
+// the LLM rate the text.
+func (uc *reviewUseCase) newReview(ctx context.Context, input HandleMessageInput, rating int) (*entity.Review, error) {
+	reviewID, err := uuid.NewRandom()
 	if err != nil {
-		log.Printf("ERROR saving review for customer %d: %v\n", input.UserID, err)
-		return fmt.Errorf("failed to save review: %w", err)
+		log.Printf("ERROR generating UUID for review: %v", err)
+		return nil, fmt.Errorf("failed to generate review id: %w", err)
+	}
+	if rating == 0 {
+		rating = uc.rateReview(ctx, input.ChatID, input.Text)
 	}
-	log.Printf("Saved review %s from customer %d\n", review.ID, input.UserID)
 
-	return nil
+	return &entity.Review{
+		ID:           reviewID.String(),
+		CustomerID:   input.UserID,
+		CustomerName: input.UserName,
+		ChatID:       input.ChatID,
+		Channel:      input.Channel,
+		Text:         input.Text,
+		Rating:       rating,
+		ReceivedAt:   time.Now(),
+	}, nil
 }
@@ -1,14 +1,41 @@
 package usecase
 
-import "context"
+import (
+	"context"
+	"errors"
+
+	"smb-chatbot/internal/entity"
+)
+
+// ErrChannelMismatch rejects a message from another channel than the one the
+// chat belongs to. Retrying it cannot succeed.
+var ErrChannelMismatch = errors.New("chat belongs to another channel")
 
 type HandleMessageInput struct {
 	ChatID   int64  `json:"chat_id"`
 	UserID   int64  `json:"user_id"`
 	UserName string `json:"user_name"`
 	Text     string
+	// MessageID optionally identifies the message on the client side. A
+	// message with an already processed ID is answered with the stored reply.
+	MessageID string `json:"message_id"`
+	// Channel is the messenger the message arrived on; replies are routed
+	// back to it. Empty keeps the conversation's current channel, and
+	// entity.ChannelAPI only admits chats that no messenger claimed.
+	Channel string `json:"channel,omitempty"`
+	// Payload is the payload of the quick reply the customer tapped; Text
+	// then holds its title.
+	Payload string `json:"payload,omitempty"`
+	// AttachmentIDs are photos already stored through the AttachmentService,
+	// Media are photos still to be downloaded from the channel. Text may be
+	// empty when either is set.
+	AttachmentIDs []string              `json:"attachment_ids,omitempty"`
+	Media         []entity.InboundMedia `json:"media,omitempty"`
 }
 
 type ReviewUseCase interface {
 	HandleMessage(ctx context.Context, input HandleMessageInput) (string, error)
+	// CheckAttachments returns ErrInvalidAttachment unless ids are photos
+	// uploaded to the chat that belong to no review yet.
+	CheckAttachments(ctx context.Context, chatID int64, ids []string) error
 }
@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	optOutConfirmation = "You have been unsubscribed and will not receive any more messages. Reply START to resubscribe."
+	optInConfirmation  = "You have been resubscribed. Reply STOP at any time to unsubscribe."
+)
+
+// Standard carrier keywords, matched against the whole trimmed message.
+var (
+	optOutKeywords = map[string]bool{"STOP": true, "STOPALL": true, "UNSUBSCRIBE": true, "CANCEL": true, "END": true, "QUIT": true}
+	optInKeywords  = map[string]bool{"START": true, "UNSTOP": true, "YES": true}
+)
+
+// SubscriptionService handles STOP/START style keywords on channels that
+// require them, such as SMS.
+type SubscriptionService interface {
+	// HandleKeyword reports whether text was a subscription keyword and, if
+	// so, the confirmation to send back. Other messages are left untouched.
+	HandleKeyword(ctx context.Context, channel string, chatID int64, text string) (reply string, handled bool, err error)
+}
+
+type subscriptionService struct {
+	optOutRepo OptOutRepository
+}
+
+func NewSubscriptionService(or OptOutRepository) SubscriptionService {
+	return &subscriptionService{optOutRepo: or}
+}
+
+func (s *subscriptionService) HandleKeyword(ctx context.Context, channel string, chatID int64, text string) (string, bool, error) {
+	keyword := strings.ToUpper(strings.TrimSpace(text))
+
+	switch {
+	case optOutKeywords[keyword]:
+		if err := s.optOutRepo.OptOut(ctx, channel, chatID); err != nil {
+			return "", true, fmt.Errorf("failed to opt out chat %d: %w", chatID, err)
+		}
+		log.Printf("Chat %d opted out of %s messages", chatID, channel)
+		return optOutConfirmation, true, nil
+	case optInKeywords[keyword]:
+		optedOut, err := s.optOutRepo.IsOptedOut(ctx, channel, chatID)
+		if err != nil {
+			return "", true, fmt.Errorf("failed to check opt-out of chat %d: %w", chatID, err)
+		}
+		// "YES" is an ordinary answer unless the customer is unsubscribed.
+		if !optedOut {
+			return "", false, nil
+		}
+		if err := s.optOutRepo.OptIn(ctx, channel, chatID); err != nil {
+			return "", true, fmt.Errorf("failed to opt in chat %d: %w", chatID, err)
+		}
+		log.Printf("Chat %d opted back in to %s messages", chatID, channel)
+		return optInConfirmation, true, nil
+	default:
+		return "", false, nil
+	}
+}
@@ -0,0 +1,10 @@
+package usecase
+
+import "context"
+
+// TxManager runs a unit of work atomically. Repository calls made with the
+// context passed to fn take part in the transaction; if fn returns an error
+// every write is rolled back.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
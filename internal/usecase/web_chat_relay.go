@@ -0,0 +1,16 @@
+package usecase
+
+import "context"
+
+// WebChatRelay carries web chat messages to every app instance, as a
+// customer's WebSocket may be connected to another instance than the one
+// delivering the message.
+type WebChatRelay interface {
+	// Publish announces the outbox message messageID of the chat to all
+	// instances once the surrounding transaction commits.
+	Publish(ctx context.Context, chatID int64, messageID string) error
+	// Listen passes the messages published by any instance to deliver until
+	// ctx ends. ready is called whenever listening (re)starts: messages
+	// published before may have been missed.
+	Listen(ctx context.Context, ready func(), deliver func(chatID int64, messageID string))
+}
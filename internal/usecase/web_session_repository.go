@@ -0,0 +1,12 @@
+package usecase
+
+import (
+	"context"
+
+	"smb-chatbot/internal/entity"
+)
+
+type WebSessionRepository interface {
+	// Create stores a new session and assigns its ChatID.
+	Create(ctx context.Context, session *entity.WebSession) error
+}
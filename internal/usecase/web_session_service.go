@@ -0,0 +1,33 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"smb-chatbot/internal/entity"
+)
+
+// WebSessionService gives anonymous web chat visitors an identity, so they
+// cannot choose the chat they write to.
+type WebSessionService interface {
+	Start(ctx context.Context) (*entity.WebSession, error)
+}
+
+type webSessionService struct {
+	sessionRepo WebSessionRepository
+}
+
+func NewWebSessionService(wsr WebSessionRepository) WebSessionService {
+	return &webSessionService{sessionRepo: wsr}
+}
+
+func (s *webSessionService) Start(ctx context.Context) (*entity.WebSession, error) {
+	session := &entity.WebSession{CreatedAt: time.Now()}
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	session.UserID = session.ChatID
+	log.Printf("Started web session for chat %d", session.ChatID)
+	return session, nil
+}
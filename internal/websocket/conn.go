@@ -0,0 +1,295 @@
+// Package websocket is a minimal RFC 6455 server implementation covering
+// what the web chat needs: text messages, fragmentation, ping/pong and close.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Close status codes used by the server.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+)
+
+// ErrClosed is returned by ReadMessage once the peer closed the connection.
+var ErrClosed = errors.New("websocket closed")
+
+// Conn is a server side WebSocket connection. Reads must happen from a single
+// goroutine; writes are safe for concurrent use.
+type Conn struct {
+	netConn      net.Conn
+	reader       *bufio.Reader
+	writeMu      sync.Mutex
+	maxMessage   int64
+	writeTimeout time.Duration
+	closeOnce    sync.Once
+}
+
+// Upgrade performs the opening handshake and takes over the connection.
+// maxMessage limits the size of a single (reassembled) client message.
+func Upgrade(w http.ResponseWriter, r *http.Request, maxMessage int64) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, errors.New("websocket handshake requires GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "Expected WebSocket upgrade", http.StatusBadRequest)
+		return nil, errors.New("missing websocket upgrade headers")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("invalid websocket key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("response writer does not support hijacking")
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hijack connection: %w", err)
+	}
+
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	if _, err := netConn.Write([]byte(response)); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("failed to write handshake response: %w", err)
+	}
+
+	return &Conn{
+		netConn:      netConn,
+		reader:       rw.Reader,
+		maxMessage:   maxMessage,
+		writeTimeout: 10 * time.Second,
+	}, nil
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text message, answering pings on the way.
+// A read deadline of timeout is applied to each frame.
+func (c *Conn) ReadMessage(timeout time.Duration) (string, error) {
+	var message []byte
+	fragmented := false
+	for {
+		if timeout > 0 {
+			_ = c.netConn.SetReadDeadline(time.Now().Add(timeout))
+		}
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return "", err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return "", err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code := CloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			if len(payload) == 1 || len(payload) >= 2 && (!validCloseCode(code) || !utf8.Valid(payload[2:])) {
+				c.Close(CloseProtocolError, "invalid close frame")
+				return "", errors.New("invalid websocket close frame")
+			}
+			c.Close(code, "")
+			return "", ErrClosed
+		case opBinary:
+			c.Close(CloseUnsupportedData, "binary messages are not supported")
+			return "", errors.New("received binary message")
+		case opText:
+			if fragmented {
+				c.Close(CloseProtocolError, "expected continuation frame")
+				return "", errors.New("unexpected text frame during fragmented message")
+			}
+			fragmented = !fin
+		case opContinuation:
+			if !fragmented {
+				c.Close(CloseProtocolError, "unexpected continuation frame")
+				return "", errors.New("unexpected continuation frame")
+			}
+		default:
+			c.Close(CloseProtocolError, "unknown opcode")
+			return "", fmt.Errorf("unknown opcode %d", opcode)
+		}
+
+		if int64(len(message)+len(payload)) > c.maxMessage {
+			c.Close(CloseMessageTooBig, "message too big")
+			return "", errors.New("websocket message too big")
+		}
+		message = append(message, payload...)
+		if fin {
+			if !utf8.Valid(message) {
+				c.Close(CloseProtocolError, "invalid UTF-8")
+				return "", errors.New("websocket text message is not valid UTF-8")
+			}
+			return string(message), nil
+		}
+	}
+}
+
+// validCloseCode reports whether a peer may send code in a close frame.
+// 1005, 1006 and 1015 only report closes that had no close frame.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	default:
+		return code >= 3000 && code <= 4999
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.reader, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0F
+	if head[0]&0x70 != 0 {
+		c.Close(CloseProtocolError, "reserved bits set")
+		return false, 0, nil, errors.New("websocket frame uses reserved bits")
+	}
+	// Clients must mask every frame.
+	if head[1]&0x80 == 0 {
+		c.Close(CloseProtocolError, "frame not masked")
+		return false, 0, nil, errors.New("unmasked client frame")
+	}
+
+	length := int64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if opcode >= opClose && (length > 125 || !fin) {
+		c.Close(CloseProtocolError, "invalid control frame")
+		return false, 0, nil, errors.New("invalid control frame")
+	}
+	if length < 0 || length > c.maxMessage {
+		c.Close(CloseMessageTooBig, "message too big")
+		return false, 0, nil, errors.New("websocket frame too big")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage sends a text message.
+func (c *Conn) WriteMessage(text string) error {
+	return c.writeFrame(opText, []byte(text))
+}
+
+// Ping sends a ping; the client's pong refreshes the read deadline.
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, payload...)
+
+	_ = c.netConn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	_, err := c.netConn.Write(frame)
+	return err
+}
+
+// Close sends a close frame and closes the connection. It is safe to call
+// more than once.
+func (c *Conn) Close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+		if len(reason) > 120 {
+			reason = reason[:120]
+		}
+		payload = append(payload, reason...)
+		_ = c.writeFrame(opClose, payload)
+		_ = c.netConn.Close()
+	})
+}
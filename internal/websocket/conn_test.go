@@ -0,0 +1,318 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMaxMessage = 64
+
+// frame encodes a client frame, masked unless unmasked is set.
+type frame struct {
+	fin      bool
+	rsv      byte
+	opcode   byte
+	payload  []byte
+	unmasked bool
+	// length overrides the payload length in the header.
+	length uint64
+}
+
+func (f frame) encode() []byte {
+	head := f.opcode | f.rsv<<4
+	if f.fin {
+		head |= 0x80
+	}
+	out := []byte{head}
+	maskBit := byte(0x80)
+	if f.unmasked {
+		maskBit = 0
+	}
+	length := uint64(len(f.payload))
+	if f.length > 0 {
+		length = f.length
+	}
+	switch {
+	case length < 126:
+		out = append(out, maskBit|byte(length))
+	case length <= 0xFFFF:
+		out = append(out, maskBit|126)
+		out = binary.BigEndian.AppendUint16(out, uint16(length))
+	default:
+		out = append(out, maskBit|127)
+		out = binary.BigEndian.AppendUint64(out, length)
+	}
+	if f.unmasked {
+		return append(out, f.payload...)
+	}
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	out = append(out, mask[:]...)
+	for i, b := range f.payload {
+		out = append(out, b^mask[i%4])
+	}
+	return out
+}
+
+func text(s string) frame { return frame{fin: true, opcode: opText, payload: []byte(s)} }
+func closeFrame(payload ...byte) frame {
+	return frame{fin: true, opcode: opClose, payload: payload}
+}
+
+func closePayload(code int, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
+
+// serverFrame is a frame the server sent, which is never masked.
+type serverFrame struct {
+	opcode  byte
+	payload []byte
+}
+
+func readServerFrames(t *testing.T, r *bufio.Reader) []serverFrame {
+	t.Helper()
+	var frames []serverFrame
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return frames
+		}
+		require.Zero(t, head[1]&0x80, "server frames must not be masked")
+		length := int(head[1] & 0x7F)
+		switch length {
+		case 126:
+			var ext [2]byte
+			_, _ = io.ReadFull(r, ext[:])
+			length = int(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			_, _ = io.ReadFull(r, ext[:])
+			length = int(binary.BigEndian.Uint64(ext[:]))
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return frames
+		}
+		frames = append(frames, serverFrame{opcode: head[0] & 0x0F, payload: payload})
+	}
+}
+
+// readResult is what ReadMessage returned on the server.
+type readResult struct {
+	messages []string
+	err      error
+}
+
+// exchange opens a WebSocket to a server that reads messages until an error,
+// sends frames and returns what the server read and sent back.
+func exchange(t *testing.T, frames []frame) (readResult, []serverFrame) {
+	t.Helper()
+	results := make(chan readResult, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, testMaxMessage)
+		if err != nil {
+			results <- readResult{err: err}
+			return
+		}
+		var result readResult
+		for {
+			message, err := conn.ReadMessage(5 * time.Second)
+			if err != nil {
+				result.err = err
+				conn.Close(CloseNormal, "")
+				break
+			}
+			result.messages = append(result.messages, message)
+		}
+		results <- result
+	}))
+	defer server.Close()
+
+	netConn, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.NoError(t, err)
+	defer netConn.Close()
+	_ = netConn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	handshake := "GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	_, err = netConn.Write([]byte(handshake))
+	require.NoError(t, err)
+	reader := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	var raw []byte
+	for _, f := range frames {
+		raw = append(raw, f.encode()...)
+	}
+	// The server may close before reading every frame.
+	_, _ = netConn.Write(raw)
+
+	sent := readServerFrames(t, reader)
+	return <-results, sent
+}
+
+// closeCode is the status code of the close frame among frames, or 0.
+func closeCode(frames []serverFrame) int {
+	for _, f := range frames {
+		if f.opcode == opClose && len(f.payload) >= 2 {
+			return int(binary.BigEndian.Uint16(f.payload))
+		}
+	}
+	return 0
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		name         string
+		frames       []frame
+		wantMessages []string
+		wantPongs    []string
+		wantClosed   bool
+		wantCode     int
+	}{
+		{name: "text", frames: []frame{text("hello"), closeFrame()},
+			wantMessages: []string{"hello"}, wantClosed: true, wantCode: CloseNormal},
+		{name: "fragmented text", frames: []frame{
+			{opcode: opText, payload: []byte("hel")},
+			{opcode: opContinuation, payload: []byte("l")},
+			{fin: true, opcode: opContinuation, payload: []byte("o")},
+			closeFrame(),
+		}, wantMessages: []string{"hello"}, wantClosed: true, wantCode: CloseNormal},
+		{name: "ping between fragments", frames: []frame{
+			{opcode: opText, payload: []byte("hello ")},
+			{fin: true, opcode: opPing, payload: []byte("p1")},
+			{fin: true, opcode: opContinuation, payload: []byte("world")},
+			closeFrame(),
+		}, wantMessages: []string{"hello world"}, wantPongs: []string{"p1"}, wantClosed: true, wantCode: CloseNormal},
+		{name: "pong is ignored", frames: []frame{{fin: true, opcode: opPong}, text("hi"), closeFrame()},
+			wantMessages: []string{"hi"}, wantClosed: true, wantCode: CloseNormal},
+		{name: "unmasked frame", frames: []frame{{fin: true, opcode: opText, payload: []byte("hi"), unmasked: true}},
+			wantCode: CloseProtocolError},
+		{name: "reserved bits", frames: []frame{{fin: true, rsv: 0x4, opcode: opText, payload: []byte("hi")}},
+			wantCode: CloseProtocolError},
+		{name: "unknown opcode", frames: []frame{{fin: true, opcode: 0x3}},
+			wantCode: CloseProtocolError},
+		{name: "binary", frames: []frame{{fin: true, opcode: opBinary, payload: []byte{1}}},
+			wantCode: CloseUnsupportedData},
+		{name: "oversized 7-bit length", frames: []frame{{fin: true, opcode: opText, length: testMaxMessage + 1}},
+			wantCode: CloseMessageTooBig},
+		{name: "oversized 64-bit length", frames: []frame{{fin: true, opcode: opText, length: 1 << 62}},
+			wantCode: CloseMessageTooBig},
+		{name: "64-bit length with the top bit set", frames: []frame{{fin: true, opcode: opText, length: 1 << 63}},
+			wantCode: CloseMessageTooBig},
+		{name: "fragments over the limit", frames: []frame{
+			{opcode: opText, payload: []byte(strings.Repeat("a", testMaxMessage))},
+			{fin: true, opcode: opContinuation, payload: []byte("a")},
+		}, wantCode: CloseMessageTooBig},
+		{name: "fragmented ping", frames: []frame{{opcode: opPing, payload: []byte("p")}},
+			wantCode: CloseProtocolError},
+		{name: "fragmented close", frames: []frame{{opcode: opClose}},
+			wantCode: CloseProtocolError},
+		{name: "ping over 125 bytes", frames: []frame{{fin: true, opcode: opPing, length: 126}},
+			wantCode: CloseProtocolError},
+		{name: "continuation without start", frames: []frame{{fin: true, opcode: opContinuation, payload: []byte("a")}},
+			wantCode: CloseProtocolError},
+		{name: "text inside fragmented message", frames: []frame{{opcode: opText, payload: []byte("a")}, text("b")},
+			wantCode: CloseProtocolError},
+		{name: "invalid UTF-8", frames: []frame{{fin: true, opcode: opText, payload: []byte{0xff, 0xfe}}},
+			wantCode: CloseProtocolError},
+		{name: "close with code", frames: []frame{closeFrame(closePayload(CloseGoingAway, "bye")...)},
+			wantClosed: true, wantCode: CloseGoingAway},
+		{name: "close with private code", frames: []frame{closeFrame(closePayload(4000, "")...)},
+			wantClosed: true, wantCode: 4000},
+		{name: "close with one byte", frames: []frame{closeFrame(0x03)},
+			wantCode: CloseProtocolError},
+		{name: "close code below 1000", frames: []frame{closeFrame(closePayload(999, "")...)},
+			wantCode: CloseProtocolError},
+		{name: "close code 1005", frames: []frame{closeFrame(closePayload(1005, "")...)},
+			wantCode: CloseProtocolError},
+		{name: "close code 1006", frames: []frame{closeFrame(closePayload(1006, "")...)},
+			wantCode: CloseProtocolError},
+		{name: "reserved close code", frames: []frame{closeFrame(closePayload(2000, "")...)},
+			wantCode: CloseProtocolError},
+		{name: "close code above 4999", frames: []frame{closeFrame(closePayload(5000, "")...)},
+			wantCode: CloseProtocolError},
+		{name: "close reason not UTF-8", frames: []frame{closeFrame(closePayload(CloseNormal, "\xff")...)},
+			wantCode: CloseProtocolError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, sent := exchange(t, tt.frames)
+
+			assert.Equal(t, tt.wantMessages, result.messages)
+			require.Error(t, result.err)
+			assert.Equal(t, tt.wantClosed, errors.Is(result.err, ErrClosed), "got error %v", result.err)
+			assert.Equal(t, tt.wantCode, closeCode(sent))
+
+			var pongs []string
+			for _, f := range sent {
+				if f.opcode == opPong {
+					pongs = append(pongs, string(f.payload))
+				}
+			}
+			assert.Equal(t, tt.wantPongs, pongs)
+		})
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		header     map[string]string
+		wantStatus int
+		wantAccept string
+	}{
+		{
+			name:   "valid",
+			method: http.MethodGet,
+			header: map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "websocket",
+				"Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ=="},
+			wantStatus: http.StatusSwitchingProtocols,
+			// The example from RFC 6455.
+			wantAccept: "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=",
+		},
+		{name: "POST", method: http.MethodPost, header: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket",
+			"Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ=="}, wantStatus: http.StatusMethodNotAllowed},
+		{name: "no upgrade", method: http.MethodGet, header: map[string]string{
+			"Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ=="}, wantStatus: http.StatusBadRequest},
+		{name: "old version", method: http.MethodGet, header: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket",
+			"Sec-WebSocket-Version": "8", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ=="}, wantStatus: http.StatusUpgradeRequired},
+		{name: "short key", method: http.MethodGet, header: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket",
+			"Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "c2hvcnQ="}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if conn, err := Upgrade(w, r, testMaxMessage); err == nil {
+					conn.Close(CloseNormal, "")
+				}
+			}))
+			defer server.Close()
+
+			req, err := http.NewRequest(tt.method, server.URL, nil)
+			require.NoError(t, err)
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantAccept, resp.Header.Get("Sec-WebSocket-Accept"))
+		})
+	}
+}
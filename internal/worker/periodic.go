@@ -0,0 +1,28 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunPeriodic calls task every interval until ctx is cancelled. Errors are
+// logged and do not stop the loop.
+func RunPeriodic(ctx context.Context, name string, interval time.Duration, task func(ctx context.Context) error) {
+	log.Printf("WORKER (%s): Started, running every %s", name, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := task(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("ERROR (%s): %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("WORKER (%s): Stopped", name)
+			return
+		case <-ticker.C:
+		}
+	}
+}
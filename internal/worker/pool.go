@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// RunPool runs size goroutines that call task back to back while it reports
+// work was done, and wait pollInterval when it was not. It returns once ctx is
+// cancelled and every in-flight task has finished.
+func RunPool(ctx context.Context, name string, size int, pollInterval time.Duration, task func(ctx context.Context) (bool, error)) {
+	log.Printf("WORKER (%s): Starting %d workers", name, size)
+
+	var wg sync.WaitGroup
+	for i := 0; i < size; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				worked, err := task(ctx)
+				if err != nil && ctx.Err() == nil {
+					log.Printf("ERROR (%s #%d): %v", name, id, err)
+				}
+				if worked && err == nil {
+					continue
+				}
+				select {
+				case <-ctx.Done():
+				case <-time.After(pollInterval):
+				}
+			}
+		}(i + 1)
+	}
+	wg.Wait()
+	log.Printf("WORKER (%s): Stopped", name)
+}
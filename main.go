@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"smb-chatbot/internal/auth"
+	"smb-chatbot/internal/entity"
+	"smb-chatbot/internal/gateway/blobstore"
 	gwMessenger "smb-chatbot/internal/gateway/messenger"
+	"smb-chatbot/internal/gateway/ratelimit"
 	gwStorage "smb-chatbot/internal/gateway/storage"
 	"smb-chatbot/internal/server"
 	"smb-chatbot/internal/usecase"
+	"smb-chatbot/internal/worker"
 
 	"github.com/joho/godotenv"
 	openai "github.com/sashabaranov/go-openai"
@@ -16,6 +27,9 @@ import (
 func main() {
 	log.Println("Starting SMB Chatbot...")
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("INFO: No .env file found, relying on system environment variables.")
@@ -39,9 +53,111 @@ func main() {
 	reviewRepo := gwStorage.NewReviewRepository(db)
 	convoRepo := gwStorage.NewConversationRepository(db)
 	historyRepo := gwStorage.NewHistoryRepository(db)
+	reviewDestinationRepo := gwStorage.NewReviewDestinationRepository(db)
+	reviewLinkRepo := gwStorage.NewReviewLinkRepository(db)
+	campaignRepo := gwStorage.NewCampaignRepository(db)
+	processedMessageRepo := gwStorage.NewProcessedMessageRepository(db)
+	outboxRepo := gwStorage.NewOutboxRepository(db)
+	inboundQueue := gwStorage.NewInboundQueue(db)
+	optOutRepo := gwStorage.NewOptOutRepository(db)
+	emailThreadRepo := gwStorage.NewEmailThreadRepository(db)
+	txManager := gwStorage.NewTxManager(db)
+	chatLocker := gwStorage.NewChatLocker(db, envDuration("CHAT_LOCK_WAIT", 15*time.Second))
 
-	messengerClient := gwMessenger.NewMockMessengerClient()
-	log.Println("Using Mock Messenger Client.")
+	apiKeyService := usecase.NewAPIKeyService(gwStorage.NewAPIKeyRepository(db))
+	// Web chat tokens, attachment URLs and dashboard tokens share one signer,
+	// so a web chat token also grants access to the chat's photos.
+	var tokenSigner *auth.TokenSigner
+	if secret := envOrDefault("AUTH_TOKEN_SECRET", os.Getenv("WS_TOKEN_SECRET")); secret != "" {
+		tokenSigner = auth.NewTokenSigner(secret)
+	}
+
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "export-reviews":
+			err = runExportReviews(ctx, usecase.NewReviewReader(reviewRepo, nil), os.Args[2:])
+		case "api-keys":
+			err = runAPIKeys(ctx, apiKeyService, os.Args[2:])
+		case "issue-token":
+			err = runIssueToken(tokenSigner, os.Args[2:])
+		default:
+			log.Fatalf("FATAL: Unknown command '%s'", os.Args[1])
+		}
+		if err != nil {
+			log.Fatalf("FATAL: %s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	// MESSENGER_CHANNELS enables any combination of channels; the first one is
+	// the default for conversations started through the HTTP API.
+	channels := envOrDefault("MESSENGER_CHANNELS", envOrDefault("MESSENGER_CHANNEL", entity.ChannelMock))
+	channelRegistry := usecase.NewChannelRegistry()
+
+	var telegramClient *gwMessenger.TelegramClient
+	var whatsAppClient *gwMessenger.WhatsAppClient
+	var twilioCfg gwMessenger.TwilioConfig
+	// mediaFetchers download the photos customers send, by channel.
+	mediaFetchers := map[string]usecase.MediaFetcher{}
+	emailEnabled := false
+	var webSocketHub *gwMessenger.WebSocketHub
+	for _, channel := range strings.Split(channels, ",") {
+		switch channel = strings.TrimSpace(channel); channel {
+		case entity.ChannelMock:
+			channelRegistry.Register(channel, gwMessenger.NewMockMessengerClient())
+		case entity.ChannelTelegram:
+			telegramClient = gwMessenger.NewTelegramClient(requireEnv("TELEGRAM_BOT_TOKEN"), os.Getenv("TELEGRAM_API_BASE_URL"))
+			channelRegistry.Register(channel, telegramClient)
+			mediaFetchers[channel] = telegramClient
+		case entity.ChannelWhatsApp:
+			whatsAppClient = gwMessenger.NewWhatsAppClient(gwMessenger.WhatsAppConfig{
+				AccessToken:      requireEnv("WHATSAPP_ACCESS_TOKEN"),
+				PhoneNumberID:    requireEnv("WHATSAPP_PHONE_NUMBER_ID"),
+				BaseURL:          os.Getenv("WHATSAPP_API_BASE_URL"),
+				ReviewTemplate:   os.Getenv("WHATSAPP_REVIEW_TEMPLATE"),
+				TemplateLanguage: os.Getenv("WHATSAPP_TEMPLATE_LANGUAGE"),
+			}, convoRepo)
+			channelRegistry.Register(channel, whatsAppClient)
+			mediaFetchers[channel] = whatsAppClient
+		case entity.ChannelSMS:
+			twilioCfg = gwMessenger.TwilioConfig{
+				AccountSID:        requireEnv("TWILIO_ACCOUNT_SID"),
+				AuthToken:         requireEnv("TWILIO_AUTH_TOKEN"),
+				FromNumber:        requireEnv("TWILIO_FROM_NUMBER"),
+				BaseURL:           os.Getenv("TWILIO_API_BASE_URL"),
+				StatusCallbackURL: os.Getenv("TWILIO_STATUS_CALLBACK_URL"),
+			}
+			twilioClient := gwMessenger.NewTwilioSMSClient(twilioCfg, optOutRepo)
+			channelRegistry.Register(channel, twilioClient)
+			mediaFetchers[channel] = twilioClient
+		case entity.ChannelEmail:
+			channelRegistry.Register(channel, gwMessenger.NewEmailClient(gwMessenger.SMTPConfig{
+				Host:        requireEnv("SMTP_HOST"),
+				Port:        envInt("SMTP_PORT", 587),
+				Username:    os.Getenv("SMTP_USERNAME"),
+				Password:    os.Getenv("SMTP_PASSWORD"),
+				FromAddress: requireEnv("EMAIL_FROM_ADDRESS"),
+				FromName:    os.Getenv("EMAIL_FROM_NAME"),
+			}, emailThreadRepo))
+			emailEnabled = true
+		case entity.ChannelWebSocket:
+			// Messages are relayed through Postgres, as the instance sending
+			// one may not hold the customer's connection.
+			webSocketHub = gwMessenger.NewWebSocketHub(envDuration("WS_BACKLOG_TTL", time.Hour), gwStorage.NewWebChatRelay(db), outboxRepo)
+			channelRegistry.Register(channel, webSocketHub)
+			go webSocketHub.Run(ctx)
+			go worker.RunPeriodic(ctx, "websocket-backlog-pruner", 10*time.Minute, webSocketHub.Prune)
+		default:
+			log.Fatalf("FATAL: Unknown messenger channel '%s' in MESSENGER_CHANNELS", channel)
+		}
+	}
+	log.Printf("Enabled messenger channels: %s (default '%s')", strings.Join(channelRegistry.Names(), ", "), channelRegistry.Default())
+	deliveryClient := usecase.NewChannelRouter(channelRegistry, convoRepo)
+
+	// Use cases queue outgoing messages in their transaction; the outbox
+	// dispatcher delivers them through the real messenger client.
+	outboxMessenger := usecase.NewOutboxMessenger(outboxRepo)
 
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	if apiKey == "" {
@@ -51,21 +167,191 @@ func main() {
 
 	log.Println("OpenAI client initialized.")
 
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	publicReviewCfg := usecase.PublicReviewConfig{
+		BusinessID:  envOrDefault("BUSINESS_ID", "default"),
+		MinRating:   4,
+		LinkBaseURL: envOrDefault("PUBLIC_BASE_URL", "http://localhost:"+port),
+	}
+	if v := os.Getenv("PUBLIC_REVIEW_MIN_RATING"); v != "" {
+		minRating, err := strconv.Atoi(v)
+		if err != nil || minRating < 1 || minRating > 5 {
+			log.Fatalf("FATAL: PUBLIC_REVIEW_MIN_RATING must be a number between 1 and 5, got '%s'", v)
+		}
+		publicReviewCfg.MinRating = minRating
+	}
+	reviewPromoter := usecase.NewReviewPromoter(reviewDestinationRepo, reviewLinkRepo, historyRepo, outboxMessenger, txManager, publicReviewCfg)
+
+	// Photo attachments are stored below ATTACHMENTS_DIR; without it photos
+	// customers send are ignored.
+	var attachmentService usecase.AttachmentService
+	var attachmentRepo usecase.AttachmentRepository
+	attachmentMaxBytes := int64(envInt("ATTACHMENT_MAX_BYTES", 10<<20))
+	if dir := os.Getenv("ATTACHMENTS_DIR"); dir != "" {
+		blobStore, err := blobstore.NewLocalStore(dir)
+		if err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		attachmentRepo = gwStorage.NewAttachmentRepository(db)
+		attachmentService = usecase.NewAttachmentService(attachmentRepo, blobStore, mediaFetchers, usecase.AttachmentConfig{
+			MaxBytes:         attachmentMaxBytes,
+			MaxPixels:        envInt("ATTACHMENT_MAX_PIXELS", 24_000_000),
+			ThumbnailSize:    320,
+			PendingWindow:    24 * time.Hour,
+			MaxPending:       envInt("ATTACHMENT_MAX_PENDING", 20),
+			PendingRetention: envDuration("ATTACHMENT_PENDING_RETENTION", 72*time.Hour),
+		})
+		log.Printf("Storing photo attachments in %s", dir)
+		go worker.RunPeriodic(ctx, "attachment-pruner", time.Hour, attachmentService.PrunePending)
+	}
+
 	reviewUseCase := usecase.NewReviewUseCase(
 		reviewRepo,
 		convoRepo,
 		historyRepo,
-		messengerClient,
+		outboxMessenger,
 		openaiClient,
+		reviewPromoter,
+		chatLocker,
+		txManager,
+		processedMessageRepo,
+		attachmentService,
 	)
 
-	srv := server.NewServer(reviewUseCase, historyRepo, messengerClient)
+	sweeperInterval := envDuration("SWEEPER_INTERVAL", 5*time.Minute)
+	if sweeperInterval > 0 {
+		sweeper := usecase.NewConversationSweeper(convoRepo, historyRepo, outboxMessenger, chatLocker, txManager, usecase.SweeperConfig{
+			AwaitingReviewTimeout: envDuration("AWAITING_REVIEW_TIMEOUT", 24*time.Hour),
+			ReminderAfter:         envDuration("AWAITING_REVIEW_REMINDER_AFTER", 0),
+			BatchSize:             100,
+		})
+		go worker.RunPeriodic(ctx, "conversation-sweeper", sweeperInterval, sweeper.Sweep)
+	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	campaignScheduler := usecase.NewCampaignScheduler(campaignRepo, convoRepo, historyRepo, outboxMessenger, chatLocker, txManager)
+	if campaignInterval := envDuration("CAMPAIGN_INTERVAL", 0); campaignInterval > 0 {
+		go worker.RunPeriodic(ctx, "review-campaigns", campaignInterval, campaignScheduler.RunCampaigns)
+	}
+
+	outboxDispatcher := usecase.NewOutboxDispatcher(outboxRepo, txManager, deliveryClient, usecase.OutboxConfig{
+		MaxAttempts: envInt("OUTBOX_MAX_ATTEMPTS", 8),
+		BaseBackoff: envDuration("OUTBOX_BASE_BACKOFF", 2*time.Second),
+		MaxBackoff:  envDuration("OUTBOX_MAX_BACKOFF", 10*time.Minute),
+		BatchSize:   50,
+	})
+	go worker.RunPeriodic(ctx, "outbox-dispatcher", envDuration("OUTBOX_POLL_INTERVAL", time.Second), outboxDispatcher.Dispatch)
+
+	processingMode := envOrDefault("MESSAGE_PROCESSING_MODE", "sync")
+	if processingMode != "sync" && processingMode != "async" {
+		log.Fatalf("FATAL: MESSAGE_PROCESSING_MODE must be 'sync' or 'async', got '%s'", processingMode)
+	}
+	inboundService := usecase.NewInboundService(reviewUseCase, inboundQueue, usecase.InboundConfig{
+		Async:             processingMode == "async",
+		MaxAttempts:       envInt("INBOUND_MAX_ATTEMPTS", 3),
+		RetryDelay:        envDuration("INBOUND_RETRY_DELAY", 5*time.Second),
+		VisibilityTimeout: envDuration("INBOUND_VISIBILITY_TIMEOUT", 5*time.Minute),
+		StatsWindow:       15 * time.Minute,
+	})
+	// Workers also run in sync mode so messages queued before a switch are still drained.
+	go worker.RunPool(ctx, "inbound-workers", envInt("INBOUND_WORKERS", 4), 500*time.Millisecond, inboundService.ProcessNext)
+	go worker.RunPeriodic(ctx, "inbound-requeue", time.Minute, inboundService.RequeueStale)
+	log.Printf("Processing inbound messages in %s mode.", processingMode)
+
+	deliveryTracker := usecase.NewDeliveryTracker(outboxRepo, txManager)
+	go worker.RunPeriodic(ctx, "early-receipt-pruner", time.Hour, deliveryTracker.PruneEarlyReceipts)
+	conversationAdmin := usecase.NewConversationAdmin(convoRepo, chatLocker, txManager)
+	// API_AUTH=disabled leaves the API public, e.g. for local development.
+	switch mode := envOrDefault("API_AUTH", "required"); mode {
+	case "required":
+		if tokenSigner == nil {
+			log.Println("INFO: AUTH_TOKEN_SECRET not set, the API accepts API keys only.")
+		}
+	case "disabled":
+		log.Println("WARNING: API_AUTH=disabled, the API is public.")
+		apiKeyService = nil
+	default:
+		log.Fatalf("FATAL: API_AUTH must be 'required' or 'disabled', got '%s'", mode)
 	}
 
+	// Messages from the API and the web chat are rate limited per chat, user
+	// and client IP. The postgres store shares the limits between instances.
+	var rateLimiter usecase.RateLimiter
+	var rateLimitStore usecase.RateLimitStore
+	switch store := envOrDefault("RATE_LIMIT_STORE", "memory"); store {
+	case "memory":
+		rateLimitStore = ratelimit.NewMemoryStore()
+	case "postgres":
+		rateLimitStore = gwStorage.NewRateLimitStore(db)
+	case "disabled":
+		log.Println("WARNING: RATE_LIMIT_STORE=disabled, messages are not rate limited.")
+	default:
+		log.Fatalf("FATAL: RATE_LIMIT_STORE must be 'memory', 'postgres' or 'disabled', got '%s'", store)
+	}
+	if rateLimitStore != nil {
+		rateLimiter = usecase.NewRateLimiter(rateLimitStore, usecase.RateLimitConfig{
+			PerChat: envRateLimit("RATE_LIMIT_PER_CHAT", "10/1m"),
+			PerUser: envRateLimit("RATE_LIMIT_PER_USER", "20/1m"),
+			PerIP:   envRateLimit("RATE_LIMIT_PER_IP", "60/1m"),
+			Flood:   envRateLimit("FLOOD_LIMIT", "60/10m"),
+			MuteFor: envDuration("FLOOD_MUTE_DURATION", 15*time.Minute),
+		})
+		go worker.RunPeriodic(ctx, "rate-limit-pruner", 10*time.Minute, rateLimiter.Prune)
+	}
+
+	srv := server.NewServer(inboundService, historyRepo, reviewPromoter, campaignScheduler, deliveryTracker, conversationAdmin, apiKeyService, tokenSigner, rateLimiter)
+	if os.Getenv("TRUST_X_FORWARDED_FOR") == "true" {
+		srv.EnableForwardedFor()
+	}
+
+	if telegramClient != nil {
+		switch mode := envOrDefault("TELEGRAM_MODE", "webhook"); mode {
+		case "webhook":
+			secret := requireEnv("TELEGRAM_WEBHOOK_SECRET")
+			srv.EnableTelegramWebhook(secret)
+			if webhookURL := os.Getenv("TELEGRAM_WEBHOOK_URL"); webhookURL != "" {
+				if err := telegramClient.SetWebhook(ctx, webhookURL, secret); err != nil {
+					log.Fatalf("FATAL: Failed to register Telegram webhook: %v", err)
+				}
+				log.Printf("Registered Telegram webhook %s", webhookURL)
+			}
+		case "polling":
+			poller := gwMessenger.NewTelegramPoller(telegramClient)
+			go poller.Run(ctx, func(ctx context.Context, input usecase.HandleMessageInput) error {
+				_, err := inboundService.Submit(ctx, input)
+				return err
+			})
+		default:
+			log.Fatalf("FATAL: TELEGRAM_MODE must be 'webhook' or 'polling', got '%s'", mode)
+		}
+	}
+	if whatsAppClient != nil {
+		srv.EnableWhatsAppWebhook(requireEnv("WHATSAPP_VERIFY_TOKEN"), requireEnv("WHATSAPP_APP_SECRET"))
+	}
+	if twilioCfg.AuthToken != "" {
+		srv.EnableSMSWebhook(usecase.NewSubscriptionService(optOutRepo), twilioCfg.AuthToken, os.Getenv("TWILIO_WEBHOOK_URL"), twilioCfg.StatusCallbackURL)
+	}
+	if emailEnabled {
+		srv.EnableEmailWebhook(usecase.NewEmailThreader(emailThreadRepo, txManager), attachmentService, requireEnv("EMAIL_WEBHOOK_SECRET"))
+	}
+	if (webSocketHub != nil || attachmentService != nil) && tokenSigner == nil {
+		log.Fatal("FATAL: AUTH_TOKEN_SECRET environment variable not set.")
+	}
+	if webSocketHub != nil {
+		srv.EnableWebSocket(webSocketHub, tokenSigner, envDuration("WS_TOKEN_TTL", 24*time.Hour))
+	}
+	if tokenSigner != nil {
+		srv.EnableWebSessions(usecase.NewWebSessionService(gwStorage.NewWebSessionRepository(db)), tokenSigner, envDuration("WEB_SESSION_TTL", 30*24*time.Hour))
+	}
+	attachmentURLTTL := envDuration("ATTACHMENT_URL_TTL", time.Hour)
+	if attachmentService != nil {
+		srv.EnableAttachments(attachmentService, tokenSigner, attachmentURLTTL, attachmentMaxBytes)
+	}
+	srv.EnableReviewAPI(usecase.NewReviewReader(reviewRepo, attachmentRepo), tokenSigner, attachmentURLTTL)
+
 	log.Printf("Attempting to start server on port %s...", port)
 	if err := srv.Start(port); err != nil {
 		log.Fatalf("FATAL: Failed to start server: %v", err)
@@ -73,3 +359,58 @@ func main() {
 
 	log.Println("Server stopped gracefully.")
 }
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func requireEnv(key string) string {
+	v := os.Getenv(key)
+	if v == "" {
+		log.Fatalf("FATAL: %s environment variable not set.", key)
+	}
+	return v
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Fatalf("FATAL: %s must be a non-negative duration like '30m' or '24h', got '%s'", key, v)
+	}
+	return d
+}
+
+// envRateLimit parses a limit like "10/1m", ten messages at once and ten
+// more per minute. "off" disables the limit.
+func envRateLimit(key, fallback string) usecase.RateLimit {
+	v := envOrDefault(key, fallback)
+	if v == "off" {
+		return usecase.RateLimit{}
+	}
+	burst, interval, found := strings.Cut(v, "/")
+	n, err := strconv.Atoi(burst)
+	d, durationErr := time.ParseDuration(interval)
+	if !found || err != nil || durationErr != nil || n < 1 || d <= 0 {
+		log.Fatalf("FATAL: %s must be a limit like '10/1m' or 'off', got '%s'", key, v)
+	}
+	return usecase.RateLimit{Burst: n, Interval: d}
+}
+
+func envInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		log.Fatalf("FATAL: %s must be a positive number, got '%s'", key, v)
+	}
+	return n
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"testing"
 	"time"
 
@@ -92,11 +93,48 @@ func TestE2EConversationFlow(t *testing.T) {
 	assert.Equal("Idle", convoState, "Expected conversation state to return to Idle")
 }
 
+func TestE2EDuplicateMessageIsReplayed(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	testChatID := int64(3002)
+	testUserID := int64(4002)
+
+	db, err := sql.Open("pgx", testDbURL)
+	require.NoError(err, "Failed to connect to test DB")
+	defer db.Close()
+
+	_, err = db.Exec(`DELETE FROM conversations WHERE chat_id = $1;`, testChatID)
+	require.NoError(err)
+
+	messageID := fmt.Sprintf("e2e-%d", time.Now().UnixNano())
+
+	first, statusCode, err := sendMessageWithIDAPI(testChatID, testUserID, "Do you deliver on Sundays?", messageID)
+	require.NoError(err)
+	require.Equal(http.StatusOK, statusCode)
+	require.NotEmpty(first.Reply)
+
+	// A retried delivery with the same message_id must not be processed again.
+	second, statusCode, err := sendMessageWithIDAPI(testChatID, testUserID, "Do you deliver on Sundays?", messageID)
+	require.NoError(err)
+	require.Equal(http.StatusOK, statusCode)
+	assert.Equal(first.Reply, second.Reply, "Expected the stored reply to be replayed")
+
+	var historyCount int
+	err = db.QueryRow(`SELECT count(*) FROM message_history WHERE chat_id = $1`, testChatID).Scan(&historyCount)
+	require.NoError(err)
+	assert.Equal(2, historyCount, "Expected the duplicate to add no history entries")
+}
+
 type apiResponseMessage struct {
 	Reply string `json:"reply"`
 }
 
 func sendMessageAPI(chatID, userID int64, text string) (apiResponseMessage, int, error) {
+	return sendMessageWithIDAPI(chatID, userID, text, "")
+}
+
+func sendMessageWithIDAPI(chatID, userID int64, text, messageID string) (apiResponseMessage, int, error) {
 	apiURL := fmt.Sprintf("%s/message", baseAPIURL)
 	requestBody := map[string]interface{}{
 		"chat_id":   chatID,
@@ -104,6 +142,9 @@ func sendMessageAPI(chatID, userID int64, text string) (apiResponseMessage, int,
 		"user_name": fmt.Sprintf("E2E User %d", userID),
 		"text":      text,
 	}
+	if messageID != "" {
+		requestBody["message_id"] = messageID
+	}
 	jsonBody, _ := json.Marshal(requestBody)
 
 	req, err := http.NewRequestWithContext(context.Background(), "POST", apiURL, bytes.NewBuffer(jsonBody))
@@ -111,6 +152,10 @@ func sendMessageAPI(chatID, userID int64, text string) (apiResponseMessage, int,
 		return apiResponseMessage{}, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	// With API_AUTH=required the app needs an integration or owner key.
+	if apiKey := os.Getenv("E2E_API_KEY"); apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
 
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)